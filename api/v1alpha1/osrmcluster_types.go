@@ -17,7 +17,11 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/itayankri/OSRM-Operator/internal/status"
 	corev1 "k8s.io/api/core/v1"
@@ -27,12 +31,55 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-const defaultImage = "ghcr.io/project-osrm/osrm-backend:v5.27.1"
-const defaultSpeedUpdatesFetcherImage = "itayankri/osrm-speed-updates:osrm-v5.27.1"
-const defaultBuilderImage = "itayankri/osrm-builder:osrm-v5.27.1"
+const defaultOSRMVersion = "v5.27.1"
+const defaultImageRepository = "ghcr.io/project-osrm/osrm-backend"
+const defaultSpeedUpdatesFetcherImageRepository = "itayankri/osrm-speed-updates"
+const defaultBuilderImageRepository = "itayankri/osrm-builder"
+const defaultSnapshotsKeep = 3
+const defaultCanaryWeight = 10
+const defaultCanaryAnalysisDuration = 10 * time.Minute
+const defaultCanaryMaxErrorRateIncrease = 1
+const defaultCanaryMinRequests = 100
+const defaultRolloutStepInterval = 5 * time.Minute
+const defaultMaxMatchingSize = 21474836
+const defaultProbeCoordinate = "0,0"
+const defaultObjectStorageImage = "amazon/aws-cli:2.17.40"
+const defaultHealthCheckInterval = time.Minute
+const defaultHealthCheckService = "route"
+const defaultTracingSamplingPercentage = 100
+const defaultTracingImage = "nginx:otel"
+const defaultResyncInterval = 10 * time.Minute
+
+var defaultRolloutSteps = []int32{10, 50, 100}
 
 const OperatorPausedAnnotation = "osrm.itayankri/operator.paused"
 
+// RebuildMapAnnotation triggers a one-off map build whenever its value (an arbitrary token) changes
+const RebuildMapAnnotation = "osrm.itayankri/rebuild-map"
+
+// RebuildMapProfilesAnnotation optionally limits RebuildMapAnnotation to a comma-separated list of profiles
+const RebuildMapProfilesAnnotation = "osrm.itayankri/rebuild-map-profiles"
+
+// RetryMapBuildAnnotation recreates the failed map data Jobs whenever its value (an arbitrary token) changes
+const RetryMapBuildAnnotation = "osrm.itayankri/retry-map-build"
+
+// SwitchoverAnnotation pauses ("pause") the blue/green switchovers in progress
+const SwitchoverAnnotation = "osrm.itayankri/switchover"
+
+const SwitchoverActionPause = "pause"
+
+// AbortSwitchoverAnnotation aborts the blue/green switchovers in progress whenever its value (an arbitrary token) changes
+const AbortSwitchoverAnnotation = "osrm.itayankri/abort-switchover"
+
+// AbortSwitchoverProfilesAnnotation optionally limits AbortSwitchoverAnnotation to a comma-separated list of profiles
+const AbortSwitchoverProfilesAnnotation = "osrm.itayankri/abort-switchover-profiles"
+
+// UpdateSpeedsAnnotation triggers a one-off speed update whenever its value (an arbitrary token) changes
+const UpdateSpeedsAnnotation = "osrm.itayankri/update-speeds"
+
+// UpdateSpeedsProfilesAnnotation optionally limits UpdateSpeedsAnnotation to a comma-separated list of profiles
+const UpdateSpeedsProfilesAnnotation = "osrm.itayankri/update-speeds-profiles"
+
 // Phase is the current phase of the deployment
 type Phase string
 
@@ -66,19 +113,252 @@ const (
 type OSRMClusterSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	PBFURL      string          `json:"pbfUrl,omitempty"`
-	Profiles    ProfilesSpec    `json:"profiles,omitempty"`
-	Service     ServiceSpec     `json:"service,omitempty"`
-	Image       *string         `json:"image,omitempty"`
+	PBFURL   string       `json:"pbfUrl,omitempty"`
+	Profiles ProfilesSpec `json:"profiles,omitempty"`
+	Service  ServiceSpec  `json:"service,omitempty"`
+	Image    *string      `json:"image,omitempty"`
+	// OSRMVersion is the OSRM release of the osrm-routed, map builder and speed updates images,
+	// unless they are overridden. Defaults to v5.27.1.
+	// +kubebuilder:validation:Pattern=`^v[0-9]+\.[0-9]+\.[0-9]+$`
+	OSRMVersion *string `json:"osrmVersion,omitempty"`
+	// OSRMVersionUpgradePolicy defines what happens when osrmVersion changes to a release
+	// that cannot serve the existing map data. Defaults to Rebuild.
+	// +kubebuilder:validation:Enum=Rebuild;Block;Canary
+	OSRMVersionUpgradePolicy *OSRMVersionUpgradePolicy `json:"osrmVersionUpgradePolicy,omitempty"`
+	// Canary configures upgrades with the Canary osrmVersionUpgradePolicy.
+	Canary *CanarySpec `json:"canary,omitempty"`
+	// Rollout defines how workers move to new map data once a map build completes.
+	Rollout     *RolloutSpec    `json:"rollout,omitempty"`
 	Persistence PersistenceSpec `json:"persistence,omitempty"`
 	MapBuilder  MapBuilderSpec  `json:"mapBuilder,omitempty"`
+	// ObjectStorage stores built map data in an S3-compatible bucket.
+	ObjectStorage *ObjectStorageSpec `json:"objectStorage,omitempty"`
+	// MapSource overrides where the map data of the profiles comes from.
+	MapSource *MapSourceSpec `json:"mapSource,omitempty"`
+	// Probes configures the probes of the osrm-routed workers of all profiles.
+	// The workers have no probes unless probes are set for the cluster or their profile.
+	Probes *ProbesSpec `json:"probes,omitempty"`
+	// Monitoring exposes the requests served by the workers and the gateway to Prometheus.
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
+	// HealthChecks are test queries the operator periodically sends through the gateway.
+	// +listType=map
+	// +listMapKey=name
+	HealthChecks []HealthCheckSpec `json:"healthChecks,omitempty"`
+	// HealthCheckInterval is how often the health checks run. Defaults to 1m.
+	HealthCheckInterval *metav1.Duration `json:"healthCheckInterval,omitempty"`
+	// Tracing exports a span for each request proxied by the gateway to an OpenTelemetry collector.
+	Tracing *TracingSpec `json:"tracing,omitempty"`
+	// DriftPolicy defines what happens when fields the operator sets on the child resources
+	// are changed by others. Defaults to Correct.
+	// +kubebuilder:validation:Enum=Correct;Report
+	DriftPolicy *DriftPolicy `json:"driftPolicy,omitempty"`
+	// ResyncInterval is how often the child resources are compared with their desired state
+	// when no change triggers a reconciliation. Defaults to 10m.
+	ResyncInterval *metav1.Duration `json:"resyncInterval,omitempty"`
+}
+
+func (spec *OSRMClusterSpec) GetOSRMVersion() string {
+	if spec.OSRMVersion != nil {
+		return *spec.OSRMVersion
+	}
+	return defaultOSRMVersion
+}
+
+func (spec *OSRMClusterSpec) GetOSRMVersionUpgradePolicy() OSRMVersionUpgradePolicy {
+	if spec.OSRMVersionUpgradePolicy != nil {
+		return *spec.OSRMVersionUpgradePolicy
+	}
+	return OSRMVersionUpgradePolicyRebuild
 }
 
 func (spec *OSRMClusterSpec) GetImage() string {
-	if spec.Image != nil {
+	return spec.GetImageForOSRMVersion(spec.GetOSRMVersion())
+}
+
+// GetImageForOSRMVersion returns the osrm-routed image of an OSRM release.
+// spec.image is assumed to be of spec.osrmVersion.
+func (spec *OSRMClusterSpec) GetImageForOSRMVersion(osrmVersion string) string {
+	if spec.Image != nil && osrmVersion == spec.GetOSRMVersion() {
 		return *spec.Image
 	}
-	return defaultImage
+	return fmt.Sprintf("%s:%s", defaultImageRepository, osrmVersion)
+}
+
+func (spec *OSRMClusterSpec) GetDriftPolicy() DriftPolicy {
+	if spec.DriftPolicy != nil {
+		return *spec.DriftPolicy
+	}
+	return DriftPolicyCorrect
+}
+
+func (spec *OSRMClusterSpec) GetResyncInterval() time.Duration {
+	if spec.ResyncInterval != nil {
+		return spec.ResyncInterval.Duration
+	}
+	return defaultResyncInterval
+}
+
+// DriftPolicy defines how changes made by others to the fields the operator sets are handled
+type DriftPolicy string
+
+const (
+	// DriftPolicyCorrect reverts the changed fields to their desired values.
+	DriftPolicyCorrect DriftPolicy = "Correct"
+	// DriftPolicyReport only reports the changed fields, and leaves them as they are until the
+	// changes are reverted or the policy is set to Correct. The other fields are still applied.
+	DriftPolicyReport DriftPolicy = "Report"
+)
+
+// OSRMVersionUpgradePolicy defines how map data built by an incompatible OSRM release is handled
+type OSRMVersionUpgradePolicy string
+
+const (
+	// OSRMVersionUpgradePolicyRebuild rebuilds the map data with the new release.
+	OSRMVersionUpgradePolicyRebuild OSRMVersionUpgradePolicy = "Rebuild"
+	// OSRMVersionUpgradePolicyBlock keeps serving the map data with the release it was built with.
+	OSRMVersionUpgradePolicyBlock OSRMVersionUpgradePolicy = "Block"
+	// OSRMVersionUpgradePolicyCanary serves a fraction of one profile's traffic with map data built
+	// by the new release, and rebuilds all profiles only when its error rate does not increase.
+	OSRMVersionUpgradePolicyCanary OSRMVersionUpgradePolicy = "Canary"
+)
+
+type CanarySpec struct {
+	// Profile is the profile that receives the canary. Defaults to the first profile.
+	Profile *string `json:"profile,omitempty"`
+	// Weight is the percentage of the profile's requests served by the canary. Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight *int32 `json:"weight,omitempty"`
+	// AnalysisDuration is how long the canary serves requests before it is promoted or rolled back.
+	// Defaults to 10m.
+	AnalysisDuration *metav1.Duration `json:"analysisDuration,omitempty"`
+	// MaxErrorRateIncrease is the number of percentage points the canary's error rate may exceed
+	// the error rate of the current release by. Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	MaxErrorRateIncrease *int32 `json:"maxErrorRateIncrease,omitempty"`
+	// MinRequests is the number of requests the canary must serve for its error rate
+	// to be compared. The analysis is extended by up to another analysisDuration until
+	// the canary served them, and the upgrade is rolled back otherwise. Defaults to 100.
+	// +kubebuilder:validation:Minimum=1
+	MinRequests *int64 `json:"minRequests,omitempty"`
+}
+
+// GetCanaryProfile returns the profile that receives the canary of an OSRM upgrade.
+func (spec *OSRMClusterSpec) GetCanaryProfile() *ProfileSpec {
+	if len(spec.Profiles) == 0 {
+		return nil
+	}
+	if spec.Canary != nil && spec.Canary.Profile != nil {
+		for _, profile := range spec.Profiles {
+			if profile.Name == *spec.Canary.Profile {
+				return profile
+			}
+		}
+	}
+	return spec.Profiles[0]
+}
+
+func (spec *CanarySpec) GetWeight() int32 {
+	if spec != nil && spec.Weight != nil {
+		return *spec.Weight
+	}
+	return defaultCanaryWeight
+}
+
+func (spec *CanarySpec) GetAnalysisDuration() time.Duration {
+	if spec != nil && spec.AnalysisDuration != nil {
+		return spec.AnalysisDuration.Duration
+	}
+	return defaultCanaryAnalysisDuration
+}
+
+func (spec *CanarySpec) GetMaxErrorRateIncrease() int32 {
+	if spec != nil && spec.MaxErrorRateIncrease != nil {
+		return *spec.MaxErrorRateIncrease
+	}
+	return defaultCanaryMaxErrorRateIncrease
+}
+
+func (spec *CanarySpec) GetMinRequests() int64 {
+	if spec != nil && spec.MinRequests != nil {
+		return *spec.MinRequests
+	}
+	return defaultCanaryMinRequests
+}
+
+// RolloutStrategy defines how workers move to new map data
+type RolloutStrategy string
+
+const (
+	// RolloutStrategyRollingUpdate restarts the workers of a profile onto the new map data with a rolling update.
+	RolloutStrategyRollingUpdate RolloutStrategy = "RollingUpdate"
+	// RolloutStrategyBlueGreen serves the new map data with a second Deployment, and shifts the
+	// profile's requests to it in steps before the workers of the profile are moved to it.
+	RolloutStrategyBlueGreen RolloutStrategy = "BlueGreen"
+)
+
+type RolloutSpec struct {
+	// Strategy defaults to RollingUpdate.
+	// +kubebuilder:validation:Enum=RollingUpdate;BlueGreen
+	Strategy *RolloutStrategy `json:"strategy,omitempty"`
+	// Steps are the percentages of a profile's requests sent to the new map data, in order.
+	// All requests are sent to it after the last step. Defaults to [10, 50, 100].
+	// +kubebuilder:validation:items:Minimum=1
+	// +kubebuilder:validation:items:Maximum=100
+	Steps []int32 `json:"steps,omitempty"`
+	// StepInterval is how long each step lasts. Defaults to 5m.
+	StepInterval *metav1.Duration `json:"stepInterval,omitempty"`
+}
+
+func (spec *RolloutSpec) IsBlueGreen() bool {
+	return spec != nil && spec.Strategy != nil && *spec.Strategy == RolloutStrategyBlueGreen
+}
+
+func (spec *RolloutSpec) GetSteps() []int32 {
+	if spec != nil && len(spec.Steps) > 0 {
+		return spec.Steps
+	}
+	return defaultRolloutSteps
+}
+
+func (spec *RolloutSpec) GetStepInterval() time.Duration {
+	if spec != nil && spec.StepInterval != nil {
+		return spec.StepInterval.Duration
+	}
+	return defaultRolloutStepInterval
+}
+
+// IsOSRMVersionCompatible returns true when map data built by one OSRM release can
+// be served by another. OSRM data files are only compatible within a minor release.
+func IsOSRMVersionCompatible(built, serving string) bool {
+	return minorVersion(built) == minorVersion(serving)
+}
+
+func minorVersion(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return strings.Join(parts[:2], ".")
+}
+
+// HasMapDataVolume returns false when workers fetch map data from object
+// storage that is populated elsewhere, in which case no data volume or Job is needed.
+func (spec *OSRMClusterSpec) HasMapDataVolume() bool {
+	return spec.ObjectStorage == nil || spec.ObjectStorage.Upload || !spec.ObjectStorage.Hydrate
+}
+
+// IsPrebuilt returns true when map data is imported instead of built from the PBF file.
+func (spec *OSRMClusterSpec) IsPrebuilt() bool {
+	return spec.MapSource != nil && spec.MapSource.Prebuilt != nil
+}
+
+func (spec *OSRMClusterSpec) GetAlgorithm() string {
+	if spec.IsPrebuilt() && spec.MapSource.Prebuilt.Algorithm != nil {
+		return *spec.MapSource.Prebuilt.Algorithm
+	}
+	return AlgorithmMLD
 }
 
 func (spec *OSRMClusterSpec) GetPbfFileName() string {
@@ -87,6 +367,9 @@ func (spec *OSRMClusterSpec) GetPbfFileName() string {
 }
 
 func (spec *OSRMClusterSpec) GetOsrmFileName() string {
+	if spec.IsPrebuilt() && spec.MapSource.Prebuilt.OSRMFileName != nil {
+		return *spec.MapSource.Prebuilt.OSRMFileName
+	}
 	return strings.ReplaceAll(spec.GetPbfFileName(), "osm.pbf", "osrm")
 }
 
@@ -102,6 +385,239 @@ type ProfileSpec struct {
 	MaxReplicas      *int32                       `json:"maxReplicas,omitempty"`
 	Resources        *corev1.ResourceRequirements `json:"resources,omitempty"`
 	SpeedUpdates     *SpeedUpdatesSpec            `json:"speedUpdates,omitempty"`
+	// Persistence overrides spec.persistence for the map data volume of this profile.
+	Persistence *ProfilePersistenceSpec `json:"persistence,omitempty"`
+	// MapBuilder overrides spec.mapBuilder for this profile.
+	MapBuilder *ProfileMapBuilderSpec `json:"mapBuilder,omitempty"`
+	// Routed tunes the osrm-routed workers of this profile.
+	Routed *OSRMRoutedSpec `json:"routed,omitempty"`
+	// Probes overrides spec.probes for the workers of this profile.
+	Probes *ProbesSpec `json:"probes,omitempty"`
+}
+
+// ProbesSpec configures the probes of osrm-routed. By default, the startup and readiness
+// probes issue a nearest request, which only succeeds once the map data is loaded, and the
+// liveness probe issues the same request less often.
+type ProbesSpec struct {
+	// Disabled removes the probes of the workers.
+	Disabled *bool `json:"disabled,omitempty"`
+	// Coordinate is the "longitude,latitude" of the nearest request, which should be inside the map.
+	// Defaults to "0,0".
+	// +kubebuilder:validation:Pattern=`^-?[0-9]+(\.[0-9]+)?,-?[0-9]+(\.[0-9]+)?$`
+	Coordinate *string `json:"coordinate,omitempty"`
+	// Startup replaces the default startup probe.
+	Startup *corev1.Probe `json:"startup,omitempty"`
+	// Readiness replaces the default readiness probe.
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
+	// Liveness replaces the default liveness probe.
+	Liveness *corev1.Probe `json:"liveness,omitempty"`
+}
+
+// MonitorKind is the Prometheus Operator object that scrapes the metrics exporters
+type MonitorKind string
+
+const (
+	MonitorKindPodMonitor     MonitorKind = "PodMonitor"
+	MonitorKindServiceMonitor MonitorKind = "ServiceMonitor"
+)
+
+// MonitoringSpec injects a metrics exporter into the worker and gateway pods, which counts
+// the requests and their latency per OSRM service, profile and status code.
+type MonitoringSpec struct {
+	// Kind is the Prometheus Operator object created to scrape the exporters, when the
+	// Prometheus Operator CRDs are installed. Defaults to PodMonitor.
+	// +kubebuilder:validation:Enum=PodMonitor;ServiceMonitor
+	Kind *MonitorKind `json:"kind,omitempty"`
+	// Interval is the scrape interval, e.g. 30s. Defaults to the interval of Prometheus.
+	// +kubebuilder:validation:Pattern=`^(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`
+	Interval *string `json:"interval,omitempty"`
+	// Labels are added to the monitor, e.g. to match the monitor selector of Prometheus.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Exporter configures the exporter sidecar of the workers.
+	Exporter *ExporterSpec `json:"exporter,omitempty"`
+}
+
+type ExporterSpec struct {
+	// Image is an nginx image with the njs module. Defaults to the gateway image.
+	Image     *string                      `json:"image,omitempty"`
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// TracingSpec configures the OpenTelemetry module of the gateway, which records a span for
+// each request with its profile and OSRM service, and propagates the W3C trace context to the
+// workers.
+type TracingSpec struct {
+	// Endpoint is the host:port of the OTLP/gRPC receiver of the collector.
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+	// ServiceName is the service.name of the spans. Defaults to the name of the OSRMCluster.
+	ServiceName *string `json:"serviceName,omitempty"`
+	// SamplingPercentage is the percentage of the requests that are traced, unless they
+	// continue a sampled trace. Defaults to 100.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SamplingPercentage *int32 `json:"samplingPercentage,omitempty"`
+	// Image is an nginx image with the OpenTelemetry module, which replaces the gateway image
+	// while tracing is enabled. Defaults to nginx:otel.
+	Image *string `json:"image,omitempty"`
+}
+
+// HealthCheckSpec is a query with a known answer. The routing of its profile is healthy when
+// the query succeeds and the answer is within the expected bounds.
+type HealthCheckSpec struct {
+	// Name identifies the health check in the status and metrics.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// Service is the OSRM service queried. Defaults to route.
+	// +kubebuilder:validation:Enum=route;table;nearest;match;trip
+	Service *string `json:"service,omitempty"`
+	// Profile is the name of the profile queried.
+	Profile string `json:"profile"`
+	// Coordinates are the longitude,latitude pairs of the query, in order.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:items:Pattern=`^-?[0-9]+(\.[0-9]+)?,-?[0-9]+(\.[0-9]+)?$`
+	Coordinates []string `json:"coordinates"`
+	// MaxDuration is the longest travel time of a healthy answer, e.g. 25m. For the table
+	// service it applies to the travel time from the first to the last coordinate.
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+	// MaxDistance is the longest distance of a healthy answer, in meters. For the nearest
+	// service it applies to the distance of the first coordinate from the road network.
+	// +kubebuilder:validation:Minimum=0
+	MaxDistance *int32 `json:"maxDistance,omitempty"`
+}
+
+func (spec *HealthCheckSpec) GetService() string {
+	if spec.Service != nil {
+		return *spec.Service
+	}
+	return defaultHealthCheckService
+}
+
+func (spec *OSRMClusterSpec) GetHealthCheckInterval() time.Duration {
+	if spec.HealthCheckInterval != nil {
+		return spec.HealthCheckInterval.Duration
+	}
+	return defaultHealthCheckInterval
+}
+
+func (spec *TracingSpec) GetSamplingPercentage() int32 {
+	if spec.SamplingPercentage != nil {
+		return *spec.SamplingPercentage
+	}
+	return defaultTracingSamplingPercentage
+}
+
+func (spec *TracingSpec) GetImage() string {
+	if spec.Image != nil {
+		return *spec.Image
+	}
+	return defaultTracingImage
+}
+
+func (spec *MonitoringSpec) GetKind() MonitorKind {
+	if spec != nil && spec.Kind != nil {
+		return *spec.Kind
+	}
+	return MonitorKindPodMonitor
+}
+
+func (spec *MonitoringSpec) GetExporterImage(defaultImage string) string {
+	if spec != nil && spec.Exporter != nil && spec.Exporter.Image != nil {
+		return *spec.Exporter.Image
+	}
+	return defaultImage
+}
+
+func (spec *MonitoringSpec) GetExporterResources() corev1.ResourceRequirements {
+	if spec != nil && spec.Exporter != nil && spec.Exporter.Resources != nil {
+		return *spec.Exporter.Resources
+	}
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("50m"),
+			corev1.ResourceMemory: resource.MustParse("32Mi"),
+		},
+	}
+}
+
+// OSRMRoutedSpec sets the options of osrm-routed. Unset options keep the defaults of osrm-routed,
+// except for maxMatchingSize.
+type OSRMRoutedSpec struct {
+	// MaxTableSize is the maximum number of locations of a table request.
+	// +kubebuilder:validation:Minimum=1
+	MaxTableSize *int32 `json:"maxTableSize,omitempty"`
+	// MaxViarouteSize is the maximum number of locations of a route request.
+	// +kubebuilder:validation:Minimum=1
+	MaxViarouteSize *int32 `json:"maxViarouteSize,omitempty"`
+	// MaxTripSize is the maximum number of locations of a trip request.
+	// +kubebuilder:validation:Minimum=1
+	MaxTripSize *int32 `json:"maxTripSize,omitempty"`
+	// MaxMatchingSize is the maximum number of locations of a match request. Defaults to 21474836.
+	// +kubebuilder:validation:Minimum=1
+	MaxMatchingSize *int32 `json:"maxMatchingSize,omitempty"`
+	// MaxNearestSize is the maximum number of results of a nearest request.
+	// +kubebuilder:validation:Minimum=1
+	MaxNearestSize *int32 `json:"maxNearestSize,omitempty"`
+	// MaxAlternatives is the maximum number of alternative routes.
+	// +kubebuilder:validation:Minimum=0
+	MaxAlternatives *int32 `json:"maxAlternatives,omitempty"`
+	// MaxMatchingRadius is the maximum radius in meters of a match request, or -1 for no limit.
+	// +kubebuilder:validation:Minimum=-1
+	MaxMatchingRadius *int32 `json:"maxMatchingRadius,omitempty"`
+	// Threads is the number of threads serving requests.
+	// +kubebuilder:validation:Minimum=1
+	Threads *int32 `json:"threads,omitempty"`
+	// MMap maps the map data files into memory instead of loading them.
+	MMap *bool `json:"mmap,omitempty"`
+	// KeepaliveTimeout is the keep-alive timeout of connections in seconds.
+	// +kubebuilder:validation:Minimum=0
+	KeepaliveTimeout *int32 `json:"keepaliveTimeout,omitempty"`
+	// ExtraArgs are appended to the arguments of osrm-routed. They cannot set the options that
+	// have a field, or the algorithm, address and port, which are set by the operator.
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:items:MaxLength=256
+	// +kubebuilder:validation:XValidation:rule="self.all(arg, !arg.matches('^(-a|-i|-p|-t|-m|-k|--algorithm|--ip|--port|--max-[a-z-]+|--threads|--mmap|--keepalive-timeout)(=.*)?$'))",message="extraArgs cannot set options that have a field or are set by the operator"
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+}
+
+// GetArgs returns the arguments of osrm-routed set by the options.
+func (spec *OSRMRoutedSpec) GetArgs() []string {
+	if spec == nil {
+		spec = &OSRMRoutedSpec{}
+	}
+	maxMatchingSize := int32(defaultMaxMatchingSize)
+	if spec.MaxMatchingSize != nil {
+		maxMatchingSize = *spec.MaxMatchingSize
+	}
+
+	args := []string{}
+	for _, option := range []struct {
+		flag  string
+		value *int32
+	}{
+		{"--max-table-size", spec.MaxTableSize},
+		{"--max-viaroute-size", spec.MaxViarouteSize},
+		{"--max-trip-size", spec.MaxTripSize},
+		{"--max-matching-size", &maxMatchingSize},
+		{"--max-nearest-size", spec.MaxNearestSize},
+		{"--max-alternatives", spec.MaxAlternatives},
+		{"--max-matching-radius", spec.MaxMatchingRadius},
+		{"--threads", spec.Threads},
+		{"--keepalive-timeout", spec.KeepaliveTimeout},
+	} {
+		if option.value != nil {
+			args = append(args, option.flag, strconv.Itoa(int(*option.value)))
+		}
+	}
+	if spec.MMap != nil && *spec.MMap {
+		args = append(args, "--mmap")
+	}
+	return append(args, spec.ExtraArgs...)
+}
+
+type ProfilePersistenceSpec struct {
+	StorageClassName *string            `json:"storageClassName,omitempty"`
+	Storage          *resource.Quantity `json:"storage,omitempty"`
 }
 
 func (spec *ProfileSpec) GetMinAvailable() *intstr.IntOrString {
@@ -112,11 +628,11 @@ func (spec *ProfileSpec) GetMinAvailable() *intstr.IntOrString {
 	return &intstr.IntOrString{IntVal: 1}
 }
 
-func (spec *ProfileSpec) GetSpeedUpdatesImage() string {
+func (spec *ProfileSpec) GetSpeedUpdatesImage(osrmVersion string) string {
 	if spec.SpeedUpdates != nil && spec.SpeedUpdates.Image != nil {
 		return *spec.SpeedUpdates.Image
 	}
-	return defaultSpeedUpdatesFetcherImage
+	return fmt.Sprintf("%s:osrm-%s", defaultSpeedUpdatesFetcherImageRepository, osrmVersion)
 }
 
 func (spec *ProfileSpec) GetResources() *corev1.ResourceRequirements {
@@ -146,13 +662,78 @@ type MapBuilderSpec struct {
 	PartitionOptions *string                      `json:"partitionOptions,omitempty"`
 	CustomizeOptions *string                      `json:"customizeOptions,omitempty"`
 	Resources        *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// SharedDownload downloads the PBF file once into a shared volume that all
+	// profiles extract from, instead of downloading it in every profile's Job.
+	SharedDownload *SharedDownloadSpec `json:"sharedDownload,omitempty"`
+	// Stages splits the map build into a Job per stage (extract, partition and
+	// customize), each with its own image, resources and retry policy.
+	Stages *MapBuilderStagesSpec `json:"stages,omitempty"`
+	// BackoffLimit is the number of retries before a map builder Job is marked as failed.
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+	// ActiveDeadlineSeconds limits the duration of a map builder Job.
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+	// TTLSecondsAfterFinished deletes finished map builder Jobs after the given duration.
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+type MapBuilderStagesSpec struct {
+	Extract   *MapBuilderStageSpec `json:"extract,omitempty"`
+	Partition *MapBuilderStageSpec `json:"partition,omitempty"`
+	Customize *MapBuilderStageSpec `json:"customize,omitempty"`
+}
+
+func (spec *MapBuilderStagesSpec) Get(stage MapBuilderStage) *MapBuilderStageSpec {
+	if spec == nil {
+		return nil
+	}
+	switch stage {
+	case MapBuilderStageExtract:
+		return spec.Extract
+	case MapBuilderStagePartition:
+		return spec.Partition
+	case MapBuilderStageCustomize:
+		return spec.Customize
+	}
+	return nil
+}
+
+type MapBuilderStageSpec struct {
+	Image                 *string                      `json:"image,omitempty"`
+	Resources             *corev1.ResourceRequirements `json:"resources,omitempty"`
+	BackoffLimit          *int32                       `json:"backoffLimit,omitempty"`
+	ActiveDeadlineSeconds *int64                       `json:"activeDeadlineSeconds,omitempty"`
+}
+
+// ProfileMapBuilderSpec overrides spec.mapBuilder for a single profile.
+type ProfileMapBuilderSpec struct {
+	Image     *string                      `json:"image,omitempty"`
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Stages splits the map build of the profile into a Job per stage. The
+	// settings of a stage fall back to the profile's and then to spec.mapBuilder.stages.
+	Stages *MapBuilderStagesSpec `json:"stages,omitempty"`
 }
 
-func (spec *MapBuilderSpec) GetImage() string {
+// MapBuilderStage is a step of the map build that can run in its own Job
+type MapBuilderStage string
+
+const (
+	MapBuilderStageExtract   MapBuilderStage = "extract"
+	MapBuilderStagePartition MapBuilderStage = "partition"
+	MapBuilderStageCustomize MapBuilderStage = "customize"
+)
+
+// MapBuilderStages lists the stages of a split map build in the order they run.
+var MapBuilderStages = []MapBuilderStage{
+	MapBuilderStageExtract,
+	MapBuilderStagePartition,
+	MapBuilderStageCustomize,
+}
+
+func (spec *MapBuilderSpec) GetImage(osrmVersion string) string {
 	if spec.Image != nil {
 		return *spec.Image
 	}
-	return defaultBuilderImage
+	return fmt.Sprintf("%s:osrm-%s", defaultBuilderImageRepository, osrmVersion)
 }
 
 func (spec *MapBuilderSpec) GetResources() *corev1.ResourceRequirements {
@@ -167,6 +748,109 @@ func (spec *MapBuilderSpec) GetResources() *corev1.ResourceRequirements {
 	return spec.Resources
 }
 
+type SharedDownloadSpec struct {
+	// Checksum is the expected SHA-256 of the PBF file. When omitted, the
+	// MD5 file published next to the PBF (if any) is used for verification.
+	Checksum  *string                      `json:"checksum,omitempty"`
+	Storage   *resource.Quantity           `json:"storage,omitempty"`
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+func (spec *SharedDownloadSpec) GetChecksum() string {
+	if spec.Checksum != nil {
+		return *spec.Checksum
+	}
+	return ""
+}
+
+func (spec *SharedDownloadSpec) GetResources() *corev1.ResourceRequirements {
+	if spec.Resources == nil {
+		return &corev1.ResourceRequirements{}
+	}
+	return spec.Resources
+}
+
+type ObjectStorageSpec struct {
+	// Endpoint of an S3-compatible service (e.g. MinIO). Defaults to AWS S3.
+	Endpoint *string `json:"endpoint,omitempty"`
+	Bucket   string  `json:"bucket"`
+	// Prefix of the objects in the bucket. Defaults to the OSRMCluster's name.
+	Prefix *string `json:"prefix,omitempty"`
+	Region *string `json:"region,omitempty"`
+	// CredentialsSecretName is the name of a Secret holding AWS_ACCESS_KEY_ID
+	// and AWS_SECRET_ACCESS_KEY.
+	CredentialsSecretName *string `json:"credentialsSecretName,omitempty"`
+	Image                 *string `json:"image,omitempty"`
+	// Upload uploads the map data to the bucket after every map build.
+	Upload bool `json:"upload,omitempty"`
+	// Hydrate makes worker pods copy the map data from the bucket onto
+	// ephemeral storage instead of mounting the shared volume.
+	Hydrate bool `json:"hydrate,omitempty"`
+	// Storage limits the size of the ephemeral volume of hydrated workers.
+	Storage *resource.Quantity `json:"storage,omitempty"`
+}
+
+func (spec *ObjectStorageSpec) GetImage() string {
+	if spec.Image != nil {
+		return *spec.Image
+	}
+	return defaultObjectStorageImage
+}
+
+// GetProfileURL returns the S3 URL under which the map data of a profile is stored.
+func (spec *ObjectStorageSpec) GetProfileURL(clusterName string, profile string) string {
+	prefix := clusterName
+	if spec.Prefix != nil {
+		prefix = strings.Trim(*spec.Prefix, "/")
+	}
+	if prefix == "" {
+		return fmt.Sprintf("s3://%s/%s", spec.Bucket, profile)
+	}
+	return fmt.Sprintf("s3://%s/%s/%s", spec.Bucket, prefix, profile)
+}
+
+const (
+	AlgorithmMLD = "mld"
+	AlgorithmCH  = "ch"
+)
+
+type MapSourceSpec struct {
+	// Prebuilt imports existing OSRM datasets instead of building them from the PBF file.
+	Prebuilt *PrebuiltMapSpec `json:"prebuilt,omitempty"`
+}
+
+// PrebuiltMapSpec points at prebuilt OSRM datasets, one per profile. Exactly one
+// of URL, ObjectStorage and PersistentVolumeClaim should be set.
+type PrebuiltMapSpec struct {
+	// URL of a flat tar archive of a profile's dataset. "{profile}" is replaced by the profile name.
+	URL *string `json:"url,omitempty"`
+	// ObjectStorage is a bucket laid out the way spec.objectStorage uploads map data.
+	ObjectStorage *ObjectStorageSpec `json:"objectStorage,omitempty"`
+	// PersistentVolumeClaim holding one directory per profile.
+	PersistentVolumeClaim *PrebuiltVolumeSource `json:"persistentVolumeClaim,omitempty"`
+	// Checksums maps profile names to the expected SHA-256 of the archive
+	// for URL sources, or of the SHA256SUMS file for the other sources.
+	Checksums map[string]string `json:"checksums,omitempty"`
+	// +kubebuilder:validation:Enum=mld;ch
+	Algorithm *string `json:"algorithm,omitempty"`
+	// OSRMFileName is the base name of the dataset files, e.g. "israel.osrm".
+	// Defaults to the name derived from the PBF URL.
+	OSRMFileName *string `json:"osrmFileName,omitempty"`
+}
+
+func (spec *PrebuiltMapSpec) GetURL(profile string) string {
+	if spec.URL == nil {
+		return ""
+	}
+	return strings.ReplaceAll(*spec.URL, "{profile}", profile)
+}
+
+type PrebuiltVolumeSource struct {
+	ClaimName string `json:"claimName"`
+	// Path of the directory holding the profile directories within the volume.
+	Path string `json:"path,omitempty"`
+}
+
 type ServiceSpec struct {
 	Type             *corev1.ServiceType `json:"type,omitempty"`
 	Annotations      map[string]string   `json:"annotations,omitempty"`
@@ -208,6 +892,74 @@ type PersistenceSpec struct {
 	StorageClassName string                             `json:"storageClassName,omitempty"`
 	Storage          *resource.Quantity                 `json:"storage,omitempty"`
 	AccessMode       *corev1.PersistentVolumeAccessMode `json:"accessMode,omitempty"`
+	// WorkerStorage defines where worker pods serve map data from.
+	WorkerStorage *WorkerStorageSpec `json:"workerStorage,omitempty"`
+	// ReclaimPolicy defines what happens to the map data volumes when the
+	// OSRMCluster is deleted. Defaults to Delete.
+	// +kubebuilder:validation:Enum=Retain;Delete
+	ReclaimPolicy *ReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// Snapshots enables VolumeSnapshots of the map data volumes after every map build and speed update.
+	Snapshots *SnapshotsSpec `json:"snapshots,omitempty"`
+	// RestoreFrom maps profile names to the VolumeSnapshot their map data
+	// volume is restored from when it is created.
+	RestoreFrom map[string]string `json:"restoreFrom,omitempty"`
+}
+
+type SnapshotsSpec struct {
+	VolumeSnapshotClassName *string `json:"volumeSnapshotClassName,omitempty"`
+	// Keep is the number of snapshots kept per profile. Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	Keep *int32 `json:"keep,omitempty"`
+}
+
+func (spec *SnapshotsSpec) GetKeep() int {
+	if spec.Keep != nil {
+		return int(*spec.Keep)
+	}
+	return defaultSnapshotsKeep
+}
+
+// ReclaimPolicy defines whether map data volumes outlive their OSRMCluster
+type ReclaimPolicy string
+
+const (
+	ReclaimPolicyRetain ReclaimPolicy = "Retain"
+	ReclaimPolicyDelete ReclaimPolicy = "Delete"
+)
+
+// WorkerStorageMode defines whether workers serve map data from the shared volume or a local copy
+type WorkerStorageMode string
+
+const (
+	WorkerStorageModeShared WorkerStorageMode = "Shared"
+	WorkerStorageModeLocal  WorkerStorageMode = "Local"
+)
+
+// WorkerStorageSource defines what the per-pod volumes of workers are filled from
+type WorkerStorageSource string
+
+const (
+	WorkerStorageSourceVolume   WorkerStorageSource = "Volume"
+	WorkerStorageSourceSnapshot WorkerStorageSource = "Snapshot"
+)
+
+type WorkerStorageSpec struct {
+	// Mode is Shared to mount the map data volume in every worker, or Local to
+	// copy the map data onto a per-pod volume before the worker starts.
+	// +kubebuilder:validation:Enum=Shared;Local
+	Mode WorkerStorageMode `json:"mode,omitempty"`
+	// StorageClassName of the per-pod volume, e.g. a local PV provisioner.
+	// When empty, an emptyDir is used.
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// Storage is the size of the per-pod volume.
+	Storage *resource.Quantity `json:"storage,omitempty"`
+	// Source is Volume to copy the map data from the map data volume, or
+	// Snapshot to restore the per-pod volume from the VolumeSnapshot of the
+	// latest map data, so workers do not mount the map data volume. Snapshot
+	// requires spec.persistence.snapshots and a storageClassName whose CSI
+	// driver can restore the snapshots. Defaults to Volume.
+	// +kubebuilder:validation:Enum=Volume;Snapshot
+	Source WorkerStorageSource `json:"source,omitempty"`
 }
 
 func (spec *PersistenceSpec) GetAccessMode() corev1.PersistentVolumeAccessMode {
@@ -217,6 +969,159 @@ func (spec *PersistenceSpec) GetAccessMode() corev1.PersistentVolumeAccessMode {
 	return corev1.ReadWriteMany
 }
 
+// SplitsMapBuild returns true when the map build of a profile runs in a Job per stage.
+func (spec *OSRMClusterSpec) SplitsMapBuild(profile *ProfileSpec) bool {
+	return spec.MapBuilder.Stages != nil || (profile.MapBuilder != nil && profile.MapBuilder.Stages != nil)
+}
+
+// mapBuilderStages returns the stage settings in order of precedence: the
+// profile's stage, the profile, the cluster's stage. An empty stage refers
+// to the whole map build.
+func (spec *OSRMClusterSpec) mapBuilderStages(profile *ProfileSpec, stage MapBuilderStage) []*MapBuilderStageSpec {
+	stages := []*MapBuilderStageSpec{}
+	if profile.MapBuilder != nil {
+		stages = append(stages, profile.MapBuilder.Stages.Get(stage), &MapBuilderStageSpec{
+			Image:     profile.MapBuilder.Image,
+			Resources: profile.MapBuilder.Resources,
+		})
+	}
+	return append(stages, spec.MapBuilder.Stages.Get(stage))
+}
+
+func (spec *OSRMClusterSpec) GetMapBuilderImage(profile *ProfileSpec, stage MapBuilderStage) string {
+	for _, stageSpec := range spec.mapBuilderStages(profile, stage) {
+		if stageSpec != nil && stageSpec.Image != nil {
+			return *stageSpec.Image
+		}
+	}
+	return spec.MapBuilder.GetImage(spec.GetOSRMVersion())
+}
+
+func (spec *OSRMClusterSpec) GetMapBuilderResources(profile *ProfileSpec, stage MapBuilderStage) *corev1.ResourceRequirements {
+	for _, stageSpec := range spec.mapBuilderStages(profile, stage) {
+		if stageSpec != nil && stageSpec.Resources != nil {
+			return stageSpec.Resources
+		}
+	}
+	return spec.MapBuilder.GetResources()
+}
+
+func (spec *OSRMClusterSpec) GetMapBuilderBackoffLimit(profile *ProfileSpec, stage MapBuilderStage) *int32 {
+	for _, stageSpec := range spec.mapBuilderStages(profile, stage) {
+		if stageSpec != nil && stageSpec.BackoffLimit != nil {
+			return stageSpec.BackoffLimit
+		}
+	}
+	return spec.MapBuilder.BackoffLimit
+}
+
+func (spec *OSRMClusterSpec) GetMapBuilderActiveDeadlineSeconds(profile *ProfileSpec, stage MapBuilderStage) *int64 {
+	for _, stageSpec := range spec.mapBuilderStages(profile, stage) {
+		if stageSpec != nil && stageSpec.ActiveDeadlineSeconds != nil {
+			return stageSpec.ActiveDeadlineSeconds
+		}
+	}
+	return spec.MapBuilder.ActiveDeadlineSeconds
+}
+
+// GetStorage returns the size of the map data volume of a profile.
+func (spec *OSRMClusterSpec) GetStorage(profile *ProfileSpec) *resource.Quantity {
+	if profile.Persistence != nil && profile.Persistence.Storage != nil {
+		return profile.Persistence.Storage
+	}
+	return spec.Persistence.Storage
+}
+
+// profileProbes returns the probe settings of a profile, followed by those of the cluster.
+func (spec *OSRMClusterSpec) profileProbes(profile *ProfileSpec) []*ProbesSpec {
+	probes := []*ProbesSpec{}
+	for _, candidate := range []*ProbesSpec{profile.Probes, spec.Probes} {
+		if candidate != nil {
+			probes = append(probes, candidate)
+		}
+	}
+	return probes
+}
+
+// HasProbes returns true unless the probes are disabled for a profile or the cluster.
+func (spec *OSRMClusterSpec) HasProbes(profile *ProfileSpec) bool {
+	for _, probes := range spec.profileProbes(profile) {
+		if probes.Disabled != nil {
+			return !*probes.Disabled
+		}
+	}
+	return true
+}
+
+// GetProbeCoordinate returns the coordinate of the nearest request of the default probes of a profile.
+func (spec *OSRMClusterSpec) GetProbeCoordinate(profile *ProfileSpec) string {
+	for _, probes := range spec.profileProbes(profile) {
+		if probes.Coordinate != nil {
+			return *probes.Coordinate
+		}
+	}
+	return defaultProbeCoordinate
+}
+
+// GetStartupProbe returns the startup probe that replaces the default of a profile, or nil.
+func (spec *OSRMClusterSpec) GetStartupProbe(profile *ProfileSpec) *corev1.Probe {
+	for _, probes := range spec.profileProbes(profile) {
+		if probes.Startup != nil {
+			return probes.Startup
+		}
+	}
+	return nil
+}
+
+// GetReadinessProbe returns the readiness probe that replaces the default of a profile, or nil.
+func (spec *OSRMClusterSpec) GetReadinessProbe(profile *ProfileSpec) *corev1.Probe {
+	for _, probes := range spec.profileProbes(profile) {
+		if probes.Readiness != nil {
+			return probes.Readiness
+		}
+	}
+	return nil
+}
+
+// GetLivenessProbe returns the liveness probe that replaces the default of a profile, or nil.
+func (spec *OSRMClusterSpec) GetLivenessProbe(profile *ProfileSpec) *corev1.Probe {
+	for _, probes := range spec.profileProbes(profile) {
+		if probes.Liveness != nil {
+			return probes.Liveness
+		}
+	}
+	return nil
+}
+
+// GetStorageClassName returns the storage class of the map data volume of a profile.
+func (spec *OSRMClusterSpec) GetStorageClassName(profile *ProfileSpec) string {
+	if profile.Persistence != nil && profile.Persistence.StorageClassName != nil {
+		return *profile.Persistence.StorageClassName
+	}
+	return spec.Persistence.StorageClassName
+}
+
+func (spec *PersistenceSpec) GetReclaimPolicy() ReclaimPolicy {
+	if spec.ReclaimPolicy != nil {
+		return *spec.ReclaimPolicy
+	}
+	return ReclaimPolicyDelete
+}
+
+// IsLocalWorkerStorage returns true when workers copy map data onto a per-pod volume.
+func (spec *PersistenceSpec) IsLocalWorkerStorage() bool {
+	return spec.WorkerStorage != nil && spec.WorkerStorage.Mode == WorkerStorageModeLocal
+}
+
+// IsSnapshotWorkerStorage returns true when the per-pod volumes of workers are
+// restored from snapshots of the map data volume.
+func (spec *PersistenceSpec) IsSnapshotWorkerStorage() bool {
+	return spec.IsLocalWorkerStorage() &&
+		spec.WorkerStorage.Source == WorkerStorageSourceSnapshot &&
+		spec.WorkerStorage.StorageClassName != "" &&
+		spec.Snapshots != nil
+}
+
 // OSRMClusterStatus defines the observed state of OSRMCluster
 type OSRMClusterStatus struct {
 	// Paused is true when the operator notices paused annotation.
@@ -227,9 +1132,282 @@ type OSRMClusterStatus struct {
 
 	Phase Phase `json:"phase,omitempty"`
 
+	// MapRebuildToken is the last rebuild-map annotation token handled by the operator.
+	MapRebuildToken string `json:"mapRebuildToken,omitempty"`
+
+	// MapBuildRetryToken is the last retry-map-build annotation token handled by the operator.
+	MapBuildRetryToken string `json:"mapBuildRetryToken,omitempty"`
+
+	// SpeedUpdatesToken is the last update-speeds annotation token handled by the operator.
+	SpeedUpdatesToken string `json:"speedUpdatesToken,omitempty"`
+
+	// SwitchoverAbortToken is the last abort-switchover annotation token handled by the operator.
+	SwitchoverAbortToken string `json:"switchoverAbortToken,omitempty"`
+
+	// OSRMUpgrade is the state of the last canary upgrade of the OSRM release.
+	OSRMUpgrade *OSRMUpgradeStatus `json:"osrmUpgrade,omitempty"`
+
+	// Switchovers is the state of the last blue/green switchover of each profile.
+	Switchovers []SwitchoverStatus `json:"switchovers,omitempty"`
+
+	// Download is the state of the shared PBF download stage, if enabled.
+	Download *DownloadStatus `json:"download,omitempty"`
+
+	// Profiles is the state of each profile.
+	Profiles []ProfileStatus `json:"profiles,omitempty"`
+
+	// HealthChecks is the result of the last run of each health check.
+	HealthChecks []HealthCheckStatus `json:"healthChecks,omitempty"`
+
+	// DriftedResources are the child resources left as they are with the Report
+	// drift policy, along with their fields that were changed by others.
+	DriftedResources []DriftedResourceStatus `json:"driftedResources,omitempty"`
+
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// DownloadPhase is the current phase of the shared PBF download
+type DownloadPhase string
+
+const (
+	DownloadPhasePending     DownloadPhase = "Pending"
+	DownloadPhaseDownloading DownloadPhase = "Downloading"
+	DownloadPhaseCompleted   DownloadPhase = "Completed"
+	DownloadPhaseFailed      DownloadPhase = "Failed"
+)
+
+type DownloadStatus struct {
+	Phase          DownloadPhase `json:"phase,omitempty"`
+	URL            string        `json:"url,omitempty"`
+	Checksum       string        `json:"checksum,omitempty"`
+	CompletionTime *metav1.Time  `json:"completionTime,omitempty"`
+}
+
+// OSRMUpgradePhase is the current phase of a canary upgrade
+type OSRMUpgradePhase string
+
+const (
+	// OSRMUpgradePhaseBuilding builds the canary profile's map data with the new release.
+	OSRMUpgradePhaseBuilding OSRMUpgradePhase = "Building"
+	// OSRMUpgradePhaseAnalyzing serves a fraction of the canary profile's requests with the new release.
+	OSRMUpgradePhaseAnalyzing OSRMUpgradePhase = "Analyzing"
+	// OSRMUpgradePhasePromoting rebuilds the map data of all profiles with the new release.
+	OSRMUpgradePhasePromoting  OSRMUpgradePhase = "Promoting"
+	OSRMUpgradePhasePromoted   OSRMUpgradePhase = "Promoted"
+	OSRMUpgradePhaseRolledBack OSRMUpgradePhase = "RolledBack"
+)
+
+type OSRMUpgradeStatus struct {
+	// Version is the OSRM release being upgraded to.
+	Version string `json:"version"`
+	// FromVersion is the OSRM release the map data was built with when the upgrade started.
+	FromVersion string           `json:"fromVersion,omitempty"`
+	Profile     string           `json:"profile,omitempty"`
+	Phase       OSRMUpgradePhase `json:"phase,omitempty"`
+	// RoutingStartTime is the time the canary became available and the gateway
+	// configuration started to send requests to it.
+	RoutingStartTime *metav1.Time `json:"routingStartTime,omitempty"`
+	// AnalysisStartTime is the time the gateway pods rolled out the configuration
+	// that sends requests to the canary.
+	AnalysisStartTime *metav1.Time `json:"analysisStartTime,omitempty"`
+	// Stable and Canary are the requests served by the gateway since the analysis started.
+	Stable  TrafficStats `json:"stable,omitempty"`
+	Canary  TrafficStats `json:"canary,omitempty"`
+	Message string       `json:"message,omitempty"`
+}
+
+// IsCanaryServing returns true while the gateway configuration sends requests to the canary.
+func (upgrade *OSRMUpgradeStatus) IsCanaryServing() bool {
+	return upgrade != nil && upgrade.Phase == OSRMUpgradePhaseAnalyzing && upgrade.RoutingStartTime != nil
+}
+
+// IsInProgress returns true until the upgrade is promoted or rolled back.
+func (upgrade *OSRMUpgradeStatus) IsInProgress() bool {
+	return upgrade != nil &&
+		upgrade.Phase != OSRMUpgradePhasePromoted &&
+		upgrade.Phase != OSRMUpgradePhaseRolledBack
+}
+
+// SwitchoverPhase is the current phase of a blue/green switchover
+type SwitchoverPhase string
+
+const (
+	// SwitchoverPhaseDeploying waits for the green Deployment to serve the new map data.
+	SwitchoverPhaseDeploying SwitchoverPhase = "Deploying"
+	// SwitchoverPhaseShifting sends a growing share of the profile's requests to the green Deployment.
+	SwitchoverPhaseShifting SwitchoverPhase = "Shifting"
+	// SwitchoverPhasePaused holds the current share until the pause is lifted.
+	SwitchoverPhasePaused SwitchoverPhase = "Paused"
+	// SwitchoverPhaseCompleting moves the profile's workers to the new map data while
+	// the green Deployment serves all of the profile's requests.
+	SwitchoverPhaseCompleting SwitchoverPhase = "Completing"
+	SwitchoverPhaseCompleted  SwitchoverPhase = "Completed"
+	SwitchoverPhaseAborted    SwitchoverPhase = "Aborted"
+)
+
+type SwitchoverStatus struct {
+	Profile string `json:"profile"`
+	// MapBuildTime is the completion time of the map build being switched over to.
+	MapBuildTime *metav1.Time    `json:"mapBuildTime,omitempty"`
+	Phase        SwitchoverPhase `json:"phase,omitempty"`
+	// Step is the index of the current step in spec.rollout.steps.
+	Step int32 `json:"step,omitempty"`
+	// Weight is the percentage of the profile's requests sent to the green Deployment.
+	Weight        int32        `json:"weight,omitempty"`
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+	Message       string       `json:"message,omitempty"`
+}
+
+// IsInProgress returns true until the switchover is completed or aborted.
+func (switchover *SwitchoverStatus) IsInProgress() bool {
+	return switchover != nil &&
+		switchover.Phase != SwitchoverPhaseCompleted &&
+		switchover.Phase != SwitchoverPhaseAborted
+}
+
+// GetSwitchover returns the last switchover of a profile, or nil.
+func (status *OSRMClusterStatus) GetSwitchover(profile string) *SwitchoverStatus {
+	for i := range status.Switchovers {
+		if status.Switchovers[i].Profile == profile {
+			return &status.Switchovers[i]
+		}
+	}
+	return nil
+}
+
+// HealthCheckRegression is the change of map data after which a health check started failing
+type HealthCheckRegression string
+
+const (
+	HealthCheckRegressionMapBuild    HealthCheckRegression = "MapBuild"
+	HealthCheckRegressionSpeedUpdate HealthCheckRegression = "SpeedUpdate"
+)
+
+type HealthCheckStatus struct {
+	Name    string `json:"name"`
+	Profile string `json:"profile"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+	// Duration and Distance, in meters, are those of the answer.
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	Distance *int32           `json:"distance,omitempty"`
+	// Latency is how long the gateway took to answer.
+	Latency       *metav1.Duration `json:"latency,omitempty"`
+	LastCheckTime *metav1.Time     `json:"lastCheckTime,omitempty"`
+	// MapBuildTime and SpeedUpdateTime are those of the map data that was checked.
+	MapBuildTime    *metav1.Time `json:"mapBuildTime,omitempty"`
+	SpeedUpdateTime *metav1.Time `json:"speedUpdateTime,omitempty"`
+	// Regression is set when the health check started failing after the map data
+	// of its profile was rebuilt or its speeds were updated.
+	Regression HealthCheckRegression `json:"regression,omitempty"`
+}
+
+// GetHealthCheck returns the last result of a health check, or nil.
+func (status *OSRMClusterStatus) GetHealthCheck(name string) *HealthCheckStatus {
+	for i := range status.HealthChecks {
+		if status.HealthChecks[i].Name == name {
+			return &status.HealthChecks[i]
+		}
+	}
+	return nil
+}
+
+// DriftedResourceStatus is a child resource whose fields set by the operator were changed by others.
+type DriftedResourceStatus struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Fields are the paths of the fields, each with the field manager that
+	// changed it, e.g. .spec.replicas (kubectl-edit).
+	Fields []string `json:"fields"`
+}
+
+// GetDriftedResource returns the drift of a child resource, or nil.
+func (status *OSRMClusterStatus) GetDriftedResource(kind, name string) *DriftedResourceStatus {
+	for i := range status.DriftedResources {
+		if status.DriftedResources[i].Kind == kind && status.DriftedResources[i].Name == name {
+			return &status.DriftedResources[i]
+		}
+	}
+	return nil
+}
+
+// SetDriftedResource records the drift of a child resource, replacing its previous drift.
+func (status *OSRMClusterStatus) SetDriftedResource(drifted DriftedResourceStatus) {
+	if recorded := status.GetDriftedResource(drifted.Kind, drifted.Name); recorded != nil {
+		*recorded = drifted
+		return
+	}
+	status.DriftedResources = append(status.DriftedResources, drifted)
+}
+
+// RemoveDriftedResource forgets the drift of a child resource.
+func (status *OSRMClusterStatus) RemoveDriftedResource(kind, name string) {
+	status.DriftedResources = slices.DeleteFunc(status.DriftedResources, func(drifted DriftedResourceStatus) bool {
+		return drifted.Kind == kind && drifted.Name == name
+	})
+}
+
+type TrafficStats struct {
+	Requests int64 `json:"requests,omitempty"`
+	// Errors is the number of requests that failed with a 5xx status.
+	Errors int64 `json:"errors,omitempty"`
+}
+
+// ErrorRate returns the percentage of requests that failed.
+func (stats TrafficStats) ErrorRate() float64 {
+	if stats.Requests == 0 {
+		return 0
+	}
+	return float64(stats.Errors) * 100 / float64(stats.Requests)
+}
+
+// ProfileStatus is the observed state of a single profile
+type ProfileStatus struct {
+	Name string `json:"name"`
+	// MapBuild is the state of each Job that populates the profile's map data, in the order they run.
+	MapBuild []MapBuildStageStatus `json:"mapBuild,omitempty"`
+	// OSRMVersion is the OSRM release the profile's map data was built with.
+	OSRMVersion string `json:"osrmVersion,omitempty"`
+	// MapInfo is the report of the last successful map build of the profile.
+	MapInfo *MapInfo `json:"mapInfo,omitempty"`
+	// SpeedUpdateTime is the completion time of the last speed update of the profile's map data.
+	SpeedUpdateTime *metav1.Time `json:"speedUpdateTime,omitempty"`
+}
+
+// MapInfo is the data quality report the map builder emits when it completes
+type MapInfo struct {
+	Nodes int64 `json:"nodes,omitempty"`
+	Edges int64 `json:"edges,omitempty"`
+	// BoundingBox of the PBF file as min longitude, min latitude, max longitude, max latitude.
+	BoundingBox string `json:"boundingBox,omitempty"`
+	OSRMVersion string `json:"osrmVersion,omitempty"`
+	// ProfileHash is the SHA-256 of the Lua profile the map data was built with.
+	ProfileHash          string           `json:"profileHash,omitempty"`
+	StageDurationSeconds map[string]int64 `json:"stageDurationSeconds,omitempty"`
+	OutputSizeBytes      int64            `json:"outputSizeBytes,omitempty"`
+	// BuildTime is the completion time of the map builder Job that emitted the report.
+	BuildTime *metav1.Time `json:"buildTime,omitempty"`
+}
+
+// JobPhase is the current phase of a Job
+type JobPhase string
+
+const (
+	JobPhasePending   JobPhase = "Pending"
+	JobPhaseRunning   JobPhase = "Running"
+	JobPhaseCompleted JobPhase = "Completed"
+	JobPhaseFailed    JobPhase = "Failed"
+)
+
+type MapBuildStageStatus struct {
+	// Stage is the map builder stage, or build or import for a map data Job that is not split into stages.
+	Stage          string       `json:"stage"`
+	Job            string       `json:"job"`
+	Phase          JobPhase     `json:"phase,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
 func (osrmClusterStatus *OSRMClusterStatus) SetConditions(resources []runtime.Object) {
 	var oldAvailableCondition *metav1.Condition
 	var oldAllReplicasReadyCondition *metav1.Condition
@@ -255,11 +1433,22 @@ func (osrmClusterStatus *OSRMClusterStatus) SetConditions(resources []runtime.Ob
 
 	availableCondition := status.AvailableCondition(resources, oldAvailableCondition)
 	allReplicasReadyCondition := status.AllReplicasReadyCondition(resources, oldAllReplicasReadyCondition)
-	osrmClusterStatus.Conditions = []metav1.Condition{
+	conditions := []metav1.Condition{
 		availableCondition,
 		allReplicasReadyCondition,
 		reconciliationSuccessCondition,
 	}
+
+	// Keep conditions that are maintained separately by the controller.
+	for _, condition := range osrmClusterStatus.Conditions {
+		switch condition.Type {
+		case status.ConditionAllReplicasReady, status.ConditionAvailable, status.ConditionReconciliationSuccess:
+		default:
+			conditions = append(conditions, condition)
+		}
+	}
+
+	osrmClusterStatus.Conditions = conditions
 }
 
 func (status *OSRMClusterStatus) SetCondition(condition metav1.Condition) {
@@ -271,9 +1460,14 @@ func (status *OSRMClusterStatus) SetCondition(condition metav1.Condition) {
 			status.Conditions[i].Status = condition.Status
 			status.Conditions[i].Reason = condition.Reason
 			status.Conditions[i].Message = condition.Message
-			break
+			return
 		}
 	}
+
+	if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	status.Conditions = append(status.Conditions, condition)
 }
 
 //+kubebuilder:object:root=true
@@ -293,6 +1487,67 @@ func (cluster *OSRMCluster) ChildResourceName(service string, suffix string) str
 	return strings.TrimSuffix(strings.Join([]string{nameWithService, suffix}, "-"), "-")
 }
 
+// OnDemandRequest is a one-off operation requested through an annotation on the OSRMCluster.
+type OnDemandRequest struct {
+	Token    string
+	Profiles []string
+}
+
+// Includes returns true when the request applies to the given profile.
+// A request without an explicit profile list applies to all profiles.
+func (request *OnDemandRequest) Includes(profile string) bool {
+	if len(request.Profiles) == 0 {
+		return true
+	}
+	for _, p := range request.Profiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+func (cluster *OSRMCluster) onDemandRequest(tokenAnnotation, profilesAnnotation string) *OnDemandRequest {
+	token := strings.TrimSpace(cluster.GetAnnotations()[tokenAnnotation])
+	if token == "" {
+		return nil
+	}
+
+	request := &OnDemandRequest{Token: token}
+	for _, profile := range strings.Split(cluster.GetAnnotations()[profilesAnnotation], ",") {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			request.Profiles = append(request.Profiles, profile)
+		}
+	}
+	return request
+}
+
+// MapRebuildRequest returns the requested on-demand map rebuild, or nil if none was requested.
+func (cluster *OSRMCluster) MapRebuildRequest() *OnDemandRequest {
+	return cluster.onDemandRequest(RebuildMapAnnotation, RebuildMapProfilesAnnotation)
+}
+
+// MapBuildRetryRequest returns the requested map build retry, or nil if none was requested.
+// A retry always applies to all profiles.
+func (cluster *OSRMCluster) MapBuildRetryRequest() *OnDemandRequest {
+	return cluster.onDemandRequest(RetryMapBuildAnnotation, "")
+}
+
+// SpeedUpdatesRequest returns the requested on-demand speed update, or nil if none was requested.
+func (cluster *OSRMCluster) SpeedUpdatesRequest() *OnDemandRequest {
+	return cluster.onDemandRequest(UpdateSpeedsAnnotation, UpdateSpeedsProfilesAnnotation)
+}
+
+// SwitchoverAbortRequest returns the requested abort of the switchovers in progress, or nil if none was requested.
+func (cluster *OSRMCluster) SwitchoverAbortRequest() *OnDemandRequest {
+	return cluster.onDemandRequest(AbortSwitchoverAnnotation, AbortSwitchoverProfilesAnnotation)
+}
+
+// SwitchoverAction returns the value of the switchover annotation.
+func (cluster *OSRMCluster) SwitchoverAction() string {
+	return strings.TrimSpace(cluster.GetAnnotations()[SwitchoverAnnotation])
+}
+
 //+kubebuilder:object:root=true
 
 // OSRMClusterList contains a list of OSRMCluster
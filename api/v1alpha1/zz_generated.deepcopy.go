@@ -21,11 +21,215 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanarySpec) DeepCopyInto(out *CanarySpec) {
+	*out = *in
+	if in.Profile != nil {
+		in, out := &in.Profile, &out.Profile
+		*out = new(string)
+		**out = **in
+	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AnalysisDuration != nil {
+		in, out := &in.AnalysisDuration, &out.AnalysisDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxErrorRateIncrease != nil {
+		in, out := &in.MaxErrorRateIncrease, &out.MaxErrorRateIncrease
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MinRequests != nil {
+		in, out := &in.MinRequests, &out.MinRequests
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanarySpec.
+func (in *CanarySpec) DeepCopy() *CanarySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CanarySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DownloadStatus) DeepCopyInto(out *DownloadStatus) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DownloadStatus.
+func (in *DownloadStatus) DeepCopy() *DownloadStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DownloadStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftedResourceStatus) DeepCopyInto(out *DriftedResourceStatus) {
+	*out = *in
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftedResourceStatus.
+func (in *DriftedResourceStatus) DeepCopy() *DriftedResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftedResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExporterSpec) DeepCopyInto(out *ExporterSpec) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExporterSpec.
+func (in *ExporterSpec) DeepCopy() *ExporterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ExporterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthCheckSpec) DeepCopyInto(out *HealthCheckSpec) {
+	*out = *in
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(string)
+		**out = **in
+	}
+	if in.Coordinates != nil {
+		in, out := &in.Coordinates, &out.Coordinates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxDistance != nil {
+		in, out := &in.MaxDistance, &out.MaxDistance
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheckSpec.
+func (in *HealthCheckSpec) DeepCopy() *HealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthCheckStatus) DeepCopyInto(out *HealthCheckStatus) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Distance != nil {
+		in, out := &in.Distance, &out.Distance
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+	if in.MapBuildTime != nil {
+		in, out := &in.MapBuildTime, &out.MapBuildTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SpeedUpdateTime != nil {
+		in, out := &in.SpeedUpdateTime, &out.SpeedUpdateTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheckStatus.
+func (in *HealthCheckStatus) DeepCopy() *HealthCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MapBuildStageStatus) DeepCopyInto(out *MapBuildStageStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MapBuildStageStatus.
+func (in *MapBuildStageStatus) DeepCopy() *MapBuildStageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MapBuildStageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MapBuilderSpec) DeepCopyInto(out *MapBuilderSpec) {
 	*out = *in
@@ -34,159 +238,791 @@ func (in *MapBuilderSpec) DeepCopyInto(out *MapBuilderSpec) {
 		*out = new(string)
 		**out = **in
 	}
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = new(v1.ResourceRequirements)
-		(*in).DeepCopyInto(*out)
+	if in.ExtractOptions != nil {
+		in, out := &in.ExtractOptions, &out.ExtractOptions
+		*out = new(string)
+		**out = **in
+	}
+	if in.PartitionOptions != nil {
+		in, out := &in.PartitionOptions, &out.PartitionOptions
+		*out = new(string)
+		**out = **in
+	}
+	if in.CustomizeOptions != nil {
+		in, out := &in.CustomizeOptions, &out.CustomizeOptions
+		*out = new(string)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SharedDownload != nil {
+		in, out := &in.SharedDownload, &out.SharedDownload
+		*out = new(SharedDownloadSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = new(MapBuilderStagesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MapBuilderSpec.
+func (in *MapBuilderSpec) DeepCopy() *MapBuilderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MapBuilderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MapBuilderStageSpec) DeepCopyInto(out *MapBuilderStageSpec) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MapBuilderStageSpec.
+func (in *MapBuilderStageSpec) DeepCopy() *MapBuilderStageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MapBuilderStageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MapBuilderStagesSpec) DeepCopyInto(out *MapBuilderStagesSpec) {
+	*out = *in
+	if in.Extract != nil {
+		in, out := &in.Extract, &out.Extract
+		*out = new(MapBuilderStageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Partition != nil {
+		in, out := &in.Partition, &out.Partition
+		*out = new(MapBuilderStageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Customize != nil {
+		in, out := &in.Customize, &out.Customize
+		*out = new(MapBuilderStageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MapBuilderStagesSpec.
+func (in *MapBuilderStagesSpec) DeepCopy() *MapBuilderStagesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MapBuilderStagesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MapInfo) DeepCopyInto(out *MapInfo) {
+	*out = *in
+	if in.StageDurationSeconds != nil {
+		in, out := &in.StageDurationSeconds, &out.StageDurationSeconds
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.BuildTime != nil {
+		in, out := &in.BuildTime, &out.BuildTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MapInfo.
+func (in *MapInfo) DeepCopy() *MapInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(MapInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MapSourceSpec) DeepCopyInto(out *MapSourceSpec) {
+	*out = *in
+	if in.Prebuilt != nil {
+		in, out := &in.Prebuilt, &out.Prebuilt
+		*out = new(PrebuiltMapSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MapSourceSpec.
+func (in *MapSourceSpec) DeepCopy() *MapSourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MapSourceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringSpec) DeepCopyInto(out *MonitoringSpec) {
+	*out = *in
+	if in.Kind != nil {
+		in, out := &in.Kind, &out.Kind
+		*out = new(MonitorKind)
+		**out = **in
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(string)
+		**out = **in
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Exporter != nil {
+		in, out := &in.Exporter, &out.Exporter
+		*out = new(ExporterSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringSpec.
+func (in *MonitoringSpec) DeepCopy() *MonitoringSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSRMCluster) DeepCopyInto(out *OSRMCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMCluster.
+func (in *OSRMCluster) DeepCopy() *OSRMCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(OSRMCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OSRMCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSRMClusterList) DeepCopyInto(out *OSRMClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OSRMCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMClusterList.
+func (in *OSRMClusterList) DeepCopy() *OSRMClusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(OSRMClusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OSRMClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSRMClusterSpec) DeepCopyInto(out *OSRMClusterSpec) {
+	*out = *in
+	if in.Profiles != nil {
+		in, out := &in.Profiles, &out.Profiles
+		*out = make(ProfilesSpec, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ProfileSpec)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	in.Service.DeepCopyInto(&out.Service)
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+	if in.OSRMVersion != nil {
+		in, out := &in.OSRMVersion, &out.OSRMVersion
+		*out = new(string)
+		**out = **in
+	}
+	if in.OSRMVersionUpgradePolicy != nil {
+		in, out := &in.OSRMVersionUpgradePolicy, &out.OSRMVersionUpgradePolicy
+		*out = new(OSRMVersionUpgradePolicy)
+		**out = **in
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanarySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Persistence.DeepCopyInto(&out.Persistence)
+	in.MapBuilder.DeepCopyInto(&out.MapBuilder)
+	if in.ObjectStorage != nil {
+		in, out := &in.ObjectStorage, &out.ObjectStorage
+		*out = new(ObjectStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MapSource != nil {
+		in, out := &in.MapSource, &out.MapSource
+		*out = new(MapSourceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(ProbesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Monitoring != nil {
+		in, out := &in.Monitoring, &out.Monitoring
+		*out = new(MonitoringSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthChecks != nil {
+		in, out := &in.HealthChecks, &out.HealthChecks
+		*out = make([]HealthCheckSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HealthCheckInterval != nil {
+		in, out := &in.HealthCheckInterval, &out.HealthCheckInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Tracing != nil {
+		in, out := &in.Tracing, &out.Tracing
+		*out = new(TracingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DriftPolicy != nil {
+		in, out := &in.DriftPolicy, &out.DriftPolicy
+		*out = new(DriftPolicy)
+		**out = **in
+	}
+	if in.ResyncInterval != nil {
+		in, out := &in.ResyncInterval, &out.ResyncInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMClusterSpec.
+func (in *OSRMClusterSpec) DeepCopy() *OSRMClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OSRMClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSRMClusterStatus) DeepCopyInto(out *OSRMClusterStatus) {
+	*out = *in
+	if in.OSRMUpgrade != nil {
+		in, out := &in.OSRMUpgrade, &out.OSRMUpgrade
+		*out = new(OSRMUpgradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Switchovers != nil {
+		in, out := &in.Switchovers, &out.Switchovers
+		*out = make([]SwitchoverStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Download != nil {
+		in, out := &in.Download, &out.Download
+		*out = new(DownloadStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Profiles != nil {
+		in, out := &in.Profiles, &out.Profiles
+		*out = make([]ProfileStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HealthChecks != nil {
+		in, out := &in.HealthChecks, &out.HealthChecks
+		*out = make([]HealthCheckStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DriftedResources != nil {
+		in, out := &in.DriftedResources, &out.DriftedResources
+		*out = make([]DriftedResourceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMClusterStatus.
+func (in *OSRMClusterStatus) DeepCopy() *OSRMClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OSRMClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSRMRoutedSpec) DeepCopyInto(out *OSRMRoutedSpec) {
+	*out = *in
+	if in.MaxTableSize != nil {
+		in, out := &in.MaxTableSize, &out.MaxTableSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxViarouteSize != nil {
+		in, out := &in.MaxViarouteSize, &out.MaxViarouteSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxTripSize != nil {
+		in, out := &in.MaxTripSize, &out.MaxTripSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxMatchingSize != nil {
+		in, out := &in.MaxMatchingSize, &out.MaxMatchingSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxNearestSize != nil {
+		in, out := &in.MaxNearestSize, &out.MaxNearestSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxAlternatives != nil {
+		in, out := &in.MaxAlternatives, &out.MaxAlternatives
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxMatchingRadius != nil {
+		in, out := &in.MaxMatchingRadius, &out.MaxMatchingRadius
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Threads != nil {
+		in, out := &in.Threads, &out.Threads
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MMap != nil {
+		in, out := &in.MMap, &out.MMap
+		*out = new(bool)
+		**out = **in
+	}
+	if in.KeepaliveTimeout != nil {
+		in, out := &in.KeepaliveTimeout, &out.KeepaliveTimeout
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ExtraArgs != nil {
+		in, out := &in.ExtraArgs, &out.ExtraArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMRoutedSpec.
+func (in *OSRMRoutedSpec) DeepCopy() *OSRMRoutedSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OSRMRoutedSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSRMUpgradeStatus) DeepCopyInto(out *OSRMUpgradeStatus) {
+	*out = *in
+	if in.RoutingStartTime != nil {
+		in, out := &in.RoutingStartTime, &out.RoutingStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.AnalysisStartTime != nil {
+		in, out := &in.AnalysisStartTime, &out.AnalysisStartTime
+		*out = (*in).DeepCopy()
+	}
+	out.Stable = in.Stable
+	out.Canary = in.Canary
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMUpgradeStatus.
+func (in *OSRMUpgradeStatus) DeepCopy() *OSRMUpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OSRMUpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectStorageSpec) DeepCopyInto(out *ObjectStorageSpec) {
+	*out = *in
+	if in.Endpoint != nil {
+		in, out := &in.Endpoint, &out.Endpoint
+		*out = new(string)
+		**out = **in
+	}
+	if in.Prefix != nil {
+		in, out := &in.Prefix, &out.Prefix
+		*out = new(string)
+		**out = **in
+	}
+	if in.Region != nil {
+		in, out := &in.Region, &out.Region
+		*out = new(string)
+		**out = **in
+	}
+	if in.CredentialsSecretName != nil {
+		in, out := &in.CredentialsSecretName, &out.CredentialsSecretName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectStorageSpec.
+func (in *ObjectStorageSpec) DeepCopy() *ObjectStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnDemandRequest) DeepCopyInto(out *OnDemandRequest) {
+	*out = *in
+	if in.Profiles != nil {
+		in, out := &in.Profiles, &out.Profiles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnDemandRequest.
+func (in *OnDemandRequest) DeepCopy() *OnDemandRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(OnDemandRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PersistenceSpec) DeepCopyInto(out *PersistenceSpec) {
+	*out = *in
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.AccessMode != nil {
+		in, out := &in.AccessMode, &out.AccessMode
+		*out = new(corev1.PersistentVolumeAccessMode)
+		**out = **in
+	}
+	if in.WorkerStorage != nil {
+		in, out := &in.WorkerStorage, &out.WorkerStorage
+		*out = new(WorkerStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReclaimPolicy != nil {
+		in, out := &in.ReclaimPolicy, &out.ReclaimPolicy
+		*out = new(ReclaimPolicy)
+		**out = **in
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = new(SnapshotsSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RestoreFrom != nil {
+		in, out := &in.RestoreFrom, &out.RestoreFrom
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PersistenceSpec.
+func (in *PersistenceSpec) DeepCopy() *PersistenceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PersistenceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrebuiltMapSpec) DeepCopyInto(out *PrebuiltMapSpec) {
+	*out = *in
+	if in.URL != nil {
+		in, out := &in.URL, &out.URL
+		*out = new(string)
+		**out = **in
+	}
+	if in.ObjectStorage != nil {
+		in, out := &in.ObjectStorage, &out.ObjectStorage
+		*out = new(ObjectStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PersistentVolumeClaim != nil {
+		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
+		*out = new(PrebuiltVolumeSource)
+		**out = **in
+	}
+	if in.Checksums != nil {
+		in, out := &in.Checksums, &out.Checksums
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Algorithm != nil {
+		in, out := &in.Algorithm, &out.Algorithm
+		*out = new(string)
+		**out = **in
+	}
+	if in.OSRMFileName != nil {
+		in, out := &in.OSRMFileName, &out.OSRMFileName
+		*out = new(string)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MapBuilderSpec.
-func (in *MapBuilderSpec) DeepCopy() *MapBuilderSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrebuiltMapSpec.
+func (in *PrebuiltMapSpec) DeepCopy() *PrebuiltMapSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MapBuilderSpec)
+	out := new(PrebuiltMapSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OSRMCluster) DeepCopyInto(out *OSRMCluster) {
+func (in *PrebuiltVolumeSource) DeepCopyInto(out *PrebuiltVolumeSource) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMCluster.
-func (in *OSRMCluster) DeepCopy() *OSRMCluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrebuiltVolumeSource.
+func (in *PrebuiltVolumeSource) DeepCopy() *PrebuiltVolumeSource {
 	if in == nil {
 		return nil
 	}
-	out := new(OSRMCluster)
+	out := new(PrebuiltVolumeSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *OSRMCluster) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OSRMClusterList) DeepCopyInto(out *OSRMClusterList) {
+func (in *ProbesSpec) DeepCopyInto(out *ProbesSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]OSRMCluster, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Disabled != nil {
+		in, out := &in.Disabled, &out.Disabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Coordinate != nil {
+		in, out := &in.Coordinate, &out.Coordinate
+		*out = new(string)
+		**out = **in
+	}
+	if in.Startup != nil {
+		in, out := &in.Startup, &out.Startup
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMClusterList.
-func (in *OSRMClusterList) DeepCopy() *OSRMClusterList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbesSpec.
+func (in *ProbesSpec) DeepCopy() *ProbesSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(OSRMClusterList)
+	out := new(ProbesSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *OSRMClusterList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OSRMClusterSpec) DeepCopyInto(out *OSRMClusterSpec) {
+func (in *ProfileMapBuilderSpec) DeepCopyInto(out *ProfileMapBuilderSpec) {
 	*out = *in
-	if in.Profiles != nil {
-		in, out := &in.Profiles, &out.Profiles
-		*out = make(ProfilesSpec, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(ProfileSpec)
-				(*in).DeepCopyInto(*out)
-			}
-		}
-	}
-	in.Service.DeepCopyInto(&out.Service)
 	if in.Image != nil {
 		in, out := &in.Image, &out.Image
 		*out = new(string)
 		**out = **in
 	}
-	in.Persistence.DeepCopyInto(&out.Persistence)
-	in.MapBuilder.DeepCopyInto(&out.MapBuilder)
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMClusterSpec.
-func (in *OSRMClusterSpec) DeepCopy() *OSRMClusterSpec {
-	if in == nil {
-		return nil
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
 	}
-	out := new(OSRMClusterSpec)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OSRMClusterStatus) DeepCopyInto(out *OSRMClusterStatus) {
-	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]metav1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = new(MapBuilderStagesSpec)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSRMClusterStatus.
-func (in *OSRMClusterStatus) DeepCopy() *OSRMClusterStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileMapBuilderSpec.
+func (in *ProfileMapBuilderSpec) DeepCopy() *ProfileMapBuilderSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(OSRMClusterStatus)
+	out := new(ProfileMapBuilderSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PersistenceSpec) DeepCopyInto(out *PersistenceSpec) {
+func (in *ProfilePersistenceSpec) DeepCopyInto(out *ProfilePersistenceSpec) {
 	*out = *in
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
 	if in.Storage != nil {
 		in, out := &in.Storage, &out.Storage
 		x := (*in).DeepCopy()
 		*out = &x
 	}
-	if in.AccessMode != nil {
-		in, out := &in.AccessMode, &out.AccessMode
-		*out = new(v1.PersistentVolumeAccessMode)
-		**out = **in
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PersistenceSpec.
-func (in *PersistenceSpec) DeepCopy() *PersistenceSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfilePersistenceSpec.
+func (in *ProfilePersistenceSpec) DeepCopy() *ProfilePersistenceSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PersistenceSpec)
+	out := new(ProfilePersistenceSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -221,7 +1057,7 @@ func (in *ProfileSpec) DeepCopyInto(out *ProfileSpec) {
 	}
 	if in.Resources != nil {
 		in, out := &in.Resources, &out.Resources
-		*out = new(v1.ResourceRequirements)
+		*out = new(corev1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.SpeedUpdates != nil {
@@ -229,6 +1065,26 @@ func (in *ProfileSpec) DeepCopyInto(out *ProfileSpec) {
 		*out = new(SpeedUpdatesSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Persistence != nil {
+		in, out := &in.Persistence, &out.Persistence
+		*out = new(ProfilePersistenceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MapBuilder != nil {
+		in, out := &in.MapBuilder, &out.MapBuilder
+		*out = new(ProfileMapBuilderSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Routed != nil {
+		in, out := &in.Routed, &out.Routed
+		*out = new(OSRMRoutedSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(ProbesSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileSpec.
@@ -241,6 +1097,37 @@ func (in *ProfileSpec) DeepCopy() *ProfileSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileStatus) DeepCopyInto(out *ProfileStatus) {
+	*out = *in
+	if in.MapBuild != nil {
+		in, out := &in.MapBuild, &out.MapBuild
+		*out = make([]MapBuildStageStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MapInfo != nil {
+		in, out := &in.MapInfo, &out.MapInfo
+		*out = new(MapInfo)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SpeedUpdateTime != nil {
+		in, out := &in.SpeedUpdateTime, &out.SpeedUpdateTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileStatus.
+func (in *ProfileStatus) DeepCopy() *ProfileStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in ProfilesSpec) DeepCopyInto(out *ProfilesSpec) {
 	{
@@ -266,12 +1153,42 @@ func (in ProfilesSpec) DeepCopy() ProfilesSpec {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutSpec) DeepCopyInto(out *RolloutSpec) {
+	*out = *in
+	if in.Strategy != nil {
+		in, out := &in.Strategy, &out.Strategy
+		*out = new(RolloutStrategy)
+		**out = **in
+	}
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.StepInterval != nil {
+		in, out := &in.StepInterval, &out.StepInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutSpec.
+func (in *RolloutSpec) DeepCopy() *RolloutSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceSpec) DeepCopyInto(out *ServiceSpec) {
 	*out = *in
 	if in.Type != nil {
 		in, out := &in.Type, &out.Type
-		*out = new(v1.ServiceType)
+		*out = new(corev1.ServiceType)
 		**out = **in
 	}
 	if in.Annotations != nil {
@@ -303,6 +1220,61 @@ func (in *ServiceSpec) DeepCopy() *ServiceSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedDownloadSpec) DeepCopyInto(out *SharedDownloadSpec) {
+	*out = *in
+	if in.Checksum != nil {
+		in, out := &in.Checksum, &out.Checksum
+		*out = new(string)
+		**out = **in
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedDownloadSpec.
+func (in *SharedDownloadSpec) DeepCopy() *SharedDownloadSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedDownloadSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotsSpec) DeepCopyInto(out *SnapshotsSpec) {
+	*out = *in
+	if in.VolumeSnapshotClassName != nil {
+		in, out := &in.VolumeSnapshotClassName, &out.VolumeSnapshotClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Keep != nil {
+		in, out := &in.Keep, &out.Keep
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotsSpec.
+func (in *SnapshotsSpec) DeepCopy() *SnapshotsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SpeedUpdatesSpec) DeepCopyInto(out *SpeedUpdatesSpec) {
 	*out = *in
@@ -318,12 +1290,12 @@ func (in *SpeedUpdatesSpec) DeepCopyInto(out *SpeedUpdatesSpec) {
 	}
 	if in.Resources != nil {
 		in, out := &in.Resources, &out.Resources
-		*out = new(v1.ResourceRequirements)
+		*out = new(corev1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
 	if in.Env != nil {
 		in, out := &in.Env, &out.Env
-		*out = make([]v1.EnvVar, len(*in))
+		*out = make([]corev1.EnvVar, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -339,3 +1311,91 @@ func (in *SpeedUpdatesSpec) DeepCopy() *SpeedUpdatesSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SwitchoverStatus) DeepCopyInto(out *SwitchoverStatus) {
+	*out = *in
+	if in.MapBuildTime != nil {
+		in, out := &in.MapBuildTime, &out.MapBuildTime
+		*out = (*in).DeepCopy()
+	}
+	if in.StepStartTime != nil {
+		in, out := &in.StepStartTime, &out.StepStartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SwitchoverStatus.
+func (in *SwitchoverStatus) DeepCopy() *SwitchoverStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SwitchoverStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TracingSpec) DeepCopyInto(out *TracingSpec) {
+	*out = *in
+	if in.ServiceName != nil {
+		in, out := &in.ServiceName, &out.ServiceName
+		*out = new(string)
+		**out = **in
+	}
+	if in.SamplingPercentage != nil {
+		in, out := &in.SamplingPercentage, &out.SamplingPercentage
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TracingSpec.
+func (in *TracingSpec) DeepCopy() *TracingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TracingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficStats) DeepCopyInto(out *TrafficStats) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficStats.
+func (in *TrafficStats) DeepCopy() *TrafficStats {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficStats)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerStorageSpec) DeepCopyInto(out *WorkerStorageSpec) {
+	*out = *in
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerStorageSpec.
+func (in *WorkerStorageSpec) DeepCopy() *WorkerStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metrics"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// apply reconciles a child resource with a server-side apply of the fields its
+// builder sets, as the field manager of the operator. The fields set by others,
+// e.g. the replicas set by an autoscaler or the annotations of other tools, are
+// left as they are. The fields of the operator that were changed by others are
+// reported, and reverted unless the drift policy is Report, which leaves them to
+// the managers that changed them, applies the rest and keeps them in the status
+// until the child resource is applied without conflicts. The mutate function
+// is called on the new object, and on a copy of the existing one, which the
+// builders read their state from.
+func (r *OSRMClusterReconciler) apply(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	object client.Object,
+	mutate func(client.Object) error,
+) (controllerutil.OperationResult, error) {
+	gvk, err := apiutil.GVKForObject(object, r.Scheme)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	live := object.DeepCopyObject().(client.Object)
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(object), live); err != nil {
+		if !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		}
+		live = nil
+	}
+	if live != nil {
+		if err := r.upgradeManagedFields(ctx, live); err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+	}
+
+	if err := mutate(object); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	updated := object
+	var managedFields []metav1.ManagedFieldsEntry
+	if live != nil {
+		object.SetResourceVersion(live.GetResourceVersion())
+		updated = live.DeepCopyObject().(client.Object)
+		if err := mutate(updated); err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		managedFields = live.GetManagedFields()
+	}
+
+	configuration, err := resource.ApplyConfiguration(gvk, object, updated, managedFields)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	// The apply does not take the fields that were changed by others, and fails
+	// with a conflict for each of them instead.
+	err = r.Client.Patch(ctx, configuration, client.Apply, client.FieldOwner(resource.FieldManager))
+	drifted := false
+	if drift, conflicts := resource.DriftOf(err); conflicts {
+		r.recordDrift(ctx, instance, object, drift)
+		drifted = instance.Spec.GetDriftPolicy() == osrmv1alpha1.DriftPolicyReport
+		if drifted {
+			if err := resource.RemoveDrift(configuration, drift); err != nil {
+				return controllerutil.OperationResultNone, err
+			}
+			err = r.Client.Patch(ctx, configuration, client.Apply, client.FieldOwner(resource.FieldManager))
+		} else {
+			err = r.Client.Patch(ctx, configuration, client.Apply, client.FieldOwner(resource.FieldManager), client.ForceOwnership)
+		}
+	}
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	object.SetResourceVersion(configuration.GetResourceVersion())
+	if !drifted {
+		instance.Status.RemoveDriftedResource(r.kindOf(object), object.GetName())
+	}
+
+	switch {
+	case live == nil:
+		return controllerutil.OperationResultCreated, nil
+	case configuration.GetResourceVersion() != live.GetResourceVersion():
+		return controllerutil.OperationResultUpdated, nil
+	}
+	return controllerutil.OperationResultNone, nil
+}
+
+// upgradeManagedFields moves the fields the operator updated on a child resource
+// before it used server-side apply to its field manager, once. The managed fields
+// are patched only if the child resource was not changed since it was read.
+func (r *OSRMClusterReconciler) upgradeManagedFields(ctx context.Context, live client.Object) error {
+	original := live.DeepCopyObject().(client.Object)
+	upgraded, err := resource.UpgradeManagedFields(live)
+	if err != nil || !upgraded {
+		return err
+	}
+	if err := r.Client.Patch(ctx, live, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); err != nil {
+		return err
+	}
+	ctrl.LoggerFrom(ctx).V(debugLevel).Info("Upgraded the managed fields of the updates made before server-side apply",
+		"kind", r.kindOf(live), "name", live.GetName(), "manager", resource.LegacyFieldManager)
+	return nil
+}
+
+// recordDrift reports the fields of a child resource that were changed by others.
+// With the Report policy, the drift is kept in the status and reported once, until
+// its fields change.
+func (r *OSRMClusterReconciler) recordDrift(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	object client.Object,
+	drift []resource.Drift,
+) {
+	policy := instance.Spec.GetDriftPolicy()
+	fields := make([]string, len(drift))
+	for i := range drift {
+		fields[i] = drift[i].String()
+	}
+	sort.Strings(fields)
+
+	if policy == osrmv1alpha1.DriftPolicyReport {
+		recorded := instance.Status.GetDriftedResource(r.kindOf(object), object.GetName())
+		reported := recorded != nil && slices.Equal(recorded.Fields, fields)
+		instance.Status.SetDriftedResource(osrmv1alpha1.DriftedResourceStatus{
+			Kind:   r.kindOf(object),
+			Name:   object.GetName(),
+			Fields: fields,
+		})
+		if reported {
+			return
+		}
+	}
+
+	action := "reverting them"
+	if policy == osrmv1alpha1.DriftPolicyReport {
+		action = "leaving them as they are with the Report drift policy"
+	}
+	ctrl.LoggerFrom(ctx).Info("Child resource drifted", "kind", r.kindOf(object), "name", object.GetName(), "fields", fields, "driftPolicy", policy)
+	metrics.RecordChildDrift(instance, r.kindOf(object), policy)
+	r.recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonDriftDetected,
+		"Fields of %s %s were changed by others, %s: %s", r.kindOf(object), object.GetName(), action, strings.Join(fields, ", "))
+}
+
+// driftedCondition forgets the drift of the child resources that no longer exist,
+// and reports those that are left as they are with the Report drift policy.
+func (r *OSRMClusterReconciler) driftedCondition(
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) metav1.Condition {
+	children := map[string]bool{}
+	for _, child := range childResources.Objects() {
+		if object, ok := child.(client.Object); ok {
+			children[r.kindOf(object)+"/"+object.GetName()] = true
+		}
+	}
+	instance.Status.DriftedResources = slices.DeleteFunc(instance.Status.DriftedResources, func(drifted osrmv1alpha1.DriftedResourceStatus) bool {
+		return !children[drifted.Kind+"/"+drifted.Name]
+	})
+
+	if len(instance.Status.DriftedResources) == 0 {
+		return metav1.Condition{
+			Type:    status.ConditionDrifted,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoDrift",
+			Message: "No child resource is left drifted",
+		}
+	}
+
+	drifted := make([]string, len(instance.Status.DriftedResources))
+	for i, resource := range instance.Status.DriftedResources {
+		drifted[i] = fmt.Sprintf("%s %s", resource.Kind, resource.Name)
+	}
+	return metav1.Condition{
+		Type:    status.ConditionDrifted,
+		Status:  metav1.ConditionTrue,
+		Reason:  "DriftReported",
+		Message: fmt.Sprintf("Child resources are left as they are with the Report drift policy: %s", strings.Join(drifted, ", ")),
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons of the events recorded on an OSRMCluster.
+const (
+	EventReasonInitialized          = "Initialized"
+	EventReasonCreated              = "Created"
+	EventReasonUpdated              = "Updated"
+	EventReasonDeleted              = "Deleted"
+	EventReasonCreateFailed         = "CreateFailed"
+	EventReasonUpdateFailed         = "UpdateFailed"
+	EventReasonGarbageCollected     = "GarbageCollected"
+	EventReasonMapBuildStarted      = "MapBuildStarted"
+	EventReasonMapBuildCompleted    = "MapBuildCompleted"
+	EventReasonMapBuildFailed       = "MapBuildFailed"
+	EventReasonMapRebuild           = "MapRebuildRequested"
+	EventReasonMapBuildRetry        = "MapBuildRetryRequested"
+	EventReasonSpeedUpdate          = "SpeedUpdateRequested"
+	EventReasonSpeedUpdateApplied   = "SpeedUpdateApplied"
+	EventReasonPaused               = "Paused"
+	EventReasonResumed              = "Resumed"
+	EventReasonCleanup              = "Cleanup"
+	EventReasonHealthCheckFailed    = "HealthCheckFailed"
+	EventReasonHealthCheckRecovered = "HealthCheckRecovered"
+	EventReasonRoutingRegression    = "RoutingRegression"
+	EventReasonDriftDetected        = "DriftDetected"
+)
+
+// recordMapBuildEvents records the map build stages that started, completed or
+// failed since the previous status of the OSRMCluster.
+func (r *OSRMClusterReconciler) recordMapBuildEvents(
+	instance *osrmv1alpha1.OSRMCluster,
+	oldProfileStatuses []osrmv1alpha1.ProfileStatus,
+) {
+	for _, profileStatus := range instance.Status.Profiles {
+		for _, stage := range profileStatus.MapBuild {
+			if mapBuildPhase(oldProfileStatuses, profileStatus.Name, stage.Stage) == stage.Phase {
+				continue
+			}
+
+			switch stage.Phase {
+			case osrmv1alpha1.JobPhaseRunning:
+				r.recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonMapBuildStarted,
+					"Started the %s stage of the map build of profile %s", stage.Stage, profileStatus.Name)
+			case osrmv1alpha1.JobPhaseCompleted:
+				r.recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonMapBuildCompleted,
+					"Completed the %s stage of the map build of profile %s", stage.Stage, profileStatus.Name)
+			case osrmv1alpha1.JobPhaseFailed:
+				r.recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonMapBuildFailed,
+					"The %s stage of the map build of profile %s failed, see Job %s", stage.Stage, profileStatus.Name, stage.Job)
+			}
+		}
+	}
+}
+
+func mapBuildPhase(profileStatuses []osrmv1alpha1.ProfileStatus, profile, stage string) osrmv1alpha1.JobPhase {
+	for _, profileStatus := range profileStatuses {
+		if profileStatus.Name != profile {
+			continue
+		}
+		for _, stageStatus := range profileStatus.MapBuild {
+			if stageStatus.Stage == stage {
+				return stageStatus.Phase
+			}
+		}
+	}
+	return ""
+}
+
+// recordSpeedUpdateEvents records the speed updates that completed since the
+// previous status of the OSRMCluster, which rolls them out to the workers.
+func (r *OSRMClusterReconciler) recordSpeedUpdateEvents(
+	instance *osrmv1alpha1.OSRMCluster,
+	oldProfileStatuses []osrmv1alpha1.ProfileStatus,
+) {
+	for _, profileStatus := range instance.Status.Profiles {
+		updateTime := profileStatus.SpeedUpdateTime
+		if updateTime == nil || updateTime.Equal(speedUpdateTime(oldProfileStatuses, profileStatus.Name)) {
+			continue
+		}
+		r.recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonSpeedUpdateApplied,
+			"Rolling out the speed update of profile %s completed at %s", profileStatus.Name, updateTime.Format(time.RFC3339))
+	}
+}
+
+func speedUpdateTime(profileStatuses []osrmv1alpha1.ProfileStatus, profile string) *metav1.Time {
+	for _, profileStatus := range profileStatuses {
+		if profileStatus.Name == profile {
+			return profileStatus.SpeedUpdateTime
+		}
+	}
+	return nil
+}
+
+func requestedProfiles(request *osrmv1alpha1.OnDemandRequest) string {
+	if len(request.Profiles) == 0 {
+		return "all profiles"
+	}
+	return fmt.Sprintf("profiles %s", strings.Join(request.Profiles, ", "))
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metrics"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// maxHealthCheckAnswerSize limits the answers read from the gateway.
+const maxHealthCheckAnswerSize = 1 << 20
+
+// healthChecksTimeout bounds the time the health checks of an OSRMCluster take together.
+const healthChecksTimeout = 30 * time.Second
+
+var healthCheckClient = &http.Client{Timeout: 10 * time.Second}
+
+// reconcileHealthChecks runs the health checks of an OSRMCluster through the
+// gateway once their interval has passed, and reports their results in the
+// status, the RoutingHealthy condition and the metrics. It returns when the
+// health checks run next.
+func (r *OSRMClusterReconciler) reconcileHealthChecks(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) time.Duration {
+	healthChecks := instance.Spec.HealthChecks
+	r.forgetHealthChecks(instance)
+	if len(healthChecks) == 0 {
+		meta.RemoveStatusCondition(&instance.Status.Conditions, status.ConditionRoutingHealthy)
+		return 0
+	}
+
+	interval := instance.Spec.GetHealthCheckInterval()
+	if remaining := interval - r.timeSinceHealthChecks(instance); remaining > 0 {
+		return remaining
+	}
+
+	gateway := status.GetDeployment(instance.ChildResourceName(resource.GatewaySuffix, resource.DeploymentSuffix), childResources.Of(resource.GatewayKey))
+	if gateway == nil || gateway.Status.AvailableReplicas == 0 {
+		instance.Status.SetCondition(metav1.Condition{
+			Type:    status.ConditionRoutingHealthy,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "GatewayUnavailable",
+			Message: "The health checks run once the gateway is available",
+		})
+		return interval
+	}
+
+	// The health checks run together, so a slow gateway delays the reconciliation
+	// by the deadline at most.
+	checkCtx, cancel := context.WithTimeout(ctx, healthChecksTimeout)
+	defer cancel()
+	results := make([]osrmv1alpha1.HealthCheckStatus, len(healthChecks))
+	var wg sync.WaitGroup
+	for i := range healthChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.runHealthCheck(checkCtx, instance, &healthChecks[i])
+		}()
+	}
+	wg.Wait()
+
+	for i := range results {
+		result := &results[i]
+		result.MapBuildTime, result.SpeedUpdateTime = resource.ServedMapData(instance, result.Profile, childResources)
+		previous := instance.Status.GetHealthCheck(result.Name)
+		resource.DetectRegression(result, previous)
+		metrics.SetHealthCheck(instance, result)
+	}
+	instance.Status.HealthChecks = results
+	instance.Status.SetCondition(resource.RoutingHealthyCondition(results))
+	return interval
+}
+
+// runHealthCheck sends the query of a health check to the gateway and verifies its answer.
+func (r *OSRMClusterReconciler) runHealthCheck(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	check *osrmv1alpha1.HealthCheckSpec,
+) osrmv1alpha1.HealthCheckStatus {
+	result := osrmv1alpha1.HealthCheckStatus{
+		Name:          check.Name,
+		Profile:       check.Profile,
+		LastCheckTime: &metav1.Time{Time: time.Now()},
+	}
+
+	url, err := resource.HealthCheckURL(instance, check)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	body, err := fetchHealthCheckAnswer(ctx, url)
+	result.Latency = &metav1.Duration{Duration: time.Since(start)}
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	answer, err := resource.ParseHealthCheckAnswer(check, body)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	if answer.Duration != nil {
+		result.Duration = &metav1.Duration{Duration: *answer.Duration}
+	}
+	if answer.Distance != nil {
+		distance := int32(math.Round(*answer.Distance))
+		result.Distance = &distance
+	}
+	if err := answer.Verify(check); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	result.Healthy = true
+	return result
+}
+
+func fetchHealthCheckAnswer(ctx context.Context, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The spans of the gateway continue the trace of the reconciliation.
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+	response, err := healthCheckClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxHealthCheckAnswerSize))
+	if err != nil {
+		return nil, err
+	}
+	// OSRM answers its errors with a 400 status and their code in the body.
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusBadRequest {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return body, nil
+}
+
+// recordHealthCheckEvents records the health checks that started failing,
+// regressed since a map build or a speed update, or passed again since their
+// previous results.
+func (r *OSRMClusterReconciler) recordHealthCheckEvents(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	previousResults []osrmv1alpha1.HealthCheckStatus,
+) {
+	for _, result := range instance.Status.HealthChecks {
+		var previous *osrmv1alpha1.HealthCheckStatus
+		for i := range previousResults {
+			if previousResults[i].Name == result.Name {
+				previous = &previousResults[i]
+			}
+		}
+
+		switch {
+		case previous == nil || result.Healthy == previous.Healthy:
+		case result.Healthy:
+			r.recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonHealthCheckRecovered,
+				"Health check %s passed again", result.Name)
+		case result.Regression != "":
+			ctrl.LoggerFrom(ctx).Info("Health check regressed", "profile", result.Profile, "healthCheck", result.Name, "after", result.Regression, "message", result.Message)
+			r.recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonRoutingRegression,
+				"Health check %s fails since the %s of profile %s: %s", result.Name, resource.RegressionCause(result.Regression), result.Profile, result.Message)
+		default:
+			r.recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonHealthCheckFailed,
+				"Health check %s failed: %s", result.Name, result.Message)
+		}
+	}
+}
+
+// timeSinceHealthChecks returns the time since the health checks last ran, or
+// the longest duration when one of them never ran.
+func (r *OSRMClusterReconciler) timeSinceHealthChecks(instance *osrmv1alpha1.OSRMCluster) time.Duration {
+	var elapsed time.Duration
+	for _, check := range instance.Spec.HealthChecks {
+		previous := instance.Status.GetHealthCheck(check.Name)
+		if previous == nil || previous.LastCheckTime == nil {
+			return math.MaxInt64
+		}
+		if since := time.Since(previous.LastCheckTime.Time); since > elapsed {
+			elapsed = since
+		}
+	}
+	return elapsed
+}
+
+// forgetHealthChecks removes the results of health checks that were removed from the spec.
+func (r *OSRMClusterReconciler) forgetHealthChecks(instance *osrmv1alpha1.OSRMCluster) {
+	results := []osrmv1alpha1.HealthCheckStatus{}
+	for _, result := range instance.Status.HealthChecks {
+		if isHealthCheckSpecified(instance, result.Name) {
+			results = append(results, result)
+		} else {
+			metrics.DeleteHealthCheck(instance, result.Name)
+		}
+	}
+	instance.Status.HealthChecks = results
+}
+
+func isHealthCheckSpecified(instance *osrmv1alpha1.OSRMCluster, name string) bool {
+	for _, check := range instance.Spec.HealthChecks {
+		if check.Name == name {
+			return true
+		}
+	}
+	return false
+}
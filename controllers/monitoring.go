@@ -0,0 +1,176 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var monitorKinds = []osrmv1alpha1.MonitorKind{
+	osrmv1alpha1.MonitorKindPodMonitor,
+	osrmv1alpha1.MonitorKindServiceMonitor,
+}
+
+// DetectMonitorKinds returns the Prometheus Operator monitors served by the API
+// server. The operator only creates monitors whose CRDs are installed when it starts.
+func DetectMonitorKinds(config *rest.Config) (map[osrmv1alpha1.MonitorKind]bool, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := map[osrmv1alpha1.MonitorKind]bool{}
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(schema.GroupVersion{
+		Group:   resource.MonitoringAPIGroup,
+		Version: resource.MonitoringAPIVersion,
+	}.String())
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return kinds, nil
+		}
+		return nil, err
+	}
+
+	for _, apiResource := range resources.APIResources {
+		for _, kind := range monitorKinds {
+			if apiResource.Kind == string(kind) {
+				kinds[kind] = true
+			}
+		}
+	}
+	return kinds, nil
+}
+
+// getMonitors lists the monitors of an OSRMCluster, of the kinds whose CRDs are installed.
+func (r *OSRMClusterReconciler) getMonitors(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) ([]client.Object, error) {
+	monitors := []client.Object{}
+	for _, kind := range monitorKinds {
+		if !r.MonitorKinds[kind] {
+			continue
+		}
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(resource.MonitorGVK(kind).GroupVersion().WithKind(string(kind) + "List"))
+		listMonitors, err := r.listChildResources(ctx, instance, list)
+		if err != nil {
+			return nil, err
+		}
+		monitors = append(monitors, listMonitors...)
+	}
+	return monitors, nil
+}
+
+// reconcileMonitoring creates the monitor chosen by spec.monitoring when its CRD
+// is installed, and deletes the monitoring resources that are no longer used.
+func (r *OSRMClusterReconciler) reconcileMonitoring(
+	ctx context.Context,
+	resourceBuilder *resource.OSRMResourceBuilder,
+	childResources *resource.ChildResources,
+) error {
+	instance := resourceBuilder.Instance
+	monitoring := instance.Spec.Monitoring
+
+	for _, kind := range monitorKinds {
+		if !r.MonitorKinds[kind] {
+			continue
+		}
+
+		monitor := resourceBuilder.Monitor(kind)
+		if monitoring == nil || monitoring.GetKind() != kind {
+			if hasMonitor(kind, childResources.Of(resource.GatewayKey)) {
+				if err := r.deleteChildResource(ctx, instance, monitor); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		operationResult, err := r.apply(ctx, instance, monitor, func(object client.Object) error {
+			return resourceBuilder.UpdateMonitor(object.(*unstructured.Unstructured))
+		})
+		r.logOperationResult(ctrl.LoggerFrom(ctx), instance, monitor, operationResult, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	if monitoring != nil && !r.MonitorKinds[monitoring.GetKind()] {
+		ctrl.LoggerFrom(ctx).Info("The Prometheus Operator CRDs were not found when the operator started, the monitor is not created",
+			"kind", monitoring.GetKind())
+	}
+
+	var unused []client.Object
+	if monitoring == nil {
+		unused = append(unused, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.ChildResourceName(resource.GatewaySuffix, resource.ExporterSuffix),
+			Namespace: instance.Namespace,
+		}})
+	}
+	if monitoring == nil || monitoring.GetKind() != osrmv1alpha1.MonitorKindServiceMonitor {
+		unused = append(unused, &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.ChildResourceName(resource.GatewaySuffix, resource.MetricsServiceSuffix),
+			Namespace: instance.Namespace,
+		}})
+	}
+	for _, object := range unused {
+		if !hasChild(object, childResources.Of(resource.GatewayKey)) {
+			continue
+		}
+		if err := r.deleteChildResource(ctx, instance, object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasMonitor(kind osrmv1alpha1.MonitorKind, childResources []runtime.Object) bool {
+	for _, child := range childResources {
+		if monitor, ok := child.(*unstructured.Unstructured); ok && monitor.GetKind() == string(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasChild returns true when a child resource of the same type and name was fetched.
+func hasChild(object client.Object, childResources []runtime.Object) bool {
+	for _, child := range childResources {
+		switch child := child.(type) {
+		case *corev1.ConfigMap:
+			if _, ok := object.(*corev1.ConfigMap); ok && child.Name == object.GetName() {
+				return true
+			}
+		case *corev1.Service:
+			if _, ok := object.(*corev1.Service); ok && child.Name == object.GetName() {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const canaryAnalysisInterval = 30 * time.Second
+
+var gatewayStatsClient = &http.Client{Timeout: 5 * time.Second}
+
+// reconcileOSRMUpgrade moves a canary upgrade of the OSRM release through its
+// phases: the canary profile's map data is built with the new release into a
+// separate directory, a canary Deployment serves a share of its requests, and
+// the upgrade is then promoted to all profiles or rolled back by comparing the
+// error rates counted by the gateway. It returns when to check the canary again.
+func (r *OSRMClusterReconciler) reconcileOSRMUpgrade(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) (time.Duration, error) {
+	upgrade := instance.Status.OSRMUpgrade
+	osrmVersion := instance.Spec.GetOSRMVersion()
+
+	if err := r.cleanUpCanary(ctx, instance, childResources); err != nil {
+		return 0, err
+	}
+
+	if instance.Spec.GetOSRMVersionUpgradePolicy() != osrmv1alpha1.OSRMVersionUpgradePolicyCanary ||
+		!instance.Spec.HasMapDataVolume() ||
+		instance.Spec.IsPrebuilt() {
+		if upgrade.IsInProgress() {
+			return 0, r.rollBackOSRMUpgrade(ctx, instance, "The Canary upgrade policy is no longer used")
+		}
+		return 0, nil
+	}
+
+	if upgrade.IsInProgress() && upgrade.Version != osrmVersion {
+		if err := r.rollBackOSRMUpgrade(ctx, instance, fmt.Sprintf("Superseded by OSRM %s", osrmVersion)); err != nil {
+			return 0, err
+		}
+		upgrade = instance.Status.OSRMUpgrade
+	}
+
+	if !upgrade.IsInProgress() {
+		if upgrade != nil && upgrade.Version == osrmVersion {
+			return 0, nil
+		}
+		profile := instance.Spec.GetCanaryProfile()
+		fromVersion := resource.MapDataOSRMVersion(instance, profile, childResources)
+		if fromVersion == "" || fromVersion == osrmVersion {
+			return 0, nil
+		}
+
+		ctrl.LoggerFrom(ctx).Info("Starting canary upgrade", "from", fromVersion, "to", osrmVersion, "profile", profile.Name)
+		instance.Status.OSRMUpgrade = &osrmv1alpha1.OSRMUpgradeStatus{
+			Version:     osrmVersion,
+			FromVersion: fromVersion,
+			Profile:     profile.Name,
+			Phase:       osrmv1alpha1.OSRMUpgradePhaseBuilding,
+			Message:     fmt.Sprintf("Building the map data of profile %s with OSRM %s", profile.Name, osrmVersion),
+		}
+		return 0, r.Client.Status().Update(ctx, instance)
+	}
+
+	switch upgrade.Phase {
+	case osrmv1alpha1.OSRMUpgradePhaseBuilding:
+		jobName := instance.ChildResourceName(upgrade.Profile, resource.CanaryJobSuffix)
+		if status.IsJobFailed(jobName, childResources.Of(resource.ProfileKey(upgrade.Profile))) {
+			return 0, r.rollBackOSRMUpgrade(ctx, instance, fmt.Sprintf("The canary map build Job %s failed", jobName))
+		}
+		if status.IsJobCompleted(jobName, childResources.Of(resource.ProfileKey(upgrade.Profile))) {
+			upgrade.Phase = osrmv1alpha1.OSRMUpgradePhaseAnalyzing
+			upgrade.Message = "Waiting for the canary Deployment to become available"
+			return 0, r.Client.Status().Update(ctx, instance)
+		}
+		return 0, nil
+
+	case osrmv1alpha1.OSRMUpgradePhaseAnalyzing:
+		return r.analyzeCanary(ctx, instance, childResources)
+
+	case osrmv1alpha1.OSRMUpgradePhasePromoting:
+		for _, profile := range instance.Spec.Profiles {
+			if resource.MapDataOSRMVersion(instance, profile, childResources) != osrmVersion {
+				return 0, nil
+			}
+		}
+		ctrl.LoggerFrom(ctx).Info("Canary upgrade promoted", "osrmVersion", osrmVersion)
+		upgrade.Phase = osrmv1alpha1.OSRMUpgradePhasePromoted
+		upgrade.Message = fmt.Sprintf("All profiles are served by OSRM %s", osrmVersion)
+		return 0, r.Client.Status().Update(ctx, instance)
+	}
+
+	return 0, nil
+}
+
+// analyzeCanary sends the canary's share of requests to it once it is available,
+// and starts the analysis once every gateway pod was rolled out with that
+// configuration. It records the requests and errors counted by the gateway, and
+// promotes or rolls back the upgrade once the analysis duration has passed. The
+// analysis is extended until the canary served the minimum number of requests,
+// for up to another analysis duration.
+func (r *OSRMClusterReconciler) analyzeCanary(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) (time.Duration, error) {
+	upgrade := instance.Status.OSRMUpgrade
+	canary := instance.Spec.Canary
+
+	if upgrade.RoutingStartTime == nil {
+		deploymentName := instance.ChildResourceName(upgrade.Profile, resource.CanarySuffix)
+		for _, child := range childResources.Of(resource.ProfileKey(upgrade.Profile)) {
+			if deployment, ok := child.(*appsv1.Deployment); ok &&
+				deployment.Name == deploymentName &&
+				deployment.Status.AvailableReplicas > 0 {
+				upgrade.RoutingStartTime = &metav1.Time{Time: time.Now()}
+				upgrade.Message = "Waiting for the gateway to roll out the configuration that sends requests to the canary"
+				return canaryAnalysisInterval, r.Client.Status().Update(ctx, instance)
+			}
+		}
+		return canaryAnalysisInterval, nil
+	}
+
+	if upgrade.AnalysisStartTime == nil {
+		if !resource.IsGatewayRolledOutToCanary(instance, childResources) {
+			return canaryAnalysisInterval, nil
+		}
+		upgrade.AnalysisStartTime = &metav1.Time{Time: time.Now()}
+		upgrade.Message = fmt.Sprintf("The canary serves %d%% of the requests of profile %s", canary.GetWeight(), upgrade.Profile)
+		return canaryAnalysisInterval, r.Client.Status().Update(ctx, instance)
+	}
+
+	elapsed := time.Since(upgrade.AnalysisStartTime.Time)
+	remaining := canary.GetAnalysisDuration() - elapsed
+	if remaining > 0 {
+		if remaining > canaryAnalysisInterval {
+			remaining = canaryAnalysisInterval
+		}
+		return remaining, nil
+	}
+
+	// The counters are kept in the shared memory of each gateway pod, which is
+	// reset when the pod restarts, so a pod that restarted during the analysis
+	// only counts the requests it served since. The error rates are compared on
+	// the requests that were counted, which must reach the minimum number.
+	stable, canaryStats, err := r.getGatewayStats(ctx, instance)
+	if err != nil {
+		return 0, err
+	}
+	upgrade.Stable = stable
+	upgrade.Canary = canaryStats
+
+	if upgrade.Canary.Requests < canary.GetMinRequests() {
+		if elapsed < 2*canary.GetAnalysisDuration() {
+			upgrade.Message = fmt.Sprintf("Extending the analysis until the canary served %d requests, it served %d",
+				canary.GetMinRequests(), upgrade.Canary.Requests)
+			return canaryAnalysisInterval, r.Client.Status().Update(ctx, instance)
+		}
+		return 0, r.rollBackOSRMUpgrade(ctx, instance, fmt.Sprintf(
+			"The canary served %d requests, fewer than the %d required to compare its error rate",
+			upgrade.Canary.Requests, canary.GetMinRequests(),
+		))
+	}
+
+	maxErrorRate := upgrade.Stable.ErrorRate() + float64(canary.GetMaxErrorRateIncrease())
+	if upgrade.Canary.ErrorRate() > maxErrorRate {
+		return 0, r.rollBackOSRMUpgrade(ctx, instance, fmt.Sprintf(
+			"The canary error rate %.2f%% exceeds the error rate %.2f%% of OSRM %s by more than %d percentage points",
+			upgrade.Canary.ErrorRate(), upgrade.Stable.ErrorRate(), upgrade.FromVersion, canary.GetMaxErrorRateIncrease(),
+		))
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Promoting canary upgrade", "osrmVersion", upgrade.Version)
+	upgrade.Phase = osrmv1alpha1.OSRMUpgradePhasePromoting
+	upgrade.Message = fmt.Sprintf("Rebuilding the map data of all profiles with OSRM %s", upgrade.Version)
+	return 0, r.Client.Status().Update(ctx, instance)
+}
+
+// getGatewayStats sums the requests and errors counted by every running gateway pod.
+func (r *OSRMClusterReconciler) getGatewayStats(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+) (osrmv1alpha1.TrafficStats, osrmv1alpha1.TrafficStats, error) {
+	var stable, canary osrmv1alpha1.TrafficStats
+
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(instance.Namespace), client.MatchingLabels{
+		"app": instance.ChildResourceName(resource.GatewaySuffix, resource.DeploymentSuffix),
+	}); err != nil {
+		return stable, canary, err
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		stats, err := fetchGatewayStats(ctx, fmt.Sprintf("http://%s:%d%s", pod.Status.PodIP, resource.GatewayStatsPort, resource.GatewayStatsPath))
+		if err != nil {
+			return stable, canary, fmt.Errorf("failed fetching stats of gateway pod %s: %v", pod.Name, err)
+		}
+		stable.Requests += stats.Stable.Requests
+		stable.Errors += stats.Stable.Errors
+		canary.Requests += stats.Canary.Requests
+		canary.Errors += stats.Canary.Errors
+	}
+
+	return stable, canary, nil
+}
+
+type gatewayStats struct {
+	Stable osrmv1alpha1.TrafficStats `json:"stable"`
+	Canary osrmv1alpha1.TrafficStats `json:"canary"`
+}
+
+func fetchGatewayStats(ctx context.Context, url string) (*gatewayStats, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := gatewayStatsClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	stats := &gatewayStats{}
+	if err := json.NewDecoder(response.Body).Decode(stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// rollBackOSRMUpgrade stops the canary, leaving all profiles on the release
+// their map data was built with until spec.osrmVersion changes again.
+func (r *OSRMClusterReconciler) rollBackOSRMUpgrade(ctx context.Context, instance *osrmv1alpha1.OSRMCluster, message string) error {
+	ctrl.LoggerFrom(ctx).Info("Rolling back canary upgrade", "osrmVersion", instance.Status.OSRMUpgrade.Version, "reason", message)
+	instance.Status.OSRMUpgrade.Phase = osrmv1alpha1.OSRMUpgradePhaseRolledBack
+	instance.Status.OSRMUpgrade.Message = message
+	return r.Client.Status().Update(ctx, instance)
+}
+
+// cleanUpCanary deletes the canary Deployment and Service once the upgrade was
+// promoted or rolled back, and the gateway no longer sends requests to the canary.
+// The canary map build Job is deleted once the cleanup Job removed the canary's
+// map data from the map data volume, and the cleanup Job after it.
+func (r *OSRMClusterReconciler) cleanUpCanary(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) error {
+	upgrade := instance.Status.OSRMUpgrade
+	if upgrade == nil ||
+		upgrade.Phase == osrmv1alpha1.OSRMUpgradePhaseBuilding ||
+		upgrade.Phase == osrmv1alpha1.OSRMUpgradePhaseAnalyzing ||
+		resource.IsGatewayRoutingToCanary(instance, childResources) {
+		return nil
+	}
+
+	profile := upgrade.Profile
+	canaryJobName := instance.ChildResourceName(profile, resource.CanaryJobSuffix)
+	cleanupJobName := instance.ChildResourceName(profile, resource.CanaryCleanupJobSuffix)
+	profileResources := childResources.Of(resource.ProfileKey(profile))
+	canaryService := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name:      instance.ChildResourceName(profile, resource.CanarySuffix),
+		Namespace: instance.Namespace,
+	}}
+
+	objects := []client.Object{}
+	if status.GetDeployment(instance.ChildResourceName(profile, resource.CanarySuffix), profileResources) != nil {
+		objects = append(objects, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.ChildResourceName(profile, resource.CanarySuffix),
+			Namespace: instance.Namespace,
+		}})
+	}
+	if hasChild(canaryService, profileResources) {
+		objects = append(objects, canaryService)
+	}
+	switch {
+	case status.GetJob(canaryJobName, profileResources) != nil && status.IsJobCompleted(cleanupJobName, profileResources):
+		objects = append(objects, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:      canaryJobName,
+			Namespace: instance.Namespace,
+		}})
+	case status.GetJob(canaryJobName, profileResources) == nil && status.GetJob(cleanupJobName, profileResources) != nil:
+		objects = append(objects, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:      cleanupJobName,
+			Namespace: instance.Namespace,
+		}})
+	}
+
+	propagationPolicy := metav1.DeletePropagationBackground
+	for _, object := range objects {
+		if err := r.deleteChildResource(ctx, instance, object, &client.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
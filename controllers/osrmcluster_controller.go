@@ -20,47 +20,76 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/go-logr/logr"
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/metrics"
 	"github.com/itayankri/OSRM-Operator/internal/resource"
 	"github.com/itayankri/OSRM-Operator/internal/status"
+	"github.com/itayankri/OSRM-Operator/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 	appsv1 "k8s.io/api/apps/v1"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	clientretry "k8s.io/client-go/util/retry"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 const finalizerName = "osrmcluster.itayankri/finalizer"
 
+// Verbosity levels of the controller logs. The changes the operator makes are
+// logged by default, its steady-state progress at debugLevel, and the full spec
+// of each reconciled OSRMCluster at traceLevel.
+const (
+	debugLevel = 1
+	traceLevel = 2
+)
+
+// maxTerminationMessageLength limits the termination message of each failed Job in the MapBuildFailed condition.
+const maxTerminationMessageLength = 2048
+
+// maxConditionMessageLength is the length limit of the message of a Condition.
+const maxConditionMessageLength = 32768
+
 // OSRMClusterReconciler reconciles a OSRMCluster object
 type OSRMClusterReconciler struct {
 	client.Client
 	Scheme           *runtime.Scheme
 	log              logr.Logger
+	recorder         record.EventRecorder
 	DefaultOSRMImage string
+	// MonitorKinds are the Prometheus Operator monitors whose CRDs are installed.
+	MonitorKinds map[osrmv1alpha1.MonitorKind]bool
 }
 
-func NewOSRMClusterReconciler(client client.Client, scheme *runtime.Scheme) *OSRMClusterReconciler {
+func NewOSRMClusterReconciler(client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder) *OSRMClusterReconciler {
 	return &OSRMClusterReconciler{
-		Client: client,
-		Scheme: scheme,
-		log:    ctrl.Log.WithName("controller").WithName("OSRM"),
+		Client:   client,
+		Scheme:   scheme,
+		log:      ctrl.Log.WithName("controller").WithName("OSRM"),
+		recorder: recorder,
 	}
 }
 
@@ -90,21 +119,26 @@ func isPaused(object metav1.Object) bool {
 // the rbac rule requires an empty row at the end to render
 // +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
 // +kubebuilder:rbac:groups="",resources=pods,verbs=update;get;list;watch
-// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;deletecollection
-// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
-// +kubebuilder:rbac:groups="batch",resources=jobs,verbs=get;list;watch;create;update;deletecollection
-// +kubebuilder:rbac:groups="batch",resources=cronjobs,verbs=get;list;watch;create;update;deletecollection
-// +kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;list;watch;create;update;deletecollection
-// +kubebuilder:rbac:groups="autoscaling",resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;deletecollection
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete;deletecollection
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="batch",resources=jobs,verbs=get;list;watch;create;update;patch;delete;deletecollection
+// +kubebuilder:rbac:groups="batch",resources=cronjobs,verbs=get;list;watch;create;update;patch;deletecollection
+// +kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;list;watch;create;update;patch;delete;deletecollection
+// +kubebuilder:rbac:groups="autoscaling",resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;deletecollection
 // +kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=get;list;watch;create;update
 // +kubebuilder:rbac:groups="",resources=endpoints,verbs=get;watch;list
-// +kubebuilder:rbac:groups="policy",resources=poddisruptionbudgets,verbs=get;list;watch;create;update;deletecollection
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="events.k8s.io",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="policy",resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;deletecollection
 // +kubebuilder:rbac:groups=osrm.itayankri,resources=osrmclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=osrm.itayankri,resources=osrmclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=osrm.itayankri,resources=osrmclusters/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;deletecollection
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;deletecollection
+// +kubebuilder:rbac:groups="storage.k8s.io",resources=storageclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups="snapshot.storage.k8s.io",resources=volumesnapshots,verbs=get;list;watch;create;delete
 // +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=roles,verbs=get;list;watch;create;update
 // +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=rolebindings,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups="monitoring.coreos.com",resources=podmonitors;servicemonitors,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -115,14 +149,21 @@ func isPaused(object metav1.Object) bool {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
-func (r *OSRMClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	logger := r.log.WithValues("OSRM", req.NamespacedName)
-	logger.Info("Starting reconciliation")
+func (r *OSRMClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	start := time.Now()
+	logger := r.log.WithValues("namespace", req.Namespace, "cluster", req.Name)
+	ctx = ctrl.LoggerInto(ctx, logger)
+	ctx, span := tracing.Tracer().Start(ctx, "Reconcile", trace.WithAttributes(
+		tracing.NamespaceKey.String(req.Namespace),
+		tracing.ClusterKey.String(req.Name),
+	))
+	defer func() { tracing.End(span, err) }()
+	logger.V(debugLevel).Info("Starting reconciliation")
 
 	instance, err := r.getOSRMCluster(ctx, req.NamespacedName)
 	if err != nil {
 		if errors.IsNotFound(err) {
-			logger.Info("Instance not found")
+			logger.V(debugLevel).Info("Instance not found")
 
 			// No need to requeue if the resource no longer exists
 			return reconcile.Result{}, nil
@@ -134,9 +175,16 @@ func (r *OSRMClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return reconcile.Result{}, err
 	}
 
+	defer func() {
+		// The series of a deleted OSRMCluster are removed by its cleanup.
+		if !isBeingDeleted(instance) {
+			metrics.ObserveReconcile(instance, time.Since(start), err)
+		}
+	}()
+
 	childResources, err := r.getChildResources(ctx, instance)
 	if err != nil {
-		logger.Error(err, "Failed to fetch child resources", instance.Namespace, instance.Name)
+		logger.Error(err, "Failed to fetch child resources")
 		r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "FailedToFetchChildResources", err.Error())
 		return ctrl.Result{}, err
 	}
@@ -149,14 +197,14 @@ func (r *OSRMClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		err := r.initialize(ctx, instance)
 		// No need to requeue here, because
 		// the update will trigger reconciliation again
-		logger.Info("OSRMCLuster initialized")
+		logger.Info("OSRMCluster initialized")
 		return ctrl.Result{}, err
 	}
 
 	if isBeingDeleted(instance) {
-		err := r.cleanup(ctx, instance)
+		err := r.cleanup(ctx, instance, childResources)
 		if err != nil {
-			logger.Error(err, "Cleanup failed for rerouce: %v/%v", instance.Namespace, instance.Name)
+			logger.Error(err, "Cleanup failed")
 			return ctrl.Result{}, err
 		}
 
@@ -165,23 +213,67 @@ func (r *OSRMClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	if isPaused(instance) {
 		if instance.Status.Paused {
-			logger.Info("OSRM operator is paused on resource: %v/%v", instance.Namespace, instance.Name)
+			logger.V(debugLevel).Info("Reconciliation is paused")
 			return ctrl.Result{}, nil
 		}
-		logger.Info(fmt.Sprintf("Pausing OSRM operator on resource: %v/%v", instance.Namespace, instance.Name))
+		logger.Info("Pausing reconciliation")
 		instance.Status.Paused = true
-		err := r.updateOSRMClusterResource(ctx, instance)
-		// instance.Status.ObservedGeneration = instance.Generation
-		// err := r.Client.Status().Update(ctx, instance)
+		err := r.Client.Status().Update(ctx, instance)
+		if err == nil {
+			r.recorder.Event(instance, corev1.EventTypeNormal, EventReasonPaused,
+				fmt.Sprintf("Reconciliation is paused by the %s annotation", osrmv1alpha1.OperatorPausedAnnotation))
+		}
 		return ctrl.Result{}, err
 	}
 
-	rawInstanceSpec, err := json.Marshal(instance.Spec)
+	if instance.Status.Paused {
+		logger.Info("Resuming reconciliation")
+		instance.Status.Paused = false
+		if err := r.Client.Status().Update(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(instance, corev1.EventTypeNormal, EventReasonResumed, "Reconciliation is resumed")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if handled, err := r.handleOnDemandRequests(ctx, instance); err != nil || handled {
+		if err != nil {
+			logger.Error(err, "Failed to handle on-demand requests")
+			r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "FailedToHandleOnDemandRequests", err.Error())
+		}
+		return ctrl.Result{Requeue: handled}, err
+	}
+
+	requeueAfter, err := r.reconcileOSRMUpgrade(ctx, instance, childResources)
 	if err != nil {
-		logger.Error(err, "Failed to marshal OSRMCluster spec")
+		logger.Error(err, "Failed to reconcile the OSRM upgrade")
+		r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "FailedToReconcileOSRMUpgrade", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	switchoverRequeueAfter, err := r.reconcileSwitchovers(ctx, instance, childResources)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile the blue/green switchovers")
+		r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "FailedToReconcileSwitchovers", err.Error())
+		return ctrl.Result{}, err
+	}
+	requeueAfter = shortestRequeue(requeueAfter, switchoverRequeueAfter)
+
+	if rebuilding, err := r.rebuildOutdatedMapData(ctx, instance, childResources); err != nil || rebuilding {
+		if err != nil {
+			logger.Error(err, "Failed to rebuild map data for the OSRM version")
+			r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "FailedToRebuildMapData", err.Error())
+		}
+		return ctrl.Result{Requeue: rebuilding}, err
 	}
 
-	logger.Info("Reconciling OSRMCluster", "spec", string(rawInstanceSpec))
+	if specLogger := logger.V(traceLevel); specLogger.Enabled() {
+		rawInstanceSpec, err := json.Marshal(instance.Spec)
+		if err != nil {
+			logger.Error(err, "Failed to marshal OSRMCluster spec")
+		}
+		specLogger.Info("Reconciling OSRMCluster", "spec", string(rawInstanceSpec))
+	}
 
 	resourceBuilder := resource.OSRMResourceBuilder{
 		Instance: instance,
@@ -194,19 +286,21 @@ func (r *OSRMClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		if builder.ShouldDeploy(childResources) {
 			resource, err := builder.Build()
 			if err != nil {
-				logger.Error(err, "Failed to build resource %v for OSRMCluster %v/%v", builder, instance.Namespace, instance.Name)
+				logger.Error(err, "Failed to build resource", "builder", fmt.Sprintf("%T", builder))
 				r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "FailedToBuildChildResource", err.Error())
 				return ctrl.Result{}, err
 			}
 
 			var operationResult controllerutil.OperationResult
-			err = clientretry.RetryOnConflict(clientretry.DefaultRetry, func() error {
-				var apiError error
-				operationResult, apiError = controllerutil.CreateOrUpdate(ctx, r.Client, resource, func() error {
-					return builder.Update(resource, childResources)
-				})
-				return apiError
+			builderCtx, builderSpan := tracing.Tracer().Start(ctx, "Apply", trace.WithAttributes(
+				tracing.KindKey.String(r.kindOf(resource)),
+				tracing.NameKey.String(resource.GetName()),
+			))
+			operationResult, err = r.apply(builderCtx, instance, resource, func(object client.Object) error {
+				return builder.Update(object, childResources)
 			})
+			builderSpan.SetAttributes(tracing.OperationKey.String(string(operationResult)))
+			tracing.End(builderSpan, err)
 			r.logOperationResult(logger, instance, resource, operationResult, err)
 			if err != nil {
 				r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "Error", err.Error())
@@ -215,15 +309,49 @@ func (r *OSRMClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	}
 
-	err = r.garbageCollection(ctx, instance)
+	if err := r.deleteRecordedSpeedUpdatesJobs(ctx, instance, childResources); err != nil {
+		logger.Error(err, "Failed to delete the completed speed updates Jobs")
+		r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "FailedToDeleteSpeedUpdatesJobs", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileMonitoring(ctx, &resourceBuilder, childResources); err != nil {
+		logger.Error(err, "Failed to reconcile monitoring")
+		r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "FailedToReconcileMonitoring", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileSnapshots(ctx, &resourceBuilder, childResources); err != nil {
+		logger.Error(err, "Failed to reconcile VolumeSnapshots")
+		r.setReconciliationSuccess(ctx, instance, metav1.ConditionFalse, "FailedToReconcileSnapshots", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	err = r.garbageCollection(ctx, instance, childResources)
 	if err != nil {
-		logger.Error(err, "Garbage collection failed for OSRMCluster %v/%v", instance.Namespace, instance.Name)
+		logger.Error(err, "Garbage collection failed")
 		return ctrl.Result{RequeueAfter: time.Second * 10}, err
 	}
 
-	r.setReconciliationSuccess(ctx, instance, metav1.ConditionTrue, "Success", "Reconciliation completed")
-	logger.Info("Finished reconciling")
-	return ctrl.Result{}, nil
+	previousHealthChecks := instance.Status.HealthChecks
+	requeueAfter = shortestRequeue(requeueAfter, r.reconcileHealthChecks(ctx, instance, childResources))
+	// Drift of the child resources is detected even when no change triggers a reconciliation.
+	requeueAfter = shortestRequeue(requeueAfter, instance.Spec.GetResyncInterval())
+
+	driftedCondition := r.driftedCondition(instance, childResources)
+	instance.Status.SetCondition(driftedCondition)
+	reason, message := "Success", "Reconciliation completed"
+	if driftedCondition.Status == metav1.ConditionTrue {
+		reason = "DriftReported"
+		message = fmt.Sprintf("Reconciliation completed, except for %d drifted child resources left as they are with the Report drift policy", len(instance.Status.DriftedResources))
+	}
+	if err := r.updateReconciliationSuccess(ctx, instance, metav1.ConditionTrue, reason, message); err != nil {
+		logger.Error(err, "Failed to update Custom Resource status")
+	} else {
+		r.recordHealthCheckEvents(ctx, instance, previousHealthChecks)
+	}
+	logger.V(debugLevel).Info("Finished reconciling")
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func (r *OSRMClusterReconciler) getOSRMCluster(ctx context.Context, namespacedName types.NamespacedName) (*osrmv1alpha1.OSRMCluster, error) {
@@ -232,12 +360,12 @@ func (r *OSRMClusterReconciler) getOSRMCluster(ctx context.Context, namespacedNa
 	return instance, err
 }
 
-// logAndRecordOperationResult - helper function to log and record events with message and error
+// logOperationResult - helper function to log and record events with message and error
 // it logs and records 'updated' and 'created' OperationResult, and ignores OperationResult 'unchanged'
 func (r *OSRMClusterReconciler) logOperationResult(
 	logger logr.Logger,
-	ro runtime.Object,
-	resource runtime.Object,
+	instance *osrmv1alpha1.OSRMCluster,
+	resource client.Object,
 	operationResult controllerutil.OperationResult,
 	err error,
 ) {
@@ -245,24 +373,58 @@ func (r *OSRMClusterReconciler) logOperationResult(
 		return
 	}
 
-	var operation string
+	var operation, reason string
 	if operationResult == controllerutil.OperationResultCreated {
-		operation = "create"
+		operation, reason = "create", EventReasonCreated
 	}
 
 	if operationResult == controllerutil.OperationResultUpdated {
-		operation = "update"
+		operation, reason = "update", EventReasonUpdated
 	}
 
 	if err == nil {
-		msg := fmt.Sprintf("%sd resource %s of Type %T", operation, resource.(metav1.Object).GetName(), resource.(metav1.Object))
-		logger.Info(msg)
+		logger.Info(fmt.Sprintf("%s resource", reason), "kind", r.kindOf(resource), "name", resource.GetName())
+		metrics.RecordChildOperation(instance, r.kindOf(resource), operation)
+		r.recorder.Eventf(instance, corev1.EventTypeNormal, reason, "%s %s %s", reason, r.kindOf(resource), resource.GetName())
+	}
+
+	if err != nil {
+		logger.Error(err, "Failed to reconcile resource", "kind", r.kindOf(resource), "name", resource.GetName())
+		// apply reports no result when it fails, so the failed operation is told
+		// by whether the resource was ever created.
+		failureReason := EventReasonUpdateFailed
+		if resource.GetResourceVersion() == "" {
+			failureReason = EventReasonCreateFailed
+		}
+		r.recorder.Eventf(instance, corev1.EventTypeWarning, failureReason, "Failed to reconcile %s %s: %v", r.kindOf(resource), resource.GetName(), err)
+	}
+}
+
+// deleteChildResource deletes a child resource of an OSRMCluster, ignoring a
+// resource that no longer exists.
+func (r *OSRMClusterReconciler) deleteChildResource(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	object client.Object,
+	opts ...client.DeleteOption,
+) error {
+	if err := r.Client.Delete(ctx, object, opts...); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
 	}
+	metrics.RecordChildOperation(instance, r.kindOf(object), "delete")
+	r.recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonDeleted, "Deleted %s %s", r.kindOf(object), object.GetName())
+	return nil
+}
 
+func (r *OSRMClusterReconciler) kindOf(object client.Object) string {
+	gvk, err := apiutil.GVKForObject(object, r.Scheme)
 	if err != nil {
-		msg := fmt.Sprintf("failed to %s resource %s of Type %T", operation, resource.(metav1.Object).GetName(), resource.(metav1.Object))
-		logger.Error(err, msg)
+		return fmt.Sprintf("%T", object)
 	}
+	return gvk.Kind
 }
 
 func (r *OSRMClusterReconciler) setReconciliationSuccess(
@@ -271,6 +433,17 @@ func (r *OSRMClusterReconciler) setReconciliationSuccess(
 	conditionStatus metav1.ConditionStatus,
 	reason, msg string,
 ) {
+	if err := r.updateReconciliationSuccess(ctx, osrmCluster, conditionStatus, reason, msg); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to update Custom Resource status")
+	}
+}
+
+func (r *OSRMClusterReconciler) updateReconciliationSuccess(
+	ctx context.Context,
+	osrmCluster *osrmv1alpha1.OSRMCluster,
+	conditionStatus metav1.ConditionStatus,
+	reason, msg string,
+) error {
 	osrmCluster.Status.SetCondition(metav1.Condition{
 		Type:    status.ConditionReconciliationSuccess,
 		Status:  conditionStatus,
@@ -280,24 +453,29 @@ func (r *OSRMClusterReconciler) setReconciliationSuccess(
 			Time: time.Now(),
 		},
 	})
-	if err := r.Status().Update(ctx, osrmCluster); err != nil {
-		ctrl.LoggerFrom(ctx).Error(err, "Failed to update Custom Resource status",
-			"namespace", osrmCluster.Namespace,
-			"name", osrmCluster.Name)
-	}
+	return r.Status().Update(ctx, osrmCluster)
 }
 
 func (r *OSRMClusterReconciler) initialize(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) error {
 	controllerutil.AddFinalizer(instance, finalizerName)
-	return r.updateOSRMClusterResource(ctx, instance)
+	if err := r.updateOSRMClusterResource(ctx, instance); err != nil {
+		return err
+	}
+	r.recorder.Event(instance, corev1.EventTypeNormal, EventReasonInitialized, "Added the finalizer")
+	return nil
 }
 
+// updateOSRMClusterResource updates the spec and metadata of an OSRMCluster, then
+// its status. The update returns the stored status, so the status set by the
+// caller is kept aside and restored before it is updated.
 func (r *OSRMClusterReconciler) updateOSRMClusterResource(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) error {
+	instanceStatus := instance.Status.DeepCopy()
 	err := r.Client.Update(ctx, instance)
 	if err != nil {
 		return err
 	}
 
+	instance.Status = *instanceStatus
 	instance.Status.ObservedGeneration = instance.Generation
 	return r.Client.Status().Update(ctx, instance)
 }
@@ -305,235 +483,698 @@ func (r *OSRMClusterReconciler) updateOSRMClusterResource(ctx context.Context, i
 func (r *OSRMClusterReconciler) updateStatusConditions(
 	ctx context.Context,
 	instance *osrmv1alpha1.OSRMCluster,
-	childResources []runtime.Object,
+	childResources *resource.ChildResources,
 ) (time.Duration, error) {
-	instance.Status.SetConditions(childResources)
+	instance.Status.SetConditions(childResources.Objects())
+	instance.Status.Download = downloadStatus(instance, childResources)
+	oldProfileStatuses := instance.Status.Profiles
+	instance.Status.Profiles = profileStatuses(instance, childResources)
+	if err := r.updateMapInfo(ctx, instance, oldProfileStatuses, childResources); err != nil {
+		return 0, err
+	}
+	r.updateProfileMetrics(instance, childResources)
+	if instance.Spec.HasMapDataVolume() {
+		mapBuildFailedCondition, err := r.mapBuildFailedCondition(ctx, instance, childResources)
+		if err != nil {
+			return 0, err
+		}
+		instance.Status.SetCondition(mapBuildFailedCondition)
+		instance.Status.SetCondition(storageSyncedCondition(instance, childResources))
+		instance.Status.SetCondition(osrmVersionCompatibleCondition(instance, childResources))
+	}
 	err := r.Client.Status().Update(ctx, instance)
 	if err != nil {
 		if errors.IsConflict(err) {
-			r.log.Info("failed to update status because of conflict; requeueing...")
+			ctrl.LoggerFrom(ctx).V(debugLevel).Info("Failed to update status because of a conflict, requeueing")
 			return 2 * time.Second, nil
 		}
 		return 0, err
 	}
+	// The events are recorded once the status that reports their transitions is
+	// stored, so a failed update does not record them twice.
+	r.recordMapBuildEvents(instance, oldProfileStatuses)
+	r.recordSpeedUpdateEvents(instance, oldProfileStatuses)
 	return 0, nil
 }
 
-func (r *OSRMClusterReconciler) getChildResources(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) ([]runtime.Object, error) {
-	children := []runtime.Object{}
+func downloadStatus(instance *osrmv1alpha1.OSRMCluster, childResources *resource.ChildResources) *osrmv1alpha1.DownloadStatus {
+	sharedDownload := instance.Spec.MapBuilder.SharedDownload
+	if sharedDownload == nil {
+		return nil
+	}
 
-	gatewayDeployment := &appsv1.Deployment{}
-	if err := r.Client.Get(ctx, types.NamespacedName{
-		Name:      instance.ChildResourceName(resource.GatewaySuffix, resource.DeploymentSuffix),
-		Namespace: instance.Namespace,
-	}, gatewayDeployment); err != nil {
-		if !errors.IsNotFound(err) {
-			return nil, err
-		}
-	} else {
-		children = append(children, gatewayDeployment)
+	downloadStatus := &osrmv1alpha1.DownloadStatus{
+		Phase:    osrmv1alpha1.DownloadPhasePending,
+		URL:      instance.Spec.PBFURL,
+		Checksum: sharedDownload.GetChecksum(),
 	}
 
-	gatewayService := &corev1.Service{}
-	if err := r.Client.Get(ctx, types.NamespacedName{
-		Name:      instance.ChildResourceName(resource.GatewaySuffix, resource.ServiceSuffix),
-		Namespace: instance.Namespace,
-	}, gatewayService); err != nil {
-		if !errors.IsNotFound(err) {
-			return nil, err
+	downloadResources := childResources.Of(resource.DownloadKey)
+	jobName := instance.ChildResourceName(resource.GatewaySuffix, resource.DownloadJobSuffix)
+	job := status.GetJob(jobName, downloadResources)
+	switch {
+	case job == nil:
+	case status.IsJobCompleted(jobName, downloadResources):
+		downloadStatus.Phase = osrmv1alpha1.DownloadPhaseCompleted
+		downloadStatus.CompletionTime = job.Status.CompletionTime
+	case status.IsJobFailed(jobName, downloadResources):
+		downloadStatus.Phase = osrmv1alpha1.DownloadPhaseFailed
+	default:
+		downloadStatus.Phase = osrmv1alpha1.DownloadPhaseDownloading
+	}
+
+	return downloadStatus
+}
+
+// mapBuildFailedCondition reports the failed map data Jobs along with the
+// termination message of their last container, which holds the tail of its logs.
+func (r *OSRMClusterReconciler) mapBuildFailedCondition(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) (metav1.Condition, error) {
+	failures := []string{}
+	for _, profile := range instance.Spec.Profiles {
+		for _, suffix := range resource.MapDataJobSuffixes(instance, profile) {
+			jobName := instance.ChildResourceName(profile.Name, suffix)
+			job := status.GetJob(jobName, childResources.Of(resource.ProfileKey(profile.Name)))
+			if job == nil || !status.IsJobFailed(jobName, childResources.Of(resource.ProfileKey(profile.Name))) {
+				continue
+			}
+
+			pods := &corev1.PodList{}
+			if err := r.Client.List(ctx, pods, client.InNamespace(instance.Namespace), client.MatchingLabels{
+				"job-name": jobName,
+			}); err != nil {
+				return metav1.Condition{}, err
+			}
+
+			reason, message := status.GetJobFailureReason(job)
+			failure := fmt.Sprintf("%s: %s: %s", jobName, reason, message)
+			if terminationMessage := status.LatestTerminationMessage(pods.Items); terminationMessage != "" {
+				failure = fmt.Sprintf("%s\n%s", failure, tail(terminationMessage, maxTerminationMessageLength))
+			}
+			failures = append(failures, failure)
 		}
-	} else {
-		children = append(children, gatewayService)
 	}
 
-	gatewayConfigMap := &corev1.ConfigMap{}
-	if err := r.Client.Get(ctx, types.NamespacedName{
-		Name:      instance.ChildResourceName(resource.GatewaySuffix, resource.ConfigMapSuffix),
-		Namespace: instance.Namespace,
-	}, gatewayConfigMap); err != nil {
-		if !errors.IsNotFound(err) {
-			return nil, err
+	if len(failures) == 0 {
+		return metav1.Condition{
+			Type:    status.ConditionMapBuildFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoFailedJobs",
+			Message: "No map data Job failed",
+		}, nil
+	}
+
+	return metav1.Condition{
+		Type:    status.ConditionMapBuildFailed,
+		Status:  metav1.ConditionTrue,
+		Reason:  "JobFailed",
+		Message: joinFailures(failures, maxConditionMessageLength),
+	}, nil
+}
+
+// joinFailures joins the failures of Jobs into a message of at most length
+// bytes. The failures that do not fit are counted at the end of the message.
+func joinFailures(failures []string, length int) string {
+	// Room for the count of the failures that do not fit.
+	const omittedLength = 64
+	message := ""
+	for i, failure := range failures {
+		if i > 0 {
+			failure = "\n\n" + failure
 		}
-	} else {
-		children = append(children, gatewayConfigMap)
+		if len(message)+len(failure)+omittedLength > length {
+			return message + fmt.Sprintf("\n\n... and %d more failed Jobs", len(failures)-i)
+		}
+		message += failure
 	}
+	return message
+}
 
-	for _, profileSpec := range instance.Spec.Profiles {
-		pvc := &corev1.PersistentVolumeClaim{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Name:      instance.ChildResourceName(profileSpec.Name, resource.PersistentVolumeClaimSuffix),
-			Namespace: instance.Namespace,
-		}, pvc); err != nil {
-			if !errors.IsNotFound(err) {
-				return nil, err
+// updateMapInfo copies the build report of each profile's map builder Job into
+// its status, and keeps the previous report once the Job and its pods are gone.
+func (r *OSRMClusterReconciler) updateMapInfo(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	oldProfileStatuses []osrmv1alpha1.ProfileStatus,
+	childResources *resource.ChildResources,
+) error {
+	for i := range instance.Status.Profiles {
+		profileStatus := &instance.Status.Profiles[i]
+		for _, old := range oldProfileStatuses {
+			if old.Name == profileStatus.Name {
+				profileStatus.MapInfo = old.MapInfo
 			}
-		} else {
-			children = append(children, pvc)
 		}
 
-		job := &batchv1.Job{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Name:      instance.ChildResourceName(profileSpec.Name, resource.JobSuffix),
-			Namespace: instance.Namespace,
-		}, job); err != nil {
-			if !errors.IsNotFound(err) {
-				return nil, err
+		profile := instance.Spec.Profiles[i]
+		if instance.Spec.HasMapDataVolume() && !instance.Spec.IsPrebuilt() {
+			profileResources := childResources.Of(resource.ProfileKey(profile.Name))
+			jobName := instance.ChildResourceName(profile.Name, resource.MapDataJobSuffix(instance, profile))
+			job := status.GetJob(jobName, profileResources)
+			if job != nil && status.IsJobCompleted(jobName, profileResources) &&
+				(profileStatus.MapInfo == nil || !job.Status.CompletionTime.Equal(profileStatus.MapInfo.BuildTime)) {
+				pods := &corev1.PodList{}
+				if err := r.Client.List(ctx, pods, client.InNamespace(instance.Namespace), client.MatchingLabels{
+					"job-name": jobName,
+				}); err != nil {
+					return err
+				}
+
+				if terminationMessage := status.LatestTerminationMessage(pods.Items); terminationMessage != "" {
+					mapInfo, err := resource.ParseMapInfo(terminationMessage)
+					if err != nil {
+						ctrl.LoggerFrom(ctx).Error(err, "Failed to read map build report", "profile", profile.Name, "kind", "Job", "name", jobName)
+					} else {
+						mapInfo.BuildTime = job.Status.CompletionTime
+						profileStatus.MapInfo = mapInfo
+					}
+				}
 			}
-		} else {
-			children = append(children, job)
 		}
 
-		cronJob := &batchv1.CronJob{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Name:      instance.ChildResourceName(profileSpec.Name, resource.CronJobSuffix),
-			Namespace: instance.Namespace,
-		}, cronJob); err != nil {
-			if !errors.IsNotFound(err) {
-				return nil, err
-			}
-		} else {
-			children = append(children, cronJob)
+		if profileStatus.MapInfo != nil {
+			metrics.SetMapInfo(instance, profileStatus.Name, profileStatus.MapInfo)
 		}
+	}
+	return nil
+}
 
-		deployment := &appsv1.Deployment{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Name:      instance.ChildResourceName(profileSpec.Name, resource.DeploymentSuffix),
-			Namespace: instance.Namespace,
-		}, deployment); err != nil {
-			if !errors.IsNotFound(err) {
-				return nil, err
+// updateProfileMetrics exposes the map build, switchover, workers and speed updates of each profile.
+func (r *OSRMClusterReconciler) updateProfileMetrics(instance *osrmv1alpha1.OSRMCluster, childResources *resource.ChildResources) {
+	resourceBuilder := &resource.OSRMResourceBuilder{
+		Instance: instance,
+		Scheme:   r.Scheme,
+	}
+	for i := range instance.Status.Profiles {
+		metrics.SetProfileStatus(instance, &instance.Status.Profiles[i])
+	}
+	profiles := make([]string, len(instance.Spec.Profiles))
+	for i, profile := range instance.Spec.Profiles {
+		profiles[i] = profile.Name
+	}
+	metrics.SetProfiles(instance, profiles)
+	for _, profile := range instance.Spec.Profiles {
+		if deployment := status.GetDeployment(instance.ChildResourceName(profile.Name, resource.DeploymentSuffix), childResources.Of(resource.ProfileKey(profile.Name))); deployment != nil {
+			replicas := deployment.Status.Replicas
+			if deployment.Spec.Replicas != nil {
+				replicas = *deployment.Spec.Replicas
 			}
-		} else {
-			children = append(children, deployment)
+			metrics.SetReplicas(instance, profile.Name, replicas, deployment.Status.ReadyReplicas)
+		}
+		if updateTime := resourceBuilder.LastTrafficUpdateTime(profile, childResources); updateTime != nil {
+			metrics.SetSpeedUpdateTime(instance, profile.Name, updateTime.Time)
 		}
+	}
+}
 
-		hpa := &autoscalingv1.HorizontalPodAutoscaler{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Name:      instance.ChildResourceName(profileSpec.Name, resource.HorizontalPodAutoscalerSuffix),
-			Namespace: instance.Namespace,
-		}, hpa); err != nil {
-			if !errors.IsNotFound(err) {
-				return nil, err
+// tail returns the last length bytes of a string.
+func tail(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[len(s)-length:]
+}
+
+func profileStatuses(instance *osrmv1alpha1.OSRMCluster, childResources *resource.ChildResources) []osrmv1alpha1.ProfileStatus {
+	resourceBuilder := &resource.OSRMResourceBuilder{Instance: instance}
+	profileStatuses := []osrmv1alpha1.ProfileStatus{}
+	for _, profile := range instance.Spec.Profiles {
+		profileStatus := osrmv1alpha1.ProfileStatus{Name: profile.Name}
+
+		if instance.Spec.HasMapDataVolume() {
+			profileResources := childResources.Of(resource.ProfileKey(profile.Name))
+			for _, suffix := range resource.MapDataJobSuffixes(instance, profile) {
+				jobName := instance.ChildResourceName(profile.Name, suffix)
+				stageStatus := osrmv1alpha1.MapBuildStageStatus{
+					Stage: mapBuildStageName(suffix),
+					Job:   jobName,
+					Phase: osrmv1alpha1.JobPhasePending,
+				}
+
+				job := status.GetJob(jobName, profileResources)
+				switch {
+				case job == nil:
+				case status.IsJobCompleted(jobName, profileResources):
+					stageStatus.Phase = osrmv1alpha1.JobPhaseCompleted
+				case status.IsJobFailed(jobName, profileResources):
+					stageStatus.Phase = osrmv1alpha1.JobPhaseFailed
+				default:
+					stageStatus.Phase = osrmv1alpha1.JobPhaseRunning
+				}
+				if job != nil {
+					stageStatus.StartTime = job.Status.StartTime
+					stageStatus.CompletionTime = job.Status.CompletionTime
+				}
+
+				profileStatus.MapBuild = append(profileStatus.MapBuild, stageStatus)
 			}
-		} else {
-			children = append(children, hpa)
+			profileStatus.OSRMVersion = resource.MapDataOSRMVersion(instance, profile, childResources)
+			profileStatus.SpeedUpdateTime = resourceBuilder.LastTrafficUpdateTime(profile, childResources)
 		}
 
-		pdb := &policyv1.PodDisruptionBudget{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Name:      instance.ChildResourceName(profileSpec.Name, resource.PodDisruptionBudgetSuffix),
-			Namespace: instance.Namespace,
-		}, pdb); err != nil {
-			if !errors.IsNotFound(err) {
-				return nil, err
-			}
-		} else {
-			children = append(children, pdb)
+		profileStatuses = append(profileStatuses, profileStatus)
+	}
+	return profileStatuses
+}
+
+func mapBuildStageName(suffix string) string {
+	switch suffix {
+	case resource.JobSuffix:
+		return "build"
+	case resource.ImportJobSuffix:
+		return "import"
+	}
+	return strings.TrimPrefix(suffix, resource.JobSuffix+"-")
+}
+
+// osrmVersionCompatibleCondition reports the profiles whose map data was built
+// with an OSRM release that spec.osrmVersion cannot serve.
+func osrmVersionCompatibleCondition(instance *osrmv1alpha1.OSRMCluster, childResources *resource.ChildResources) metav1.Condition {
+	incompatible := []string{}
+	for _, profile := range instance.Spec.Profiles {
+		if !resource.IsMapDataOSRMVersionCompatible(instance, profile, childResources) {
+			incompatible = append(incompatible, fmt.Sprintf("%s (%s)", profile.Name, resource.MapDataOSRMVersion(instance, profile, childResources)))
 		}
+	}
 
-		service := &corev1.Service{}
-		if err := r.Client.Get(ctx, types.NamespacedName{
-			Name:      instance.ChildResourceName(profileSpec.Name, resource.ServiceSuffix),
-			Namespace: instance.Namespace,
-		}, service); err != nil {
-			if !errors.IsNotFound(err) {
-				return nil, err
+	if len(incompatible) == 0 {
+		return metav1.Condition{
+			Type:    status.ConditionOSRMVersionCompatible,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Compatible",
+			Message: fmt.Sprintf("All map data can be served by OSRM %s", instance.Spec.GetOSRMVersion()),
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:    status.ConditionOSRMVersionCompatible,
+		Status:  metav1.ConditionFalse,
+		Reason:  "RolloutBlocked",
+		Message: fmt.Sprintf("Map data cannot be served by OSRM %s and is served by the release it was built with: %s", instance.Spec.GetOSRMVersion(), strings.Join(incompatible, ", ")),
+	}
+	if !instance.Spec.IsPrebuilt() {
+		switch instance.Spec.GetOSRMVersionUpgradePolicy() {
+		case osrmv1alpha1.OSRMVersionUpgradePolicyRebuild:
+			condition.Reason = "Rebuilding"
+			condition.Message = fmt.Sprintf("Map data is being rebuilt with OSRM %s: %s", instance.Spec.GetOSRMVersion(), strings.Join(incompatible, ", "))
+		case osrmv1alpha1.OSRMVersionUpgradePolicyCanary:
+			if instance.Status.OSRMUpgrade.IsInProgress() {
+				condition.Reason = "Upgrading"
+				condition.Message = fmt.Sprintf("Map data is served by the release it was built with until the canary upgrade to OSRM %s is promoted: %s", instance.Spec.GetOSRMVersion(), strings.Join(incompatible, ", "))
 			}
-		} else {
-			children = append(children, service)
 		}
 	}
+	return condition
+}
 
-	return children, nil
+func storageSyncedCondition(instance *osrmv1alpha1.OSRMCluster, childResources *resource.ChildResources) metav1.Condition {
+	desired := map[string]k8sresource.Quantity{}
+	resources := childResources.StorageClasses()
+	for _, profile := range instance.Spec.Profiles {
+		if storage := instance.Spec.GetStorage(profile); storage != nil {
+			desired[instance.ChildResourceName(profile.Name, resource.PersistentVolumeClaimSuffix)] = *storage
+			resources = append(resources, childResources.Of(resource.ProfileKey(profile.Name))...)
+		}
+	}
+	return status.StorageSyncedCondition(desired, resources)
 }
 
-func (r *OSRMClusterReconciler) garbageCollection(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) error {
-	labelSelector := fmt.Sprintf(
-		"%s=%s,%s=%s,%s,%s notin (%d)",
-		metadata.NameLabelKey,
-		instance.Name,
-		metadata.ComponentLabelKey,
-		metadata.ComponentLabelProfile,
-		metadata.GenerationLabelKey,
-		metadata.GenerationLabelKey,
-		instance.ObjectMeta.Generation,
-	)
-	propagationPolicy := metav1.DeletePropagationBackground
+// childResourceLists are the kinds of the child resources of an OSRMCluster. Each
+// kind is listed once per reconciliation by the labels of the OSRMCluster, from the
+// cache of the manager, so a new kind of child resource only needs to be added here.
+var childResourceLists = []func() client.ObjectList{
+	func() client.ObjectList { return &appsv1.DeploymentList{} },
+	func() client.ObjectList { return &corev1.ServiceList{} },
+	func() client.ObjectList { return &corev1.ConfigMapList{} },
+	func() client.ObjectList { return &corev1.PersistentVolumeClaimList{} },
+	func() client.ObjectList { return &batchv1.JobList{} },
+	func() client.ObjectList { return &batchv1.CronJobList{} },
+	func() client.ObjectList { return &autoscalingv1.HorizontalPodAutoscalerList{} },
+	func() client.ObjectList { return &policyv1.PodDisruptionBudgetList{} },
+}
 
-	err := r.Client.DeleteAllOf(ctx, &batchv1.CronJob{}, &client.DeleteAllOfOptions{
-		ListOptions: client.ListOptions{
-			Namespace: instance.Namespace,
-			Raw:       &metav1.ListOptions{LabelSelector: labelSelector},
-		},
-		DeleteOptions: client.DeleteOptions{PropagationPolicy: &propagationPolicy},
-	})
-	if err != nil {
-		return err
+func (r *OSRMClusterReconciler) getChildResources(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) (*resource.ChildResources, error) {
+	objects := []client.Object{}
+	for _, newList := range childResourceLists {
+		list := newList()
+		listObjects, err := r.listChildResources(ctx, instance, list)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, listObjects...)
 	}
 
-	err = r.Client.DeleteAllOf(ctx, &batchv1.Job{}, &client.DeleteAllOfOptions{
-		ListOptions: client.ListOptions{
-			Namespace: instance.Namespace,
-			Raw:       &metav1.ListOptions{LabelSelector: labelSelector},
-		},
-		DeleteOptions: client.DeleteOptions{PropagationPolicy: &propagationPolicy},
-	})
+	monitors, err := r.getMonitors(ctx, instance)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	objects = append(objects, monitors...)
 
-	err = r.Client.DeleteAllOf(ctx, &autoscalingv1.HorizontalPodAutoscaler{}, &client.DeleteAllOfOptions{
-		ListOptions: client.ListOptions{
-			Namespace: instance.Namespace,
-			Raw:       &metav1.ListOptions{LabelSelector: labelSelector},
-		},
-	})
+	children := resource.NewChildResources(instance, objects)
+	storageClasses, err := r.getStorageClasses(ctx, instance, children)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	children.AddStorageClasses(storageClasses)
+	return children, nil
+}
 
-	err = r.Client.DeleteAllOf(ctx, &policyv1.PodDisruptionBudget{}, &client.DeleteAllOfOptions{
-		ListOptions: client.ListOptions{
-			Namespace: instance.Namespace,
-			Raw:       &metav1.ListOptions{LabelSelector: labelSelector},
-		},
-	})
-	if err != nil {
-		return err
+// listChildResources lists the child resources of a kind, sorted by name.
+func (r *OSRMClusterReconciler) listChildResources(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	list client.ObjectList,
+) ([]client.Object, error) {
+	if err := r.Client.List(
+		ctx,
+		list,
+		client.InNamespace(instance.Namespace),
+		client.MatchingLabels(metadata.GetSelectorLabels(instance)),
+	); err != nil {
+		return nil, err
 	}
 
-	err = r.Client.DeleteAllOf(ctx, &corev1.Service{}, &client.DeleteAllOfOptions{
-		ListOptions: client.ListOptions{
-			Namespace: instance.Namespace,
-			Raw:       &metav1.ListOptions{LabelSelector: labelSelector},
-		},
-	})
+	items, err := meta.ExtractList(list)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	objects := make([]client.Object, 0, len(items))
+	for _, item := range items {
+		if object, ok := item.(client.Object); ok {
+			objects = append(objects, object)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].GetName() < objects[j].GetName()
+	})
+	return objects, nil
+}
+
+// getStorageClasses returns the StorageClasses of the claims of the profiles,
+// which tell whether the claims can be expanded.
+func (r *OSRMClusterReconciler) getStorageClasses(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	children *resource.ChildResources,
+) ([]runtime.Object, error) {
+	storageClasses := []runtime.Object{}
+	fetched := map[string]bool{}
+	for _, profile := range instance.Spec.Profiles {
+		for _, child := range children.Get(resource.ProfileKey(profile.Name)) {
+			pvc, ok := child.(*corev1.PersistentVolumeClaim)
+			if !ok || pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" || fetched[*pvc.Spec.StorageClassName] {
+				continue
+			}
+			fetched[*pvc.Spec.StorageClassName] = true
+
+			storageClass := &storagev1.StorageClass{}
+			if err := r.Client.Get(ctx, types.NamespacedName{Name: *pvc.Spec.StorageClassName}, storageClass); err != nil {
+				if !errors.IsNotFound(err) {
+					return nil, err
+				}
+			} else {
+				storageClasses = append(storageClasses, storageClass)
+			}
+		}
+	}
+	return storageClasses, nil
+}
+
+// handleOnDemandRequests serves the rebuild-map and update-speeds annotations.
+// A new token deletes the Jobs of the selected profiles so the resource builders
+// recreate them from scratch, and is then recorded in the status.
+func (r *OSRMClusterReconciler) handleOnDemandRequests(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) (bool, error) {
+	handled := false
+
+	if request := instance.MapRebuildRequest(); request != nil && request.Token != instance.Status.MapRebuildToken {
+		ctrl.LoggerFrom(ctx).Info("Rebuilding map on demand", "token", request.Token, "profiles", request.Profiles)
+		r.recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonMapRebuild, "Rebuilding map data on demand for %s", requestedProfiles(request))
+		for _, suffix := range resource.AllMapDataJobSuffixes() {
+			if err := r.deleteProfileJobs(ctx, instance, request, suffix); err != nil {
+				return false, err
+			}
+		}
+		if err := r.deleteProfileJobs(ctx, instance, request, resource.UploadJobSuffix); err != nil {
+			return false, err
+		}
+		if err := r.deleteUnpinnedDownloadJob(ctx, instance); err != nil {
+			return false, err
+		}
+		if err := r.forgetMapData(ctx, instance, request); err != nil {
+			return false, err
+		}
+		instance.Status.MapRebuildToken = request.Token
+		handled = true
+	}
+
+	if request := instance.MapBuildRetryRequest(); request != nil && request.Token != instance.Status.MapBuildRetryToken {
+		ctrl.LoggerFrom(ctx).Info("Retrying failed map builds", "token", request.Token)
+		r.recorder.Event(instance, corev1.EventTypeNormal, EventReasonMapBuildRetry, "Retrying the failed map builds")
+		if err := r.deleteFailedMapDataJobs(ctx, instance); err != nil {
+			return false, err
+		}
+		instance.Status.MapBuildRetryToken = request.Token
+		handled = true
+	}
+
+	if request := instance.SpeedUpdatesRequest(); request != nil && request.Token != instance.Status.SpeedUpdatesToken {
+		ctrl.LoggerFrom(ctx).Info("Updating speeds on demand", "token", request.Token, "profiles", request.Profiles)
+		r.recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonSpeedUpdate, "Updating speeds on demand for %s", requestedProfiles(request))
+		if err := r.deleteProfileJobs(ctx, instance, request, resource.SpeedUpdatesJobSuffix); err != nil {
+			return false, err
+		}
+		instance.Status.SpeedUpdatesToken = request.Token
+		handled = true
+	}
+
+	if !handled {
+		return false, nil
 	}
 
-	err = r.Client.DeleteAllOf(ctx, &appsv1.Deployment{}, &client.DeleteAllOfOptions{
-		ListOptions: client.ListOptions{
+	return true, r.Client.Status().Update(ctx, instance)
+}
+
+func (r *OSRMClusterReconciler) deleteProfileJobs(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	request *osrmv1alpha1.OnDemandRequest,
+	suffix string,
+) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+	for _, profile := range instance.Spec.Profiles {
+		if !request.Includes(profile.Name) {
+			continue
+		}
+
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      instance.ChildResourceName(profile.Name, suffix),
+				Namespace: instance.Namespace,
+			},
+		}
+		if err := r.deleteChildResource(ctx, instance, job, &client.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteUnpinnedDownloadJob deletes the shared download Job unless the checksum of
+// the PBF file is pinned, so a rebuild downloads the file again instead of building
+// from the cached one, which may be outdated.
+func (r *OSRMClusterReconciler) deleteUnpinnedDownloadJob(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) error {
+	sharedDownload := instance.Spec.MapBuilder.SharedDownload
+	if sharedDownload == nil || sharedDownload.GetChecksum() != "" {
+		return nil
+	}
+
+	propagationPolicy := metav1.DeletePropagationBackground
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.ChildResourceName(resource.GatewaySuffix, resource.DownloadJobSuffix),
 			Namespace: instance.Namespace,
-			Raw:       &metav1.ListOptions{LabelSelector: labelSelector},
 		},
-	})
+	}
+	return r.deleteChildResource(ctx, instance, job, &client.DeleteOptions{PropagationPolicy: &propagationPolicy})
+}
+
+// deleteRecordedSpeedUpdatesJobs deletes the completed on-demand speed updates Jobs
+// whose result was recorded on the map data volume of their profile.
+func (r *OSRMClusterReconciler) deleteRecordedSpeedUpdatesJobs(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+	for _, profile := range instance.Spec.Profiles {
+		profileResources := childResources.Of(resource.ProfileKey(profile.Name))
+		jobName := instance.ChildResourceName(profile.Name, resource.SpeedUpdatesJobSuffix)
+		job := status.GetJob(jobName, profileResources)
+		if job == nil || isBeingDeleted(job) || !status.IsJobCompleted(jobName, profileResources) {
+			continue
+		}
+
+		pvc := status.GetPersistentVolumeClaim(instance.ChildResourceName(profile.Name, resource.PersistentVolumeClaimSuffix), profileResources)
+		if pvc == nil || pvc.Annotations[resource.SpeedUpdatesTokenAnnotation] != job.Annotations[resource.OnDemandTokenAnnotation] {
+			continue
+		}
+
+		if err := r.deleteChildResource(ctx, instance, job, &client.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildOutdatedMapData rebuilds the map data of profiles that was built with an
+// outdated OSRM release, as a rebuild request would.
+func (r *OSRMClusterReconciler) rebuildOutdatedMapData(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) (bool, error) {
+	if !instance.Spec.HasMapDataVolume() {
+		return false, nil
+	}
+
+	request := &osrmv1alpha1.OnDemandRequest{}
+	for _, profile := range instance.Spec.Profiles {
+		if resource.IsOutdatedOSRMVersion(instance, resource.MapDataOSRMVersion(instance, profile, childResources)) {
+			request.Profiles = append(request.Profiles, profile.Name)
+		}
+	}
+	if len(request.Profiles) == 0 {
+		return false, nil
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Rebuilding map data built with an outdated OSRM release",
+		"osrmVersion", instance.Spec.GetOSRMVersion(), "profiles", request.Profiles)
+	for _, suffix := range resource.AllMapDataJobSuffixes() {
+		if err := r.deleteProfileJobs(ctx, instance, request, suffix); err != nil {
+			return false, err
+		}
+	}
+	if err := r.deleteProfileJobs(ctx, instance, request, resource.UploadJobSuffix); err != nil {
+		return false, err
+	}
+	if err := r.deleteUnpinnedDownloadJob(ctx, instance); err != nil {
+		return false, err
+	}
+	return true, r.forgetMapData(ctx, instance, request)
+}
+
+// deleteFailedMapDataJobs deletes the failed map data Jobs of all profiles, so the
+// resource builders recreate them. Completed stages of a split build are kept.
+func (r *OSRMClusterReconciler) deleteFailedMapDataJobs(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+	for _, profile := range instance.Spec.Profiles {
+		for _, suffix := range resource.MapDataJobSuffixes(instance, profile) {
+			job := &batchv1.Job{}
+			if err := r.Client.Get(ctx, types.NamespacedName{
+				Name:      instance.ChildResourceName(profile.Name, suffix),
+				Namespace: instance.Namespace,
+			}, job); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+
+			if !status.IsJobFailed(job.Name, []runtime.Object{job}) {
+				continue
+			}
+
+			if err := r.deleteChildResource(ctx, instance, job, &client.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// garbageCollection deletes the child resources of the profiles that were
+// created by previous generations of the OSRMCluster. Retained map data volumes
+// are kept until a profile adopts them or they are deleted by hand.
+func (r *OSRMClusterReconciler) garbageCollection(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) error {
+	labelSelector := fmt.Sprintf(
+		"%s=%s,%s=%s,%s,%s notin (%d),!%s",
+		metadata.NameLabelKey,
+		instance.Name,
+		metadata.ComponentLabelKey,
+		metadata.ComponentLabelProfile,
+		metadata.GenerationLabelKey,
+		metadata.GenerationLabelKey,
+		instance.ObjectMeta.Generation,
+		metadata.RetainedLabelKey,
+	)
+	selector, err := labels.Parse(labelSelector)
 	if err != nil {
 		return err
 	}
 
-	err = r.Client.DeleteAllOf(ctx, &corev1.PersistentVolumeClaim{}, &client.DeleteAllOfOptions{
-		ListOptions: client.ListOptions{
-			Namespace: instance.Namespace,
-			Raw:       &metav1.ListOptions{LabelSelector: labelSelector},
-		},
-	})
+	for _, child := range childResources.Objects() {
+		switch child.(type) {
+		case *batchv1.CronJob, *batchv1.Job, *autoscalingv1.HorizontalPodAutoscaler, *policyv1.PodDisruptionBudget,
+			*corev1.Service, *appsv1.Deployment, *corev1.PersistentVolumeClaim:
+		default:
+			continue
+		}
+		object := child.(client.Object)
+		if isBeingDeleted(object) || !selector.Matches(labels.Set(object.GetLabels())) {
+			continue
+		}
+		if err := r.collectGarbage(ctx, instance, object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return err
+// collectGarbage deletes a child resource of a previous generation. The child
+// resources were fetched before the reconciliation updated them, so the resource
+// is only deleted when it did not change since, e.g. to the current generation.
+func (r *OSRMClusterReconciler) collectGarbage(ctx context.Context, instance *osrmv1alpha1.OSRMCluster, object client.Object) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+	uid := object.GetUID()
+	resourceVersion := object.GetResourceVersion()
+	if err := r.Client.Delete(ctx, object, &client.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+		Preconditions:     &metav1.Preconditions{UID: &uid, ResourceVersion: &resourceVersion},
+	}); err != nil {
+		if errors.IsNotFound(err) || errors.IsConflict(err) {
+			return nil
+		}
+		return err
+	}
+	metrics.RecordChildOperation(instance, r.kindOf(object), "delete")
+	r.recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonGarbageCollected,
+		"Deleted %s %s of generation %s", r.kindOf(object), object.GetName(), object.GetLabels()[metadata.GenerationLabelKey])
+	return nil
 }
 
-func (r *OSRMClusterReconciler) cleanup(ctx context.Context, instance *osrmv1alpha1.OSRMCluster) error {
+func (r *OSRMClusterReconciler) cleanup(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) error {
+	metrics.DeleteCluster(instance)
+
 	if controllerutil.ContainsFinalizer(instance, finalizerName) {
+		r.recorder.Event(instance, corev1.EventTypeNormal, EventReasonCleanup, "Deleting OSRMCluster resources")
+
+		if instance.Spec.Persistence.GetReclaimPolicy() == osrmv1alpha1.ReclaimPolicyRetain {
+			if err := r.retainMapData(ctx, instance, childResources); err != nil {
+				return err
+			}
+		}
+
 		instance.Status.ObservedGeneration = instance.Generation
 		instance.Status.SetCondition(metav1.Condition{
 			Type:    status.ConditionAvailable,
@@ -565,6 +1206,151 @@ func (r *OSRMClusterReconciler) cleanup(ctx context.Context, instance *osrmv1alp
 	return err
 }
 
+// reconcileSnapshots takes a VolumeSnapshot of every profile's map data volume
+// after each map build and speed update, and deletes all but the newest ones.
+func (r *OSRMClusterReconciler) reconcileSnapshots(
+	ctx context.Context,
+	resourceBuilder *resource.OSRMResourceBuilder,
+	childResources *resource.ChildResources,
+) error {
+	instance := resourceBuilder.Instance
+	snapshots := instance.Spec.Persistence.Snapshots
+	if snapshots == nil {
+		return nil
+	}
+
+	for _, profile := range instance.Spec.Profiles {
+		snapshot := resourceBuilder.VolumeSnapshot(profile, childResources)
+		if snapshot == nil {
+			continue
+		}
+
+		if err := r.Client.Create(ctx, snapshot); err != nil {
+			if !errors.IsAlreadyExists(err) {
+				return err
+			}
+		} else {
+			ctrl.LoggerFrom(ctx).Info("Created resource", "kind", "VolumeSnapshot", "name", snapshot.GetName(), "profile", profile.Name)
+		}
+
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(resource.VolumeSnapshotListGVK)
+		if err := r.Client.List(ctx, list, client.InNamespace(instance.Namespace), client.MatchingLabels{
+			metadata.NameLabelKey:    instance.Name,
+			metadata.ProfileLabelKey: profile.Name,
+		}); err != nil {
+			return err
+		}
+
+		// Newest first, by the time of the data they capture.
+		items := list.Items
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].GetAnnotations()[resource.SnapshotDataTimeAnnotation] >
+				items[j].GetAnnotations()[resource.SnapshotDataTimeAnnotation]
+		})
+
+		for i := snapshots.GetKeep(); i < len(items); i++ {
+			if items[i].GetName() == instance.Spec.Persistence.RestoreFrom[profile.Name] {
+				continue
+			}
+			ctrl.LoggerFrom(ctx).Info("Deleting resource", "kind", "VolumeSnapshot", "name", items[i].GetName(), "profile", profile.Name)
+			if err := r.deleteChildResource(ctx, instance, &items[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// retainMapData orphans the map data volumes of all profiles so they outlive the
+// OSRMCluster. Volumes with complete map data are annotated, which lets a new
+// OSRMCluster with the same name and profile adopt them without rebuilding.
+func (r *OSRMClusterReconciler) retainMapData(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) error {
+	for _, profile := range instance.Spec.Profiles {
+		profileResources := childResources.Of(resource.ProfileKey(profile.Name))
+		pvc := status.GetPersistentVolumeClaim(
+			instance.ChildResourceName(profile.Name, resource.PersistentVolumeClaimSuffix),
+			profileResources,
+		)
+		if pvc == nil {
+			continue
+		}
+
+		ownerReferences := []metav1.OwnerReference{}
+		for _, ownerReference := range pvc.OwnerReferences {
+			if ownerReference.UID != instance.UID {
+				ownerReferences = append(ownerReferences, ownerReference)
+			}
+		}
+		pvc.OwnerReferences = ownerReferences
+
+		if pvc.Labels == nil {
+			pvc.Labels = map[string]string{}
+		}
+		pvc.Labels[metadata.RetainedLabelKey] = "true"
+
+		jobName := instance.ChildResourceName(profile.Name, resource.MapDataJobSuffix(instance, profile))
+		if job := status.GetJob(jobName, profileResources); job != nil && status.IsJobCompleted(jobName, profileResources) {
+			pvc.Annotations = metadata.ReconcileAnnotations(pvc.Annotations, map[string]string{
+				resource.RetainedMapDataAnnotation: job.Status.CompletionTime.Format(time.RFC3339),
+			})
+		}
+
+		ctrl.LoggerFrom(ctx).Info("Retaining map data", "profile", profile.Name, "kind", "PersistentVolumeClaim", "name", pvc.Name)
+		if err := r.Client.Update(ctx, pvc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forgetMapData removes the annotations that mark existing map data from the
+// volumes of the requested profiles, so their map data is built again.
+func (r *OSRMClusterReconciler) forgetMapData(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	request *osrmv1alpha1.OnDemandRequest,
+) error {
+	for _, profile := range instance.Spec.Profiles {
+		if !request.Includes(profile.Name) {
+			continue
+		}
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := r.Client.Get(ctx, types.NamespacedName{
+			Name:      instance.ChildResourceName(profile.Name, resource.PersistentVolumeClaimSuffix),
+			Namespace: instance.Namespace,
+		}, pvc); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		_, built := pvc.Annotations[resource.MapDataBuiltAnnotation]
+		_, retained := pvc.Annotations[resource.RetainedMapDataAnnotation]
+		_, restored := pvc.Annotations[resource.RestoredFromSnapshotAnnotation]
+		if !built && !retained && !restored {
+			continue
+		}
+
+		delete(pvc.Annotations, resource.MapDataBuiltAnnotation)
+		delete(pvc.Annotations, resource.RetainedMapDataAnnotation)
+		delete(pvc.Annotations, resource.RestoredFromSnapshotAnnotation)
+		delete(pvc.Annotations, resource.OSRMVersionAnnotation)
+		delete(pvc.Annotations, resource.SpeedUpdatedAnnotation)
+		if err := r.Client.Update(ctx, pvc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *OSRMClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -96,6 +96,48 @@ var _ = Describe("OSRMClusterController", func() {
 		})
 	})
 
+	Context("Report drift policy", func() {
+		BeforeEach(func() {
+			instance = generateOSRMCluster("report-drift-policy")
+			driftPolicy := osrmv1alpha1.DriftPolicyReport
+			instance.Spec.DriftPolicy = &driftPolicy
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			waitForDeployment(ctx, instance, k8sClient)
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		})
+
+		It("Should leave a drifted field as it is and apply the other changes of the spec", func() {
+			profileDeployment := deployment(ctx, instance.Name, instance.Spec.Profiles[0].Name, osrmResource.DeploymentSuffix)
+			profileDeployment.Spec.Template.Spec.Containers[0].Image = "osrm/osrm-backend:edited"
+			Expect(k8sClient.Update(ctx, profileDeployment, client.FieldOwner("kubectl-edit"))).To(Succeed())
+
+			memory := resource.MustParse("300Mi")
+			Expect(updateWithRetry(instance, func(v *osrmv1alpha1.OSRMCluster) {
+				v.Spec.Profiles[0].Resources.Limits[corev1.ResourceMemory] = memory
+				v.Spec.Profiles[0].Resources.Requests[corev1.ResourceMemory] = memory
+			})).To(Succeed())
+
+			Eventually(func() corev1.ResourceList {
+				profileDeployment = deployment(ctx, instance.Name, instance.Spec.Profiles[0].Name, osrmResource.DeploymentSuffix)
+				return profileDeployment.Spec.Template.Spec.Containers[0].Resources.Limits
+			}, 10*time.Second).Should(HaveKeyWithValue(corev1.ResourceMemory, memory))
+			Expect(profileDeployment.Spec.Template.Spec.Containers[0].Image).To(Equal("osrm/osrm-backend:edited"))
+
+			Eventually(func() []string {
+				osrmCluster := &osrmv1alpha1.OSRMCluster{}
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), osrmCluster)).To(Succeed())
+				drifted := osrmCluster.Status.GetDriftedResource("Deployment", profileDeployment.Name)
+				if drifted == nil {
+					return nil
+				}
+				return drifted.Fields
+			}, 10*time.Second).Should(ConsistOf(ContainSubstring(".image (kubectl-edit)")))
+		})
+	})
+
 	Context("ConfigMap updates", func() {
 		testNumber := 0
 		BeforeEach(func() {
@@ -293,6 +335,146 @@ var _ = Describe("OSRMClusterController", func() {
 		})
 	})
 
+	Context("On-demand requests", func() {
+		BeforeEach(func() {
+			instance = generateOSRMCluster("on-demand-requests")
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			waitForDeployment(ctx, instance, k8sClient)
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		})
+
+		It("Should recreate the map builder Job once per rebuild-map token", func() {
+			oldJob := job(ctx, instance.Name, instance.Spec.Profiles[0].Name, osrmResource.JobSuffix)
+
+			Expect(updateWithRetry(instance, func(v *osrmv1alpha1.OSRMCluster) {
+				v.SetAnnotations(map[string]string{osrmv1alpha1.RebuildMapAnnotation: "first"})
+			})).To(Succeed())
+
+			Eventually(func() string {
+				osrmCluster := &osrmv1alpha1.OSRMCluster{}
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), osrmCluster)).To(Succeed())
+				return osrmCluster.Status.MapRebuildToken
+			}, 10*time.Second).Should(Equal("first"))
+
+			Eventually(func() bool {
+				newJob := job(ctx, instance.Name, instance.Spec.Profiles[0].Name, osrmResource.JobSuffix)
+				return string(newJob.UID) != string(oldJob.UID)
+			}, 10*time.Second).Should(BeTrue())
+		})
+	})
+
+	Context("On-demand requests with a shared download", func() {
+		BeforeEach(func() {
+			instance = generateOSRMCluster("on-demand-shared-download")
+			instance.Spec.MapBuilder.SharedDownload = &osrmv1alpha1.SharedDownloadSpec{}
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		})
+
+		It("Should download the PBF file again on a rebuild-map token", func() {
+			oldJob := job(ctx, instance.Name, osrmResource.GatewaySuffix, osrmResource.DownloadJobSuffix)
+
+			Expect(updateWithRetry(instance, func(v *osrmv1alpha1.OSRMCluster) {
+				v.SetAnnotations(map[string]string{osrmv1alpha1.RebuildMapAnnotation: "first"})
+			})).To(Succeed())
+
+			Eventually(func() bool {
+				newJob := job(ctx, instance.Name, osrmResource.GatewaySuffix, osrmResource.DownloadJobSuffix)
+				return string(newJob.UID) != string(oldJob.UID)
+			}, 10*time.Second).Should(BeTrue())
+		})
+	})
+
+	Context("Reclaim policy", func() {
+		It("Should retain map data volumes and adopt them without rebuilding", func() {
+			instance = generateOSRMCluster("reclaim-policy-retain")
+			reclaimPolicy := osrmv1alpha1.ReclaimPolicyRetain
+			instance.Spec.Persistence.ReclaimPolicy = &reclaimPolicy
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			waitForDeployment(ctx, instance, k8sClient)
+
+			oldPVC := pvc(ctx, instance.Name, instance.Spec.Profiles[0].Name, osrmResource.PersistentVolumeClaimSuffix)
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+
+			Eventually(func() map[string]string {
+				retainedPVC := pvc(ctx, instance.Name, instance.Spec.Profiles[0].Name, osrmResource.PersistentVolumeClaimSuffix)
+				Expect(retainedPVC.OwnerReferences).To(BeEmpty())
+				return retainedPVC.Annotations
+			}, ClusterDeletionTimeout).Should(HaveKey(osrmResource.RetainedMapDataAnnotation))
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), &osrmv1alpha1.OSRMCluster{})
+				return errors.IsNotFound(err)
+			}, ClusterDeletionTimeout).Should(BeTrue())
+
+			instance = generateOSRMCluster("reclaim-policy-retain")
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			waitForDeployment(ctx, instance, k8sClient)
+
+			adoptedPVC := pvc(ctx, instance.Name, instance.Spec.Profiles[0].Name, osrmResource.PersistentVolumeClaimSuffix)
+			Expect(adoptedPVC.UID).To(Equal(oldPVC.UID))
+			Expect(adoptedPVC.OwnerReferences).To(HaveLen(1))
+
+			jobs := &batchv1.JobList{}
+			Expect(k8sClient.List(ctx, jobs, client.InNamespace(instance.Namespace), client.MatchingLabels{
+				metadata.NameLabelKey: instance.Name,
+			})).To(Succeed())
+			Expect(jobs.Items).To(BeEmpty())
+
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		})
+
+		It("Should not garbage collect the retained map data volumes of the profiles it does not adopt", func() {
+			instance = generateOSRMCluster("reclaim-policy-retain-gc")
+			reclaimPolicy := osrmv1alpha1.ReclaimPolicyRetain
+			instance.Spec.Persistence.ReclaimPolicy = &reclaimPolicy
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			waitForDeployment(ctx, instance, k8sClient)
+
+			retainedProfile := instance.Spec.Profiles[0]
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+
+			Eventually(func() map[string]string {
+				retainedPVC := pvc(ctx, instance.Name, retainedProfile.Name, osrmResource.PersistentVolumeClaimSuffix)
+				return retainedPVC.Labels
+			}, ClusterDeletionTimeout).Should(HaveKey(metadata.RetainedLabelKey))
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), &osrmv1alpha1.OSRMCluster{})
+				return errors.IsNotFound(err)
+			}, ClusterDeletionTimeout).Should(BeTrue())
+
+			instance = generateOSRMCluster("reclaim-policy-retain-gc")
+			instance.Spec.Profiles[0].Name = "foot"
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			waitForDeployment(ctx, instance, k8sClient)
+
+			Expect(updateWithRetry(instance, func(v *osrmv1alpha1.OSRMCluster) {
+				v.Spec.Service.ExposingServices = append(v.Spec.Service.ExposingServices, "table")
+			})).To(Succeed())
+
+			Eventually(func() string {
+				footDeployment := deployment(ctx, instance.Name, instance.Spec.Profiles[0].Name, osrmResource.DeploymentSuffix)
+				return footDeployment.Labels[metadata.GenerationLabelKey]
+			}, 180*time.Second).Should(Equal("2"))
+
+			retainedPVC := pvc(ctx, instance.Name, retainedProfile.Name, osrmResource.PersistentVolumeClaimSuffix)
+			Consistently(func() bool {
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(retainedPVC), &corev1.PersistentVolumeClaim{})
+				return err == nil
+			}, 5*time.Second).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, retainedPVC)).To(Succeed())
+		})
+	})
+
 	Context("Garbage Collection", func() {
 		testNumber := 0
 		BeforeEach(func() {
@@ -0,0 +1,231 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileSwitchovers moves the blue/green switchover of each profile through
+// its phases: once a map build completes, a green Deployment serves the new map
+// data, the gateway sends it a growing share of the profile's requests at every
+// step, and the profile's workers are moved to the new map data while the green
+// Deployment serves all of its requests. It returns when to move to the next step.
+func (r *OSRMClusterReconciler) reconcileSwitchovers(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	childResources *resource.ChildResources,
+) (time.Duration, error) {
+	resourceBuilder := &resource.OSRMResourceBuilder{
+		Instance: instance,
+		Scheme:   r.Scheme,
+	}
+
+	var requeueAfter time.Duration
+	changed := false
+	for _, profile := range instance.Spec.Profiles {
+		if err := r.cleanUpGreen(ctx, instance, profile, childResources); err != nil {
+			return 0, err
+		}
+
+		profileRequeueAfter, profileChanged := reconcileSwitchover(resourceBuilder, profile, childResources)
+		requeueAfter = shortestRequeue(requeueAfter, profileRequeueAfter)
+		changed = changed || profileChanged
+	}
+
+	// An abort request only applies to the switchovers in progress when it is
+	// handled, so that a stale annotation does not abort the later ones.
+	if request := instance.SwitchoverAbortRequest(); request != nil && request.Token != instance.Status.SwitchoverAbortToken {
+		instance.Status.SwitchoverAbortToken = request.Token
+		changed = true
+	}
+
+	if changed {
+		return requeueAfter, r.Client.Status().Update(ctx, instance)
+	}
+	return requeueAfter, nil
+}
+
+// reconcileSwitchover updates the switchover of a profile in the status, and
+// returns whether it changed.
+func reconcileSwitchover(
+	resourceBuilder *resource.OSRMResourceBuilder,
+	profile *osrmv1alpha1.ProfileSpec,
+	childResources *resource.ChildResources,
+) (time.Duration, bool) {
+	instance := resourceBuilder.Instance
+	switchover := instance.Status.GetSwitchover(profile.Name)
+	rollout := instance.Spec.Rollout
+
+	if !rollout.IsBlueGreen() {
+		if switchover.IsInProgress() {
+			abortSwitchover(switchover, "The BlueGreen rollout strategy is no longer used")
+			return 0, true
+		}
+		return 0, false
+	}
+
+	if mapBuildTime := resourceBuilder.PendingMapBuild(profile, childResources); mapBuildTime != nil &&
+		(switchover == nil || switchover.MapBuildTime == nil || !switchover.MapBuildTime.Equal(mapBuildTime)) {
+		if switchover == nil {
+			instance.Status.Switchovers = append(instance.Status.Switchovers, osrmv1alpha1.SwitchoverStatus{Profile: profile.Name})
+			switchover = instance.Status.GetSwitchover(profile.Name)
+		}
+		*switchover = osrmv1alpha1.SwitchoverStatus{
+			Profile:      profile.Name,
+			MapBuildTime: mapBuildTime,
+			Phase:        osrmv1alpha1.SwitchoverPhaseDeploying,
+			Message:      "Waiting for the green Deployment to serve the new map data",
+		}
+		return 0, true
+	}
+
+	if !switchover.IsInProgress() {
+		return 0, false
+	}
+
+	if request := instance.SwitchoverAbortRequest(); request != nil && request.Token != instance.Status.SwitchoverAbortToken &&
+		request.Includes(profile.Name) && switchover.Phase != osrmv1alpha1.SwitchoverPhaseCompleting {
+		abortSwitchover(switchover, fmt.Sprintf("Aborted by the %s annotation", osrmv1alpha1.AbortSwitchoverAnnotation))
+		return 0, true
+	}
+
+	action := instance.SwitchoverAction()
+	steps := rollout.GetSteps()
+	switch switchover.Phase {
+	case osrmv1alpha1.SwitchoverPhaseDeploying:
+		green := status.GetDeployment(instance.ChildResourceName(profile.Name, resource.GreenSuffix), childResources.Of(resource.ProfileKey(profile.Name)))
+		if green == nil || !resource.IsDeploymentRolledOut(green) {
+			return 0, false
+		}
+		startSwitchoverStep(switchover, 0, steps)
+		return rollout.GetStepInterval(), true
+
+	case osrmv1alpha1.SwitchoverPhaseShifting:
+		if action == osrmv1alpha1.SwitchoverActionPause {
+			switchover.Phase = osrmv1alpha1.SwitchoverPhasePaused
+			switchover.Message = fmt.Sprintf("Paused with %d%% of the requests sent to the new map data", switchover.Weight)
+			return 0, true
+		}
+		remaining := rollout.GetStepInterval() - time.Since(switchover.StepStartTime.Time)
+		if remaining > 0 {
+			return remaining, false
+		}
+		if int(switchover.Step)+1 < len(steps) {
+			startSwitchoverStep(switchover, switchover.Step+1, steps)
+			return rollout.GetStepInterval(), true
+		}
+		switchover.Phase = osrmv1alpha1.SwitchoverPhaseCompleting
+		switchover.Weight = 100
+		switchover.Message = "Moving the workers to the new map data"
+		return 0, true
+
+	case osrmv1alpha1.SwitchoverPhasePaused:
+		if action == osrmv1alpha1.SwitchoverActionPause {
+			return 0, false
+		}
+		startSwitchoverStep(switchover, switchover.Step, steps)
+		return rollout.GetStepInterval(), true
+
+	case osrmv1alpha1.SwitchoverPhaseCompleting:
+		deployment := status.GetDeployment(instance.ChildResourceName(profile.Name, resource.DeploymentSuffix), childResources.Of(resource.ProfileKey(profile.Name)))
+		if deployment == nil ||
+			deployment.Spec.Template.ObjectMeta.Annotations[resource.LastMapBuildTimeAnnotation] != switchover.MapBuildTime.Format(time.RFC3339) ||
+			!resource.IsDeploymentRolledOut(deployment) {
+			return 0, false
+		}
+		switchover.Phase = osrmv1alpha1.SwitchoverPhaseCompleted
+		switchover.Weight = 0
+		switchover.Message = "The workers serve the new map data"
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func startSwitchoverStep(switchover *osrmv1alpha1.SwitchoverStatus, step int32, steps []int32) {
+	// The steps may have been shortened since the switchover started.
+	if int(step) >= len(steps) {
+		step = int32(len(steps) - 1)
+	}
+	switchover.Phase = osrmv1alpha1.SwitchoverPhaseShifting
+	switchover.Step = step
+	switchover.Weight = steps[step]
+	switchover.StepStartTime = &metav1.Time{Time: time.Now()}
+	switchover.Message = fmt.Sprintf("%d%% of the requests are sent to the new map data", switchover.Weight)
+}
+
+// abortSwitchover sends all requests back to the profile's workers. It only stops
+// the traffic shifting: the workers that restart load the new map data, unless
+// they restore it from the snapshot of the map build they serve.
+func abortSwitchover(switchover *osrmv1alpha1.SwitchoverStatus, message string) {
+	switchover.Phase = osrmv1alpha1.SwitchoverPhaseAborted
+	switchover.Weight = 0
+	switchover.Message = message
+}
+
+// cleanUpGreen deletes the green Deployment and Service of a profile once its
+// switchover completed or was aborted, and the gateway no longer sends requests to them.
+func (r *OSRMClusterReconciler) cleanUpGreen(
+	ctx context.Context,
+	instance *osrmv1alpha1.OSRMCluster,
+	profile *osrmv1alpha1.ProfileSpec,
+	childResources *resource.ChildResources,
+) error {
+	switchover := instance.Status.GetSwitchover(profile.Name)
+	if switchover == nil ||
+		switchover.IsInProgress() ||
+		status.GetDeployment(instance.ChildResourceName(profile.Name, resource.GreenSuffix), childResources.Of(resource.ProfileKey(profile.Name))) == nil ||
+		resource.IsGatewayRoutingToGreen(instance, profile, childResources) {
+		return nil
+	}
+
+	propagationPolicy := metav1.DeletePropagationBackground
+	for _, object := range []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.ChildResourceName(profile.Name, resource.GreenSuffix),
+			Namespace: instance.Namespace,
+		}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.ChildResourceName(profile.Name, resource.GreenSuffix),
+			Namespace: instance.Namespace,
+		}},
+	} {
+		if err := r.deleteChildResource(ctx, instance, object, &client.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shortestRequeue returns the earlier of two requeue delays, ignoring zero delays.
+func shortestRequeue(a, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
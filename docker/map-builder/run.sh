@@ -30,22 +30,94 @@ fi
 
 PBF_FILE_NAME=$(basename $PBF_URL)
 OSRM_FILE_NAME="${PBF_FILE_NAME/osm.pbf/osrm}"
+REPORT_FILE=$ROOT_DIR/$PARTITIONED_DATA_DIR/build-report
 
-cd $ROOT_DIR
-mkdir $PARTITIONED_DATA_DIR $CUSTOMIZED_DATA_DIR
-cd $PARTITIONED_DATA_DIR
+mkdir -p $ROOT_DIR && cd $ROOT_DIR
+mkdir -p $PARTITIONED_DATA_DIR $CUSTOMIZED_DATA_DIR
 
-echo "Downloading PBF file from $PBF_URL"
-curl -O $PBF_URL
+# record stores a value of the build report on the data volume, so that
+# stages that run in separate Jobs contribute to the same report.
+record() {
+  echo "$1=$2" >> $REPORT_FILE
+}
 
-echo "Extracting PBF"
-osrm-extract -p /opt/$PROFILE.lua $PBF_FILE_NAME $EXTRACT_OPTIONS && \
+# run_timed runs a stage and records its duration in seconds.
+run_timed() {
+  local start=$(date +%s)
+  $1 || return $?
+  record "${1^^}_SECONDS" $(( $(date +%s) - start ))
+}
 
-echo "Partitioning map data"
-osrm-partition $OSRM_FILE_NAME $PARTITION_OPTIONS
+# write_report writes the build report as JSON to the termination message
+# of the container, where the operator picks it up.
+write_report() {
+  source $REPORT_FILE
+  printf '{"nodes":%d,"edges":%d,"boundingBox":"%s","osrmVersion":"%s","profileHash":"%s","stageDurationSeconds":{"extract":%d,"partition":%d,"customize":%d},"outputSizeBytes":%d}' \
+    "${NODES:-0}" "${EDGES:-0}" "${BOUNDING_BOX}" "${OSRM_VERSION}" "${PROFILE_HASH}" \
+    "${EXTRACT_SECONDS:-0}" "${PARTITION_SECONDS:-0}" "${CUSTOMIZE_SECONDS:-0}" "${OUTPUT_SIZE_BYTES:-0}" \
+    > ${TERMINATION_MESSAGE_PATH:-/dev/termination-log}
+}
 
-cp * ../$CUSTOMIZED_DATA_DIR
-cd ../$CUSTOMIZED_DATA_DIR
+# STAGE runs a single stage of the build (extract, partition or customize).
+# When it is not set, all stages run one after the other.
+extract() {
+  cd $ROOT_DIR/$PARTITIONED_DATA_DIR
+  rm -f $REPORT_FILE
 
-echo "Customizing map data"
-osrm-customize $OSRM_FILE_NAME $CUSTOMIZE_OPTIONS --time-zone-file /opt/timezone-file.json
\ No newline at end of file
+  if [[ -n "${PBF_PATH}" ]]; then
+    echo "Using shared PBF file $PBF_PATH"
+    ln -sf $PBF_PATH $PBF_FILE_NAME
+  else
+    echo "Downloading PBF file from $PBF_URL"
+    curl -O $PBF_URL
+  fi
+
+  echo "Extracting PBF"
+  osrm-extract -p /opt/$PROFILE.lua $PBF_FILE_NAME $EXTRACT_OPTIONS 2>&1 | tee extract.log
+  if [[ ${PIPESTATUS[0]} -ne 0 ]]; then
+    return 1
+  fi
+
+  record NODES $(grep -oE "Raw input contains [0-9]+ nodes" extract.log | grep -oE "[0-9]+" | tail -1)
+  record EDGES $(grep -oE "[0-9]+ edges" extract.log | grep -oE "[0-9]+" | tail -1)
+  record BOUNDING_BOX "\"$(osmium fileinfo -g header.boxes $PBF_FILE_NAME 2>/dev/null | tr -d '()' | head -1)\""
+  record OSRM_VERSION $(osrm-routed --version)
+  record PROFILE_HASH $(sha256sum /opt/$PROFILE.lua | cut -d' ' -f1)
+}
+
+partition() {
+  cd $ROOT_DIR/$PARTITIONED_DATA_DIR
+
+  echo "Partitioning map data"
+  osrm-partition $OSRM_FILE_NAME $PARTITION_OPTIONS
+}
+
+customize() {
+  cd $ROOT_DIR/$PARTITIONED_DATA_DIR
+  cp $OSRM_FILE_NAME* ../$CUSTOMIZED_DATA_DIR
+  cd ../$CUSTOMIZED_DATA_DIR
+
+  echo "Customizing map data"
+  osrm-customize $OSRM_FILE_NAME $CUSTOMIZE_OPTIONS --time-zone-file /opt/timezone-file.json || return $?
+
+  record OUTPUT_SIZE_BYTES $(du -sb . | cut -f1)
+}
+
+case "${STAGE}" in
+  extract)
+    run_timed extract
+    ;;
+  partition)
+    run_timed partition
+    ;;
+  customize)
+    run_timed customize && write_report
+    ;;
+  "")
+    run_timed extract && run_timed partition && run_timed customize && write_report
+    ;;
+  *)
+    echo "Unknown STAGE $STAGE"
+    exit 1
+    ;;
+esac
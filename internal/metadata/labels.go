@@ -10,14 +10,20 @@ import (
 type ComponentLabelValue string
 
 const (
-	ComponentLabelGateway ComponentLabelValue = "gateway"
-	ComponentLabelProfile ComponentLabelValue = "profile"
+	ComponentLabelGateway  ComponentLabelValue = "gateway"
+	ComponentLabelProfile  ComponentLabelValue = "profile"
+	ComponentLabelDownload ComponentLabelValue = "download"
 )
 
 const NameLabelKey = "app.kubernetes.io/name"
 const PartOfLabelKey = "app.kubernetes.io/part-of"
 const ComponentLabelKey = "app.kubernetes.io/component"
 const GenerationLabelKey = "osrmcluster.itayankri/cluster-generation"
+const RetainedLabelKey = "osrmcluster.itayankri/retained"
+const ProfileLabelKey = "osrmcluster.itayankri/profile"
+
+// MetricsLabelKey marks the pods and Services the monitor of an OSRMCluster scrapes.
+const MetricsLabelKey = "osrmcluster.itayankri/metrics"
 
 func GetLabels(instance *osrmv1alpha1.OSRMCluster, componentName ComponentLabelValue) map[string]string {
 	labels := map[string]string{
@@ -35,3 +41,18 @@ func GetLabels(instance *osrmv1alpha1.OSRMCluster, componentName ComponentLabelV
 
 	return labels
 }
+
+// GetProfileLabels returns the labels of the child resources of a profile.
+func GetProfileLabels(instance *osrmv1alpha1.OSRMCluster, profile string) map[string]string {
+	labels := GetLabels(instance, ComponentLabelProfile)
+	labels[ProfileLabelKey] = profile
+	return labels
+}
+
+// GetSelectorLabels returns the labels shared by all the child resources of an OSRMCluster.
+func GetSelectorLabels(instance *osrmv1alpha1.OSRMCluster) map[string]string {
+	return map[string]string{
+		NameLabelKey:   instance.Name,
+		PartOfLabelKey: "osrmcluster",
+	}
+}
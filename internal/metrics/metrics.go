@@ -0,0 +1,473 @@
+package metrics
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "osrm_operator"
+
+var clusterLabels = []string{"namespace", "osrmcluster"}
+var profileLabels = []string{"namespace", "osrmcluster", "profile"}
+var healthCheckLabels = []string{"namespace", "osrmcluster", "profile", "check"}
+
+var jobPhases = []osrmv1alpha1.JobPhase{
+	osrmv1alpha1.JobPhasePending,
+	osrmv1alpha1.JobPhaseRunning,
+	osrmv1alpha1.JobPhaseCompleted,
+	osrmv1alpha1.JobPhaseFailed,
+}
+
+var switchoverPhases = []osrmv1alpha1.SwitchoverPhase{
+	osrmv1alpha1.SwitchoverPhaseDeploying,
+	osrmv1alpha1.SwitchoverPhaseShifting,
+	osrmv1alpha1.SwitchoverPhasePaused,
+	osrmv1alpha1.SwitchoverPhaseCompleting,
+	osrmv1alpha1.SwitchoverPhaseCompleted,
+	osrmv1alpha1.SwitchoverPhaseAborted,
+}
+
+var (
+	mapNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_nodes",
+		Help:      "Number of nodes in the PBF file of the last map build.",
+	}, profileLabels)
+
+	mapEdges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_edges",
+		Help:      "Number of edges in the routing graph of the last map build.",
+	}, profileLabels)
+
+	mapOutputSizeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_output_size_bytes",
+		Help:      "Size of the map data produced by the last map build.",
+	}, profileLabels)
+
+	mapBuildTimestampSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_build_timestamp_seconds",
+		Help:      "Completion time of the last map build, in seconds since the epoch.",
+	}, profileLabels)
+
+	mapBuildStageDurationSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_build_stage_duration_seconds",
+		Help:      "Duration of each stage of the last map build.",
+	}, append(profileLabels, "stage"))
+
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_total",
+		Help:      "Number of reconciliations of an OSRMCluster, by result.",
+	}, append(clusterLabels, "result"))
+
+	reconcileDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of the reconciliations of an OSRMCluster.",
+		Buckets:   prometheus.DefBuckets,
+	}, clusterLabels)
+
+	childOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "child_operations_total",
+		Help:      "Number of child resources created, updated and deleted by the operator, by kind.",
+	}, append(clusterLabels, "kind", "operation"))
+
+	childDriftTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "child_drift_total",
+		Help:      "Number of times fields the operator sets on a child resource were found changed by others, by kind and drift policy.",
+	}, append(clusterLabels, "kind", "policy"))
+
+	mapBuildDurationSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_build_duration_seconds",
+		Help:      "Duration of the last completed map build, from the start of its first Job to the completion of its last.",
+	}, profileLabels)
+
+	mapBuildPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "map_build_phase",
+		Help:      "Current phase of each Job of the map build, set to 1 for the current phase and 0 for the others.",
+	}, append(profileLabels, "stage", "phase"))
+
+	switchoverPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "switchover_phase",
+		Help:      "Current phase of the last blue/green switchover, set to 1 for the current phase and 0 for the others.",
+	}, append(profileLabels, "phase"))
+
+	profileReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "profile_replicas",
+		Help:      "Number of desired worker replicas of a profile.",
+	}, profileLabels)
+
+	profileReadyReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "profile_ready_replicas",
+		Help:      "Number of ready worker replicas of a profile.",
+	}, profileLabels)
+
+	healthCheckHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "health_check_healthy",
+		Help:      "Whether the last run of a health check passed, 1 when it did and 0 when it did not.",
+	}, healthCheckLabels)
+
+	healthCheckRegression = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "health_check_regression",
+		Help:      "Set to 1 while a health check fails since the map data of its profile was rebuilt or its speeds were updated.",
+	}, healthCheckLabels)
+
+	healthCheckLatencySeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "health_check_latency_seconds",
+		Help:      "Time the gateway took to answer the last run of a health check.",
+	}, healthCheckLabels)
+
+	healthCheckRouteDurationSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "health_check_route_duration_seconds",
+		Help:      "Travel time answered to the last run of a health check.",
+	}, healthCheckLabels)
+
+	healthCheckRouteDistanceMeters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "health_check_route_distance_meters",
+		Help:      "Distance answered to the last run of a health check.",
+	}, healthCheckLabels)
+
+	speedUpdateAgeSeconds = newAgeCollector(prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "speed_update_age_seconds"),
+		"Time since the last successful speed update of a profile.",
+		profileLabels,
+		nil,
+	))
+)
+
+// profiles are the profiles of each OSRMCluster that have series, by namespace and name.
+var profiles = struct {
+	sync.Mutex
+	names map[string][]string
+}{names: map[string][]string{}}
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		mapNodes,
+		mapEdges,
+		mapOutputSizeBytes,
+		mapBuildTimestampSeconds,
+		mapBuildStageDurationSeconds,
+		reconcileTotal,
+		reconcileDurationSeconds,
+		childOperationsTotal,
+		childDriftTotal,
+		mapBuildDurationSeconds,
+		mapBuildPhase,
+		switchoverPhase,
+		profileReplicas,
+		profileReadyReplicas,
+		healthCheckHealthy,
+		healthCheckRegression,
+		healthCheckLatencySeconds,
+		healthCheckRouteDurationSeconds,
+		healthCheckRouteDistanceMeters,
+		speedUpdateAgeSeconds,
+	)
+}
+
+func clusterLabelValues(instance *osrmv1alpha1.OSRMCluster) prometheus.Labels {
+	return prometheus.Labels{
+		"namespace":   instance.Namespace,
+		"osrmcluster": instance.Name,
+	}
+}
+
+func profileLabelValues(instance *osrmv1alpha1.OSRMCluster, profile string) prometheus.Labels {
+	labels := clusterLabelValues(instance)
+	labels["profile"] = profile
+	return labels
+}
+
+func withLabels(labels prometheus.Labels, extra prometheus.Labels) prometheus.Labels {
+	merged := prometheus.Labels{}
+	for name, value := range labels {
+		merged[name] = value
+	}
+	for name, value := range extra {
+		merged[name] = value
+	}
+	return merged
+}
+
+// ObserveReconcile records the result and duration of a reconciliation.
+func ObserveReconcile(instance *osrmv1alpha1.OSRMCluster, duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	labels := clusterLabelValues(instance)
+	reconcileTotal.With(withLabels(labels, prometheus.Labels{"result": result})).Inc()
+	reconcileDurationSeconds.With(labels).Observe(duration.Seconds())
+}
+
+// RecordChildOperation counts a child resource of a kind that was created, updated or deleted.
+func RecordChildOperation(instance *osrmv1alpha1.OSRMCluster, kind, operation string) {
+	childOperationsTotal.With(withLabels(clusterLabelValues(instance), prometheus.Labels{
+		"kind":      kind,
+		"operation": operation,
+	})).Inc()
+}
+
+// RecordChildDrift counts a child resource of a kind whose fields were changed by others,
+// along with the drift policy that handled it.
+func RecordChildDrift(instance *osrmv1alpha1.OSRMCluster, kind string, policy osrmv1alpha1.DriftPolicy) {
+	childDriftTotal.With(withLabels(clusterLabelValues(instance), prometheus.Labels{
+		"kind":   kind,
+		"policy": string(policy),
+	})).Inc()
+}
+
+// SetProfileStatus exposes the state of a profile's map build and switchover.
+func SetProfileStatus(instance *osrmv1alpha1.OSRMCluster, profileStatus *osrmv1alpha1.ProfileStatus) {
+	labels := profileLabelValues(instance, profileStatus.Name)
+
+	var startTime, completionTime *time.Time
+	completed := len(profileStatus.MapBuild) > 0
+	// The stages of the map build change with the map source.
+	mapBuildPhase.DeletePartialMatch(labels)
+	for _, stage := range profileStatus.MapBuild {
+		for _, phase := range jobPhases {
+			value := 0.0
+			if stage.Phase == phase {
+				value = 1
+			}
+			mapBuildPhase.With(withLabels(labels, prometheus.Labels{
+				"stage": stage.Stage,
+				"phase": string(phase),
+			})).Set(value)
+		}
+
+		if stage.Phase != osrmv1alpha1.JobPhaseCompleted || stage.StartTime == nil || stage.CompletionTime == nil {
+			completed = false
+			continue
+		}
+		if startTime == nil || stage.StartTime.Time.Before(*startTime) {
+			startTime = &stage.StartTime.Time
+		}
+		if completionTime == nil || stage.CompletionTime.Time.After(*completionTime) {
+			completionTime = &stage.CompletionTime.Time
+		}
+	}
+	if completed {
+		mapBuildDurationSeconds.With(labels).Set(completionTime.Sub(*startTime).Seconds())
+	}
+
+	if switchover := instance.Status.GetSwitchover(profileStatus.Name); switchover != nil {
+		for _, phase := range switchoverPhases {
+			value := 0.0
+			if switchover.Phase == phase {
+				value = 1
+			}
+			switchoverPhase.With(withLabels(labels, prometheus.Labels{"phase": string(phase)})).Set(value)
+		}
+	}
+}
+
+// SetReplicas exposes the desired and ready worker replicas of a profile.
+func SetReplicas(instance *osrmv1alpha1.OSRMCluster, profile string, replicas, readyReplicas int32) {
+	labels := profileLabelValues(instance, profile)
+	profileReplicas.With(labels).Set(float64(replicas))
+	profileReadyReplicas.With(labels).Set(float64(readyReplicas))
+}
+
+// SetSpeedUpdateTime records the time of the last successful speed update of a profile.
+func SetSpeedUpdateTime(instance *osrmv1alpha1.OSRMCluster, profile string, updateTime time.Time) {
+	speedUpdateAgeSeconds.set([]string{instance.Namespace, instance.Name, profile}, updateTime)
+}
+
+// SetMapInfo exposes the build report of a profile's map data.
+func SetMapInfo(instance *osrmv1alpha1.OSRMCluster, profile string, mapInfo *osrmv1alpha1.MapInfo) {
+	labels := profileLabelValues(instance, profile)
+	mapNodes.With(labels).Set(float64(mapInfo.Nodes))
+	mapEdges.With(labels).Set(float64(mapInfo.Edges))
+	mapOutputSizeBytes.With(labels).Set(float64(mapInfo.OutputSizeBytes))
+	if mapInfo.BuildTime != nil {
+		mapBuildTimestampSeconds.With(labels).Set(float64(mapInfo.BuildTime.Unix()))
+	}
+	mapBuildStageDurationSeconds.DeletePartialMatch(labels)
+	for stage, seconds := range mapInfo.StageDurationSeconds {
+		mapBuildStageDurationSeconds.With(withLabels(labels, prometheus.Labels{"stage": stage})).Set(float64(seconds))
+	}
+}
+
+// SetHealthCheck exposes the last result of a health check.
+func SetHealthCheck(instance *osrmv1alpha1.OSRMCluster, healthCheck *osrmv1alpha1.HealthCheckStatus) {
+	labels := withLabels(profileLabelValues(instance, healthCheck.Profile), prometheus.Labels{"check": healthCheck.Name})
+	healthy := 0.0
+	if healthCheck.Healthy {
+		healthy = 1
+	}
+	healthCheckHealthy.With(labels).Set(healthy)
+	regression := 0.0
+	if healthCheck.Regression != "" {
+		regression = 1
+	}
+	healthCheckRegression.With(labels).Set(regression)
+
+	for _, gauge := range []*prometheus.GaugeVec{
+		healthCheckLatencySeconds,
+		healthCheckRouteDurationSeconds,
+		healthCheckRouteDistanceMeters,
+	} {
+		gauge.Delete(labels)
+	}
+	if healthCheck.Latency != nil {
+		healthCheckLatencySeconds.With(labels).Set(healthCheck.Latency.Seconds())
+	}
+	if healthCheck.Duration != nil {
+		healthCheckRouteDurationSeconds.With(labels).Set(healthCheck.Duration.Seconds())
+	}
+	if healthCheck.Distance != nil {
+		healthCheckRouteDistanceMeters.With(labels).Set(float64(*healthCheck.Distance))
+	}
+}
+
+// DeleteHealthCheck removes the series of a health check that no longer exists.
+func DeleteHealthCheck(instance *osrmv1alpha1.OSRMCluster, name string) {
+	labels := withLabels(clusterLabelValues(instance), prometheus.Labels{"check": name})
+	for _, gauge := range healthCheckGauges() {
+		gauge.DeletePartialMatch(labels)
+	}
+}
+
+func healthCheckGauges() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{
+		healthCheckHealthy,
+		healthCheckRegression,
+		healthCheckLatencySeconds,
+		healthCheckRouteDurationSeconds,
+		healthCheckRouteDistanceMeters,
+	}
+}
+
+func profileGauges() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{
+		mapNodes,
+		mapEdges,
+		mapOutputSizeBytes,
+		mapBuildTimestampSeconds,
+		mapBuildStageDurationSeconds,
+		mapBuildDurationSeconds,
+		mapBuildPhase,
+		switchoverPhase,
+		profileReplicas,
+		profileReadyReplicas,
+	}
+}
+
+// SetProfiles records the profiles of an OSRMCluster, and removes the series of
+// the profiles that were removed since the previous call.
+func SetProfiles(instance *osrmv1alpha1.OSRMCluster, names []string) {
+	key := instance.Namespace + "/" + instance.Name
+	profiles.Lock()
+	previous := profiles.names[key]
+	profiles.names[key] = append([]string{}, names...)
+	profiles.Unlock()
+
+	for _, name := range previous {
+		if slices.Contains(names, name) {
+			continue
+		}
+		labels := profileLabelValues(instance, name)
+		for _, gauge := range append(profileGauges(), healthCheckGauges()...) {
+			gauge.DeletePartialMatch(labels)
+		}
+		speedUpdateAgeSeconds.delete([]string{instance.Namespace, instance.Name, name})
+	}
+}
+
+// DeleteCluster removes the series of a deleted OSRMCluster.
+func DeleteCluster(instance *osrmv1alpha1.OSRMCluster) {
+	profiles.Lock()
+	delete(profiles.names, instance.Namespace+"/"+instance.Name)
+	profiles.Unlock()
+
+	labels := clusterLabelValues(instance)
+	for _, gauge := range append(profileGauges(), healthCheckGauges()...) {
+		gauge.DeletePartialMatch(labels)
+	}
+	reconcileTotal.DeletePartialMatch(labels)
+	reconcileDurationSeconds.DeletePartialMatch(labels)
+	childOperationsTotal.DeletePartialMatch(labels)
+	childDriftTotal.DeletePartialMatch(labels)
+	speedUpdateAgeSeconds.deletePrefix([]string{instance.Namespace, instance.Name})
+}
+
+// ageCollector exposes the time since an event, computed when the metrics are collected.
+type ageCollector struct {
+	desc  *prometheus.Desc
+	mutex sync.Mutex
+	times map[string]ageSample
+}
+
+type ageSample struct {
+	labelValues []string
+	time        time.Time
+}
+
+func newAgeCollector(desc *prometheus.Desc) *ageCollector {
+	return &ageCollector{desc: desc, times: map[string]ageSample{}}
+}
+
+func (collector *ageCollector) set(labelValues []string, eventTime time.Time) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	collector.times[strings.Join(labelValues, "/")] = ageSample{labelValues, eventTime}
+}
+
+func (collector *ageCollector) delete(labelValues []string) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	delete(collector.times, strings.Join(labelValues, "/"))
+}
+
+func (collector *ageCollector) deletePrefix(labelValues []string) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	prefix := strings.Join(labelValues, "/") + "/"
+	for key := range collector.times {
+		if strings.HasPrefix(key, prefix) {
+			delete(collector.times, key)
+		}
+	}
+}
+
+func (collector *ageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.desc
+}
+
+func (collector *ageCollector) Collect(ch chan<- prometheus.Metric) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	for _, sample := range collector.times {
+		ch <- prometheus.MustNewConstMetric(
+			collector.desc,
+			prometheus.GaugeValue,
+			time.Since(sample.time).Seconds(),
+			sample.labelValues...,
+		)
+	}
+}
@@ -0,0 +1,285 @@
+package metrics_test
+
+import (
+	"errors"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// gather returns the series of a metric whose labels include the given ones.
+func gather(name string, labels map[string]string) []*dto.Metric {
+	families, err := ctrlmetrics.Registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	var series []*dto.Metric
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			matches := 0
+			for _, label := range metric.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value == label.GetValue() {
+					matches++
+				}
+			}
+			if matches == len(labels) {
+				series = append(series, metric)
+			}
+		}
+	}
+	return series
+}
+
+func gaugeValue(name string, labels map[string]string) float64 {
+	series := gather(name, labels)
+	Expect(series).To(HaveLen(1))
+	return series[0].GetGauge().GetValue()
+}
+
+var _ = Describe("Metrics", func() {
+	var instance *osrmv1alpha1.OSRMCluster
+	var clusterLabels map[string]string
+	var profileLabels map[string]string
+
+	BeforeEach(func() {
+		instance = &osrmv1alpha1.OSRMCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "metrics",
+			},
+		}
+		clusterLabels = map[string]string{"namespace": "metrics", "osrmcluster": "test"}
+		profileLabels = map[string]string{"namespace": "metrics", "osrmcluster": "test", "profile": "car"}
+	})
+
+	AfterEach(func() {
+		metrics.DeleteCluster(instance)
+	})
+
+	Context("ObserveReconcile", func() {
+		It("Should count reconciliations by result and observe their duration", func() {
+			metrics.ObserveReconcile(instance, time.Second, nil)
+			metrics.ObserveReconcile(instance, time.Second, nil)
+			metrics.ObserveReconcile(instance, 3*time.Second, errors.New("failed"))
+
+			success := gather("osrm_operator_reconcile_total", map[string]string{"osrmcluster": "test", "result": "success"})
+			Expect(success).To(HaveLen(1))
+			Expect(success[0].GetCounter().GetValue()).To(Equal(2.0))
+			failure := gather("osrm_operator_reconcile_total", map[string]string{"osrmcluster": "test", "result": "error"})
+			Expect(failure).To(HaveLen(1))
+			Expect(failure[0].GetCounter().GetValue()).To(Equal(1.0))
+
+			duration := gather("osrm_operator_reconcile_duration_seconds", clusterLabels)
+			Expect(duration).To(HaveLen(1))
+			Expect(duration[0].GetHistogram().GetSampleCount()).To(Equal(uint64(3)))
+			Expect(duration[0].GetHistogram().GetSampleSum()).To(Equal(5.0))
+		})
+	})
+
+	Context("RecordChildOperation", func() {
+		It("Should count child operations by kind and operation", func() {
+			metrics.RecordChildOperation(instance, "Deployment", "create")
+			metrics.RecordChildOperation(instance, "Deployment", "update")
+			metrics.RecordChildOperation(instance, "Deployment", "update")
+			metrics.RecordChildOperation(instance, "Job", "delete")
+
+			updates := gather("osrm_operator_child_operations_total", map[string]string{
+				"osrmcluster": "test",
+				"kind":        "Deployment",
+				"operation":   "update",
+			})
+			Expect(updates).To(HaveLen(1))
+			Expect(updates[0].GetCounter().GetValue()).To(Equal(2.0))
+			Expect(gather("osrm_operator_child_operations_total", clusterLabels)).To(HaveLen(3))
+		})
+	})
+
+	Context("RecordChildDrift", func() {
+		It("Should count drifted child resources by kind and policy", func() {
+			metrics.RecordChildDrift(instance, "Deployment", osrmv1alpha1.DriftPolicyCorrect)
+			metrics.RecordChildDrift(instance, "Deployment", osrmv1alpha1.DriftPolicyReport)
+			metrics.RecordChildDrift(instance, "Deployment", osrmv1alpha1.DriftPolicyReport)
+
+			reported := gather("osrm_operator_child_drift_total", map[string]string{
+				"osrmcluster": "test",
+				"kind":        "Deployment",
+				"policy":      "Report",
+			})
+			Expect(reported).To(HaveLen(1))
+			Expect(reported[0].GetCounter().GetValue()).To(Equal(2.0))
+			Expect(gather("osrm_operator_child_drift_total", clusterLabels)).To(HaveLen(2))
+		})
+	})
+
+	Context("SetProfileStatus", func() {
+		It("Should expose the map build phases and duration", func() {
+			start := metav1.NewTime(time.Now().Add(-time.Hour))
+			extracted := metav1.NewTime(start.Add(20 * time.Minute))
+			customized := metav1.NewTime(start.Add(30 * time.Minute))
+			metrics.SetProfileStatus(instance, &osrmv1alpha1.ProfileStatus{
+				Name: "car",
+				MapBuild: []osrmv1alpha1.MapBuildStageStatus{
+					{Stage: "extract", Phase: osrmv1alpha1.JobPhaseCompleted, StartTime: &start, CompletionTime: &extracted},
+					{Stage: "customize", Phase: osrmv1alpha1.JobPhaseCompleted, StartTime: &extracted, CompletionTime: &customized},
+				},
+			})
+
+			Expect(gaugeValue("osrm_operator_map_build_duration_seconds", profileLabels)).To(Equal(1800.0))
+			Expect(gaugeValue("osrm_operator_map_build_phase", map[string]string{
+				"profile": "car",
+				"stage":   "extract",
+				"phase":   string(osrmv1alpha1.JobPhaseCompleted),
+			})).To(Equal(1.0))
+			Expect(gaugeValue("osrm_operator_map_build_phase", map[string]string{
+				"profile": "car",
+				"stage":   "extract",
+				"phase":   string(osrmv1alpha1.JobPhaseRunning),
+			})).To(Equal(0.0))
+		})
+
+		It("Should not expose the duration of a map build that did not complete", func() {
+			start := metav1.NewTime(time.Now())
+			metrics.SetProfileStatus(instance, &osrmv1alpha1.ProfileStatus{
+				Name: "car",
+				MapBuild: []osrmv1alpha1.MapBuildStageStatus{
+					{Stage: "extract", Phase: osrmv1alpha1.JobPhaseRunning, StartTime: &start},
+				},
+			})
+
+			Expect(gather("osrm_operator_map_build_duration_seconds", profileLabels)).To(BeEmpty())
+		})
+
+		It("Should remove the phases of the stages that are no longer part of the map build", func() {
+			metrics.SetProfileStatus(instance, &osrmv1alpha1.ProfileStatus{
+				Name:     "car",
+				MapBuild: []osrmv1alpha1.MapBuildStageStatus{{Stage: "extract", Phase: osrmv1alpha1.JobPhaseRunning}},
+			})
+			metrics.SetProfileStatus(instance, &osrmv1alpha1.ProfileStatus{
+				Name:     "car",
+				MapBuild: []osrmv1alpha1.MapBuildStageStatus{{Stage: "download", Phase: osrmv1alpha1.JobPhaseRunning}},
+			})
+
+			Expect(gather("osrm_operator_map_build_phase", map[string]string{"profile": "car", "stage": "extract"})).To(BeEmpty())
+			Expect(gather("osrm_operator_map_build_phase", map[string]string{"profile": "car", "stage": "download"})).NotTo(BeEmpty())
+		})
+
+		It("Should expose the phase of the profile's switchover", func() {
+			instance.Status.Switchovers = []osrmv1alpha1.SwitchoverStatus{
+				{Profile: "car", Phase: osrmv1alpha1.SwitchoverPhaseShifting},
+			}
+			metrics.SetProfileStatus(instance, &osrmv1alpha1.ProfileStatus{Name: "car"})
+
+			Expect(gaugeValue("osrm_operator_switchover_phase", map[string]string{
+				"profile": "car",
+				"phase":   string(osrmv1alpha1.SwitchoverPhaseShifting),
+			})).To(Equal(1.0))
+			Expect(gaugeValue("osrm_operator_switchover_phase", map[string]string{
+				"profile": "car",
+				"phase":   string(osrmv1alpha1.SwitchoverPhaseDeploying),
+			})).To(Equal(0.0))
+		})
+	})
+
+	Context("SetReplicas", func() {
+		It("Should expose the desired and ready replicas of a profile", func() {
+			metrics.SetReplicas(instance, "car", 3, 2)
+
+			Expect(gaugeValue("osrm_operator_profile_replicas", profileLabels)).To(Equal(3.0))
+			Expect(gaugeValue("osrm_operator_profile_ready_replicas", profileLabels)).To(Equal(2.0))
+		})
+	})
+
+	Context("SetSpeedUpdateTime", func() {
+		It("Should expose the time since the last speed update", func() {
+			metrics.SetSpeedUpdateTime(instance, "car", time.Now().Add(-10*time.Minute))
+
+			Expect(gaugeValue("osrm_operator_speed_update_age_seconds", profileLabels)).To(BeNumerically("~", 600, 5))
+		})
+	})
+
+	Context("SetHealthCheck", func() {
+		It("Should expose the result of a health check until it is removed", func() {
+			distance := int32(95000)
+			metrics.SetHealthCheck(instance, &osrmv1alpha1.HealthCheckStatus{
+				Name:       "tel-aviv-haifa",
+				Profile:    "car",
+				Distance:   &distance,
+				Duration:   &metav1.Duration{Duration: 90 * time.Minute},
+				Latency:    &metav1.Duration{Duration: 20 * time.Millisecond},
+				Regression: osrmv1alpha1.HealthCheckRegressionMapBuild,
+			})
+
+			checkLabels := map[string]string{"osrmcluster": "test", "profile": "car", "check": "tel-aviv-haifa"}
+			Expect(gaugeValue("osrm_operator_health_check_healthy", checkLabels)).To(Equal(0.0))
+			Expect(gaugeValue("osrm_operator_health_check_regression", checkLabels)).To(Equal(1.0))
+			Expect(gaugeValue("osrm_operator_health_check_route_duration_seconds", checkLabels)).To(Equal(5400.0))
+			Expect(gaugeValue("osrm_operator_health_check_route_distance_meters", checkLabels)).To(Equal(95000.0))
+			Expect(gaugeValue("osrm_operator_health_check_latency_seconds", checkLabels)).To(Equal(0.02))
+
+			metrics.SetHealthCheck(instance, &osrmv1alpha1.HealthCheckStatus{
+				Name:    "tel-aviv-haifa",
+				Profile: "car",
+				Healthy: true,
+			})
+			Expect(gaugeValue("osrm_operator_health_check_healthy", checkLabels)).To(Equal(1.0))
+			Expect(gaugeValue("osrm_operator_health_check_regression", checkLabels)).To(Equal(0.0))
+			Expect(gather("osrm_operator_health_check_route_duration_seconds", checkLabels)).To(BeEmpty())
+
+			metrics.DeleteHealthCheck(instance, "tel-aviv-haifa")
+			Expect(gather("osrm_operator_health_check_healthy", checkLabels)).To(BeEmpty())
+		})
+	})
+
+	Context("SetProfiles", func() {
+		It("Should remove the series of the profiles that were removed", func() {
+			metrics.SetProfiles(instance, []string{"car", "foot"})
+			metrics.SetReplicas(instance, "car", 1, 1)
+			metrics.SetReplicas(instance, "foot", 1, 1)
+			metrics.SetSpeedUpdateTime(instance, "foot", time.Now())
+			metrics.SetHealthCheck(instance, &osrmv1alpha1.HealthCheckStatus{Name: "walk", Profile: "foot", Healthy: true})
+
+			metrics.SetProfiles(instance, []string{"car"})
+
+			footLabels := map[string]string{"osrmcluster": "test", "profile": "foot"}
+			for _, name := range []string{
+				"osrm_operator_profile_replicas",
+				"osrm_operator_speed_update_age_seconds",
+				"osrm_operator_health_check_healthy",
+			} {
+				Expect(gather(name, footLabels)).To(BeEmpty(), name)
+			}
+			Expect(gaugeValue("osrm_operator_profile_replicas", profileLabels)).To(Equal(1.0))
+		})
+	})
+
+	Context("DeleteCluster", func() {
+		It("Should remove all the series of an OSRMCluster", func() {
+			metrics.ObserveReconcile(instance, time.Second, nil)
+			metrics.RecordChildOperation(instance, "Service", "create")
+			metrics.RecordChildDrift(instance, "Service", osrmv1alpha1.DriftPolicyCorrect)
+			metrics.SetReplicas(instance, "car", 1, 1)
+			metrics.SetSpeedUpdateTime(instance, "car", time.Now())
+
+			metrics.DeleteCluster(instance)
+
+			for _, name := range []string{
+				"osrm_operator_reconcile_total",
+				"osrm_operator_reconcile_duration_seconds",
+				"osrm_operator_child_operations_total",
+				"osrm_operator_child_drift_total",
+				"osrm_operator_profile_replicas",
+				"osrm_operator_speed_update_age_seconds",
+			} {
+				Expect(gather(name, clusterLabels)).To(BeEmpty(), name)
+			}
+		})
+	})
+})
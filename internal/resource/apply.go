@@ -0,0 +1,509 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldManager is the field manager of the server-side applies of the operator.
+const FieldManager = "osrm-operator"
+
+// LegacyFieldManager is the field manager of the updates the operator made before
+// it used server-side apply. They set no field manager, so the API server named it
+// after the user agent of the operator, i.e. its binary: manager in the image of
+// the operator, and main under make run.
+var LegacyFieldManager = strings.SplitN(rest.DefaultKubernetesUserAgent(), "/", 2)[0]
+
+// fieldSet is a set of the fields of an object. The items of associative lists
+// are keyed as in the managed fields, e.g. k:{"name":"osrm"} or v:"--threads".
+// A field without subfields stands for its whole value, e.g. a scalar or an
+// atomic list.
+type fieldSet map[string]fieldSet
+
+// listKeys are the names of the key fields of the associative lists of an object
+// by their path, e.g. spec.template.spec.containers.env is keyed by name.
+type listKeys map[string][]string
+
+// ApplyConfiguration returns the configuration the operator applies to a child
+// resource. It holds the fields set by the builder of the child resource on a
+// new object, along with the fields the operator applied before, so the fields
+// the builder no longer sets are removed. Their values are those the builder
+// set on the existing object, which keep the immutable fields and the state
+// the builders read from it. The fields set by others, e.g. the replicas set
+// by an autoscaler, are left to their managers, and so are the items they add
+// to associative lists, e.g. an injected sidecar container or its environment.
+func ApplyConfiguration(
+	gvk schema.GroupVersionKind,
+	built client.Object,
+	updated client.Object,
+	managedFields []metav1.ManagedFieldsEntry,
+) (*unstructured.Unstructured, error) {
+	builtContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(built)
+	if err != nil {
+		return nil, err
+	}
+	updatedContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := fieldSet{}
+	keys := listKeys{}
+	for _, entry := range managedFields {
+		if entry.Subresource != "" || entry.FieldsV1 == nil {
+			continue
+		}
+		fields, err := parseFieldsV1(entry.FieldsV1.Raw, keys)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing the managed fields of %s %s: %v", gvk.Kind, built.GetName(), err)
+		}
+		if entry.Manager == FieldManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			owned = mergeFields(owned, fields)
+		}
+	}
+
+	configuration := &unstructured.Unstructured{Object: selectFields(updatedContent, builtContent, owned, keys, "")}
+	delete(configuration.Object, "status")
+	for _, field := range []string{"creationTimestamp", "generation", "managedFields", "resourceVersion", "uid"} {
+		unstructured.RemoveNestedField(configuration.Object, "metadata", field)
+	}
+	configuration.SetGroupVersionKind(gvk)
+	configuration.SetName(built.GetName())
+	configuration.SetNamespace(built.GetNamespace())
+	return configuration, nil
+}
+
+// UpgradeManagedFields moves the fields the operator updated on a child resource
+// before it used server-side apply to its field manager, so they are applied from
+// then on and those it no longer sets are removed. It returns false when there
+// are no such fields, e.g. once the child resource was upgraded.
+func UpgradeManagedFields(object client.Object) (bool, error) {
+	for _, entry := range object.GetManagedFields() {
+		if entry.Manager == LegacyFieldManager && entry.Operation == metav1.ManagedFieldsOperationUpdate && entry.Subresource == "" {
+			return true, csaupgrade.UpgradeManagedFields(object, sets.New(LegacyFieldManager), FieldManager)
+		}
+	}
+	return false, nil
+}
+
+// parseFieldsV1 returns the fields of a managed fields entry, and adds the key
+// fields of its associative lists to keys.
+func parseFieldsV1(raw []byte, keys listKeys) (fieldSet, error) {
+	content := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return nil, err
+	}
+	return fieldsOfFieldsV1(content, keys, ""), nil
+}
+
+func fieldsOfFieldsV1(content map[string]interface{}, keys listKeys, path string) fieldSet {
+	if len(content) == 0 {
+		// A field without subfields, e.g. a scalar.
+		return nil
+	}
+	fields := fieldSet{}
+	for key, value := range content {
+		nested, _ := value.(map[string]interface{})
+		switch {
+		case key == ".":
+		case strings.HasPrefix(key, "f:"):
+			name := strings.TrimPrefix(key, "f:")
+			fields[name] = fieldsOfFieldsV1(nested, keys, joinPath(path, name))
+		case strings.HasPrefix(key, "k:"):
+			// An item of an associative list, e.g. k:{"name":"osrm"}.
+			itemKey := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "k:")), &itemKey); err != nil {
+				return nil
+			}
+			if _, ok := keys[path]; !ok {
+				for name := range itemKey {
+					keys[path] = append(keys[path], name)
+				}
+				sort.Strings(keys[path])
+			}
+			fields[key] = fieldsOfFieldsV1(nested, keys, path)
+			if fields[key] == nil {
+				fields[key] = fieldSet{}
+			}
+		case strings.HasPrefix(key, "v:"):
+			// An item of a set, e.g. v:"--threads".
+			fields[key] = nil
+		default:
+			// The items of a list that are told apart by their index only.
+			return nil
+		}
+	}
+	return fields
+}
+
+// mergeFields returns the union of two field sets.
+func mergeFields(a, b fieldSet) fieldSet {
+	if a == nil || b == nil {
+		return nil
+	}
+	merged := fieldSet{}
+	for key, fields := range a {
+		merged[key] = fields
+	}
+	for key, fields := range b {
+		if existing, ok := merged[key]; ok {
+			merged[key] = mergeFields(existing, fields)
+		} else {
+			merged[key] = fields
+		}
+	}
+	return merged
+}
+
+// selectFields returns the values of the fields of an object that the builder
+// set on a new object, or that the operator owns. Maps and lists whose fields
+// are all unset are left out.
+func selectFields(
+	content map[string]interface{},
+	built map[string]interface{},
+	owned fieldSet,
+	keys listKeys,
+	path string,
+) map[string]interface{} {
+	names := map[string]bool{}
+	for name, value := range built {
+		if value != nil {
+			names[name] = true
+		}
+	}
+	for name := range owned {
+		names[name] = true
+	}
+
+	selected := map[string]interface{}{}
+	for name := range names {
+		value, ok := content[name]
+		if !ok || value == nil {
+			continue
+		}
+		ownedFields, isOwned := owned[name]
+		switch value := value.(type) {
+		case map[string]interface{}:
+			builtValue, _ := built[name].(map[string]interface{})
+			if isOwned && ownedFields == nil && builtValue == nil {
+				selected[name] = value
+			} else if nested := selectFields(value, builtValue, ownedFields, keys, joinPath(path, name)); len(nested) > 0 {
+				selected[name] = nested
+			}
+		case []interface{}:
+			builtValue, _ := built[name].([]interface{})
+			if items := selectItems(value, builtValue, ownedFields, keys, joinPath(path, name)); len(items) > 0 {
+				selected[name] = items
+			}
+		default:
+			selected[name] = value
+		}
+	}
+	return selected
+}
+
+// selectItems returns the items of a list that the builder set on a new object,
+// or that the operator owns, along with their fields. The items of associative
+// lists are told apart by their keys, which are learnt from the managed fields of
+// the object. Other lists, and lists whose keys are unknown, e.g. of a new object,
+// are atomic and selected as a whole.
+func selectItems(
+	list []interface{},
+	built []interface{},
+	owned fieldSet,
+	keys listKeys,
+	path string,
+) []interface{} {
+	isSet := false
+	for key := range owned {
+		isSet = isSet || strings.HasPrefix(key, "v:")
+	}
+	names := keys[path]
+	if len(names) == 0 && !isSet {
+		return list
+	}
+
+	selected := []interface{}{}
+	for _, item := range list {
+		if isSet {
+			if _, ok := owned["v:"+marshalJSON(item)]; ok || slices.ContainsFunc(built, func(builtItem interface{}) bool {
+				return reflect.DeepEqual(normalizeJSON(builtItem), normalizeJSON(item))
+			}) {
+				selected = append(selected, item)
+			}
+			continue
+		}
+
+		content, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ownedFields, isOwned := fieldSet(nil), false
+		for key, fields := range owned {
+			if itemKey, ok := strings.CutPrefix(key, "k:"); ok && hasItemKey(content, itemKey) {
+				ownedFields, isOwned = fields, true
+			}
+		}
+		var builtItem map[string]interface{}
+		for _, candidate := range built {
+			if candidate, ok := candidate.(map[string]interface{}); ok && isBuiltItem(content, candidate, names) {
+				builtItem = candidate
+				break
+			}
+		}
+		if !isOwned && builtItem == nil {
+			continue
+		}
+
+		selectedItem := selectFields(content, builtItem, ownedFields, keys, path)
+		// The key fields identify the item, so they are always applied.
+		for _, name := range names {
+			if value, ok := content[name]; ok {
+				selectedItem[name] = value
+			}
+		}
+		selected = append(selected, selectedItem)
+	}
+	return selected
+}
+
+// hasItemKey returns true when an item has the key of a managed fields entry,
+// e.g. {"containerPort":5000,"protocol":"TCP"}.
+func hasItemKey(item map[string]interface{}, itemKey string) bool {
+	key := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(itemKey), &key); err != nil {
+		return false
+	}
+	for name, value := range key {
+		if !reflect.DeepEqual(normalizeJSON(item[name]), value) {
+			return false
+		}
+	}
+	return true
+}
+
+// isBuiltItem returns true when an item has the key of an item the builder set.
+// Key fields the builder leaves unset are defaulted by the API server, e.g. the
+// protocol of a port, so they match any value.
+func isBuiltItem(item, builtItem map[string]interface{}, names []string) bool {
+	for _, name := range names {
+		if value, ok := builtItem[name]; ok && !reflect.DeepEqual(normalizeJSON(item[name]), normalizeJSON(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// normalizeJSON returns a value as it is decoded from JSON, e.g. with float64
+// numbers, so values of unstructured objects and managed fields compare equal.
+func normalizeJSON(value interface{}) interface{} {
+	var normalized interface{}
+	if err := json.Unmarshal([]byte(marshalJSON(value)), &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+func marshalJSON(value interface{}) string {
+	marshaled, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(marshaled)
+}
+
+// RemoveDrift removes the fields that drifted from the configuration of a child
+// resource, so the rest of it is applied without taking them over. A drifted list
+// item is removed along with its fields.
+func RemoveDrift(configuration *unstructured.Unstructured, drift []Drift) error {
+	for _, d := range drift {
+		if _, _, err := withoutField(configuration.Object, d.Field); err != nil {
+			return fmt.Errorf("failed removing the drifted field %s: %v", d.Field, err)
+		}
+	}
+	return nil
+}
+
+// withoutField returns a value without the field at a path of the conflicts of a
+// server-side apply, e.g. .spec.template.spec.containers[name="osrm"].image, and
+// false when the path is empty, i.e. the value itself is removed. Field names may
+// hold dots, e.g. those of annotations, so the longest name that prefixes the path
+// is taken. Fields that are not set are left as they are.
+func withoutField(value interface{}, path string) (interface{}, bool, error) {
+	if path == "" {
+		return nil, false, nil
+	}
+	switch path[0] {
+	case '.':
+		content, ok := value.(map[string]interface{})
+		if !ok {
+			return value, true, nil
+		}
+		path = path[1:]
+		name := ""
+		for key := range content {
+			if len(key) > len(name) && strings.HasPrefix(path, key) &&
+				(len(path) == len(key) || path[len(key)] == '.' || path[len(key)] == '[') {
+				name = key
+			}
+		}
+		if name == "" {
+			return value, true, nil
+		}
+		nested, keep, err := withoutField(content[name], path[len(name):])
+		if keep {
+			content[name] = nested
+		} else {
+			delete(content, name)
+		}
+		return content, true, err
+	case '[':
+		list, ok := value.([]interface{})
+		if !ok {
+			return value, true, nil
+		}
+		end := indexUnquoted(path, ']')
+		if end < 0 {
+			return value, true, fmt.Errorf("unterminated list item %s", path)
+		}
+		matches, err := listItemMatcher(path[1:end])
+		if err != nil {
+			return value, true, err
+		}
+		for i, item := range list {
+			if !matches(i, item) {
+				continue
+			}
+			nested, keep, err := withoutField(item, path[end+1:])
+			if keep {
+				list[i] = nested
+				return list, true, err
+			}
+			return slices.Delete(list, i, i+1), true, err
+		}
+		return value, true, nil
+	}
+	return value, true, fmt.Errorf("unexpected path %s", path)
+}
+
+// listItemMatcher returns a function that matches the list item of a path, given
+// by its index, e.g. [0], its value, e.g. [="--threads"], or its keys, e.g.
+// [containerPort=5000,protocol="TCP"]. The values are JSON.
+func listItemMatcher(selector string) (func(int, interface{}) bool, error) {
+	if index, err := strconv.Atoi(selector); err == nil {
+		return func(i int, _ interface{}) bool { return i == index }, nil
+	}
+	if value, ok := strings.CutPrefix(selector, "="); ok {
+		return func(_ int, item interface{}) bool { return equalJSON(item, value) }, nil
+	}
+
+	keys := map[string]string{}
+	for selector != "" {
+		end := indexUnquoted(selector, ',')
+		if end < 0 {
+			end = len(selector)
+		}
+		key, value, ok := strings.Cut(selector[:end], "=")
+		if !ok {
+			return nil, fmt.Errorf("unexpected list item key %s", selector[:end])
+		}
+		keys[key] = value
+		selector = strings.TrimPrefix(selector[end:], ",")
+	}
+	return func(_ int, item interface{}) bool {
+		content, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range keys {
+			if !equalJSON(content[key], value) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// indexUnquoted returns the index of the first occurrence of a character in a
+// path that is not within a JSON string, or -1.
+func indexUnquoted(path string, c byte) int {
+	quoted := false
+	for i := 0; i < len(path); i++ {
+		switch {
+		case quoted && path[i] == '\\':
+			i++
+		case path[i] == '"':
+			quoted = !quoted
+		case !quoted && path[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+// equalJSON returns true when a value equals a JSON value, e.g. "osrm" or 5000.
+func equalJSON(value interface{}, raw string) bool {
+	var expected interface{}
+	if err := json.Unmarshal([]byte(raw), &expected); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalizeJSON(value), expected)
+}
+
+// Drift is a field the operator sets on a child resource that was changed by another field manager.
+type Drift struct {
+	// Field is the path of the field, e.g. .spec.template.spec.containers[name="osrm"].image.
+	Field string
+	// Manager is the field manager that changed the field, e.g. kubectl-edit.
+	Manager string
+}
+
+func (drift Drift) String() string {
+	return fmt.Sprintf("%s (%s)", drift.Field, drift.Manager)
+}
+
+// DriftOf returns the drift reported by the conflicts of a server-side apply that
+// does not force the ownership of the fields it applies, and false when the apply
+// did not fail with conflicts.
+func DriftOf(err error) ([]Drift, bool) {
+	statusError, ok := err.(errors.APIStatus)
+	if !ok || !errors.IsConflict(err) || statusError.Status().Details == nil {
+		return nil, false
+	}
+
+	drift := []Drift{}
+	for _, cause := range statusError.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		// The message names the manager, e.g. conflict with "kubectl-edit" using apps/v1.
+		manager, err := strconv.QuotedPrefix(strings.TrimPrefix(cause.Message, "conflict with "))
+		if err == nil {
+			manager, _ = strconv.Unquote(manager)
+		}
+		drift = append(drift, Drift{Field: cause.Field, Manager: manager})
+	}
+	return drift, len(drift) > 0
+}
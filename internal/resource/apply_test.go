@@ -0,0 +1,307 @@
+package resource_test
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("ApplyConfiguration", func() {
+	var builder *resource.OSRMResourceBuilder
+	var resources []runtime.Object
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+		builder = &resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}
+		resources = generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+	})
+
+	build := func(resourceBuilder resource.ResourceBuilder) client.Object {
+		object, err := resourceBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resourceBuilder.Update(object, newChildResources(resources))).To(Succeed())
+		return object
+	}
+
+	It("Should apply the fields set by the builder to a new child resource", func() {
+		deployment := build(builder.Deployment(instance.Spec.Profiles[0]))
+
+		configuration, err := resource.ApplyConfiguration(appsv1.SchemeGroupVersion.WithKind("Deployment"), deployment, deployment, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configuration.GetAPIVersion()).To(Equal("apps/v1"))
+		Expect(configuration.GetKind()).To(Equal("Deployment"))
+		Expect(configuration.GetName()).To(Equal(deployment.GetName()))
+		Expect(configuration.GetLabels()).To(Equal(deployment.GetLabels()))
+		Expect(configuration.GetOwnerReferences()).To(HaveLen(1))
+		Expect(configuration.Object).NotTo(HaveKey("status"))
+		Expect(configuration.Object["metadata"]).NotTo(HaveKey("creationTimestamp"))
+		containers, _, _ := unstructured.NestedSlice(configuration.Object, "spec", "template", "spec", "containers")
+		Expect(containers).To(HaveLen(1))
+		_, found, _ := unstructured.NestedFieldNoCopy(configuration.Object, "spec", "replicas")
+		Expect(found).To(BeFalse())
+	})
+
+	It("Should leave the fields set by others on an existing child resource", func() {
+		deploymentBuilder := builder.Deployment(instance.Spec.Profiles[0])
+		deployment := build(deploymentBuilder)
+
+		replicas := int32(5)
+		live := deployment.DeepCopyObject().(*appsv1.Deployment)
+		live.CreationTimestamp = metav1.Now()
+		live.ResourceVersion = "42"
+		live.Annotations = map[string]string{"deployment.kubernetes.io/revision": "3"}
+		live.Spec.Replicas = &replicas
+		live.Spec.Template.Annotations = map[string]string{"kubectl.kubernetes.io/restartedAt": "2024-01-01T00:00:00Z"}
+		live.Spec.Template.Spec.Containers[0].Image = "osrm/osrm-backend:edited"
+		Expect(deploymentBuilder.Update(live, newChildResources(resources))).To(Succeed())
+
+		configuration, err := resource.ApplyConfiguration(appsv1.SchemeGroupVersion.WithKind("Deployment"), deployment, live, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configuration.GetAnnotations()).To(BeEmpty())
+		Expect(configuration.GetResourceVersion()).To(BeEmpty())
+		_, found, _ := unstructured.NestedFieldNoCopy(configuration.Object, "spec", "replicas")
+		Expect(found).To(BeFalse())
+		annotations, _, _ := unstructured.NestedStringMap(configuration.Object, "spec", "template", "metadata", "annotations")
+		Expect(annotations).NotTo(HaveKey("kubectl.kubernetes.io/restartedAt"))
+		containers, _, _ := unstructured.NestedSlice(configuration.Object, "spec", "template", "spec", "containers")
+		Expect(containers[0]).To(HaveKeyWithValue("image", deployment.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image))
+	})
+
+	It("Should keep the immutable pod template of an existing Job", func() {
+		jobBuilder := builder.Job(instance.Spec.Profiles[0])
+		job := build(jobBuilder)
+
+		live := job.DeepCopyObject().(*batchv1.Job)
+		live.CreationTimestamp = metav1.Now()
+		live.Spec.Template.Spec.Containers[0].Image = "osrm/osrm-backend:previous"
+		Expect(jobBuilder.Update(live, newChildResources(resources))).To(Succeed())
+
+		configuration, err := resource.ApplyConfiguration(batchv1.SchemeGroupVersion.WithKind("Job"), job, live, nil)
+		Expect(err).NotTo(HaveOccurred())
+		containers, _, _ := unstructured.NestedSlice(configuration.Object, "spec", "template", "spec", "containers")
+		Expect(containers[0]).To(HaveKeyWithValue("image", "osrm/osrm-backend:previous"))
+	})
+
+	It("Should keep applying the fields the operator owns", func() {
+		pvcBuilder := builder.PersistentVolumeClaim(instance.Spec.Profiles[0])
+		resources = nil
+		pvc := build(pvcBuilder)
+
+		live := pvc.DeepCopyObject().(*corev1.PersistentVolumeClaim)
+		live.CreationTimestamp = metav1.Now()
+		live.Annotations = map[string]string{
+			resource.MapDataBuiltAnnotation:            "2024-01-01T00:00:00Z",
+			"pv.kubernetes.io/bind-completed":          "yes",
+			"volume.kubernetes.io/storage-provisioner": "ebs.csi.aws.com",
+		}
+		Expect(pvcBuilder.Update(live, newChildResources(resources))).To(Succeed())
+		managedFields := []metav1.ManagedFieldsEntry{
+			{
+				Manager:   resource.FieldManager,
+				Operation: metav1.ManagedFieldsOperationApply,
+				FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:` +
+					resource.MapDataBuiltAnnotation + `":{}},"f:ownerReferences":{"k:{\"uid\":\"\"}":{}}}}`)},
+			},
+			{
+				Manager:   "kube-controller-manager",
+				Operation: metav1.ManagedFieldsOperationUpdate,
+				FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:pv.kubernetes.io/bind-completed":{}}}}`)},
+			},
+		}
+
+		configuration, err := resource.ApplyConfiguration(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"), pvc, live, managedFields)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configuration.GetAnnotations()).To(Equal(map[string]string{
+			resource.MapDataBuiltAnnotation: "2024-01-01T00:00:00Z",
+		}))
+		Expect(configuration.GetOwnerReferences()).To(HaveLen(1))
+	})
+
+	It("Should leave the list items set by others", func() {
+		deployment := build(builder.Deployment(instance.Spec.Profiles[0]))
+		container := deployment.(*appsv1.Deployment).Spec.Template.Spec.Containers[0]
+
+		live := deployment.DeepCopyObject().(*appsv1.Deployment)
+		live.CreationTimestamp = metav1.Now()
+		live.Spec.Template.Spec.Containers[0].Ports[0].Protocol = corev1.ProtocolTCP
+		live.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "INJECTED", Value: "true"}}
+		live.Spec.Template.Spec.Containers = append(live.Spec.Template.Spec.Containers, corev1.Container{
+			Name:  "istio-proxy",
+			Image: "istio/proxyv2",
+		})
+		managedFields := []metav1.ManagedFieldsEntry{
+			{
+				Manager:   resource.FieldManager,
+				Operation: metav1.ManagedFieldsOperationApply,
+				FieldsV1: &metav1.FieldsV1{Raw: []byte(fmt.Sprintf(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{`+
+					`"k:{\"name\":\"%s\"}":{".":{},"f:image":{},"f:name":{},"f:ports":{"k:{\"containerPort\":%d,\"protocol\":\"TCP\"}":{}}},`+
+					`"k:{\"name\":\"removed\"}":{".":{},"f:name":{}}}}}}}`, container.Name, container.Ports[0].ContainerPort))},
+			},
+			{
+				Manager:   "istio-sidecar-injector",
+				Operation: metav1.ManagedFieldsOperationUpdate,
+				FieldsV1: &metav1.FieldsV1{Raw: []byte(fmt.Sprintf(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{`+
+					`"k:{\"name\":\"%s\"}":{"f:env":{"k:{\"name\":\"INJECTED\"}":{".":{},"f:name":{},"f:value":{}}}},`+
+					`"k:{\"name\":\"istio-proxy\"}":{".":{},"f:image":{},"f:name":{}}}}}}}`, container.Name))},
+			},
+		}
+
+		configuration, err := resource.ApplyConfiguration(appsv1.SchemeGroupVersion.WithKind("Deployment"), deployment, live, managedFields)
+		Expect(err).NotTo(HaveOccurred())
+		containers, _, _ := unstructured.NestedSlice(configuration.Object, "spec", "template", "spec", "containers")
+		Expect(containers).To(HaveLen(1))
+		Expect(containers[0]).To(HaveKeyWithValue("name", container.Name))
+		Expect(containers[0]).To(HaveKeyWithValue("image", container.Image))
+		Expect(containers[0]).NotTo(HaveKey("env"))
+		ports, _, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "ports")
+		Expect(ports).To(HaveLen(len(container.Ports)))
+	})
+})
+
+var _ = Describe("DriftOf", func() {
+	It("Should return the fields changed by others", func() {
+		err := errors.NewApplyConflict([]metav1.StatusCause{
+			{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: `conflict with "kubectl-edit" using apps/v1 at 2024-01-01T00:00:00Z`,
+				Field:   `.spec.template.spec.containers[name="osrm"].image`,
+			},
+			{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: `conflict with "helm"`,
+				Field:   ".metadata.labels.app",
+			},
+		}, "Apply failed with 2 conflicts")
+
+		drift, ok := resource.DriftOf(err)
+		Expect(ok).To(BeTrue())
+		Expect(drift).To(Equal([]resource.Drift{
+			{Field: `.spec.template.spec.containers[name="osrm"].image`, Manager: "kubectl-edit"},
+			{Field: ".metadata.labels.app", Manager: "helm"},
+		}))
+		Expect(drift[0].String()).To(Equal(`.spec.template.spec.containers[name="osrm"].image (kubectl-edit)`))
+	})
+
+	It("Should report the conflicts with the updates the operator made before it used server-side apply", func() {
+		err := errors.NewApplyConflict([]metav1.StatusCause{
+			{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: fmt.Sprintf(`conflict with %q using apps/v1`, resource.LegacyFieldManager),
+				Field:   `.spec.template.spec.containers[name="osrm"].image`,
+			},
+		}, "Apply failed with 1 conflict")
+
+		drift, ok := resource.DriftOf(err)
+		Expect(ok).To(BeTrue())
+		Expect(drift).To(Equal([]resource.Drift{
+			{Field: `.spec.template.spec.containers[name="osrm"].image`, Manager: resource.LegacyFieldManager},
+		}))
+	})
+
+	It("Should return false for other errors", func() {
+		_, ok := resource.DriftOf(nil)
+		Expect(ok).To(BeFalse())
+		_, ok = resource.DriftOf(errors.NewConflict(appsv1.Resource("deployments"), "test", nil))
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("RemoveDrift", func() {
+	It("Should keep applying the fields that did not drift", func() {
+		scheme := runtime.NewScheme()
+		Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+		deploymentBuilder := (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Deployment(instance.Spec.Profiles[0])
+		deployment, err := deploymentBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deploymentBuilder.Update(deployment, newChildResources(nil))).To(Succeed())
+		deployment.SetAnnotations(map[string]string{"osrmcluster.itayankri/owner": "osrm"})
+
+		// The spec changed the resources of the workers after their image was edited.
+		container := &deployment.(*appsv1.Deployment).Spec.Template.Spec.Containers[0]
+		container.Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: k8sresource.MustParse("2Gi")}
+		container.Ports[0].Protocol = corev1.ProtocolTCP
+		configuration, err := resource.ApplyConfiguration(appsv1.SchemeGroupVersion.WithKind("Deployment"), deployment, deployment, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(resource.RemoveDrift(configuration, []resource.Drift{
+			{Field: fmt.Sprintf(`.spec.template.spec.containers[name=%q].image`, container.Name), Manager: "kubectl-edit"},
+			{Field: fmt.Sprintf(`.spec.template.spec.containers[name=%q].ports[containerPort=%d,protocol="TCP"]`,
+				container.Name, container.Ports[0].ContainerPort), Manager: "kubectl-edit"},
+			{Field: ".metadata.annotations.osrmcluster.itayankri/owner", Manager: "helm"},
+		})).To(Succeed())
+
+		Expect(configuration.GetAnnotations()).To(BeEmpty())
+		containers, _, _ := unstructured.NestedSlice(configuration.Object, "spec", "template", "spec", "containers")
+		Expect(containers).To(HaveLen(1))
+		Expect(containers[0]).NotTo(HaveKey("image"))
+		Expect(containers[0]).To(HaveKeyWithValue("name", container.Name))
+		Expect(containers[0]).To(HaveKeyWithValue("ports", HaveLen(len(container.Ports)-1)))
+		memory, _, _ := unstructured.NestedString(containers[0].(map[string]interface{}), "resources", "limits", "memory")
+		Expect(memory).To(Equal("2Gi"))
+	})
+
+	It("Should leave the configuration as it is when a drifted field is not in it", func() {
+		configuration := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"replicas": int64(2)},
+		}}
+		Expect(resource.RemoveDrift(configuration, []resource.Drift{
+			{Field: ".spec.template.spec.containers[name=\"osrm\"].image", Manager: "kubectl-edit"},
+			{Field: ".metadata.labels.app", Manager: "helm"},
+		})).To(Succeed())
+		Expect(configuration.Object).To(Equal(map[string]interface{}{
+			"spec": map[string]interface{}{"replicas": int64(2)},
+		}))
+	})
+})
+
+var _ = Describe("UpgradeManagedFields", func() {
+	It("Should move the fields the operator updated before it used server-side apply to its field manager", func() {
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{
+			{
+				Manager:    resource.LegacyFieldManager,
+				Operation:  metav1.ManagedFieldsOperationUpdate,
+				APIVersion: "apps/v1",
+				FieldsType: "FieldsV1",
+				FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+			},
+			{
+				Manager:    "kubectl-edit",
+				Operation:  metav1.ManagedFieldsOperationUpdate,
+				APIVersion: "apps/v1",
+				FieldsType: "FieldsV1",
+				FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:app":{}}}}`)},
+			},
+		}}}
+
+		upgraded, err := resource.UpgradeManagedFields(deployment)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(upgraded).To(BeTrue())
+		Expect(deployment.ManagedFields).To(HaveLen(2))
+		Expect(deployment.ManagedFields).To(ContainElement(SatisfyAll(
+			HaveField("Manager", resource.FieldManager),
+			HaveField("Operation", metav1.ManagedFieldsOperationApply),
+			HaveField("FieldsV1.Raw", MatchJSON(`{"f:spec":{"f:replicas":{}}}`)),
+		)))
+		Expect(deployment.ManagedFields).To(ContainElement(HaveField("Manager", "kubectl-edit")))
+
+		upgraded, err = resource.UpgradeManagedFields(deployment)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(upgraded).To(BeFalse())
+	})
+})
@@ -0,0 +1,157 @@
+package resource
+
+import (
+	"fmt"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// GreenDeploymentBuilder builds the Deployment that serves a profile's new map
+// data while the gateway shifts the profile's requests to it during a blue/green switchover.
+type GreenDeploymentBuilder struct {
+	ProfileScopedBuilder
+	*OSRMResourceBuilder
+}
+
+// GreenServiceBuilder builds the Service the gateway sends the green Deployment's share of requests to.
+type GreenServiceBuilder struct {
+	ProfileScopedBuilder
+	*OSRMResourceBuilder
+}
+
+func (builder *OSRMResourceBuilder) GreenDeployment(profile *osrmv1alpha1.ProfileSpec) *GreenDeploymentBuilder {
+	return &GreenDeploymentBuilder{
+		ProfileScopedBuilder{profile},
+		builder,
+	}
+}
+
+func (builder *OSRMResourceBuilder) GreenService(profile *osrmv1alpha1.ProfileSpec) *GreenServiceBuilder {
+	return &GreenServiceBuilder{
+		ProfileScopedBuilder{profile},
+		builder,
+	}
+}
+
+func (builder *GreenDeploymentBuilder) Build() (client.Object, error) {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(builder.profile.Name, GreenSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
+		},
+	}, nil
+}
+
+func (builder *GreenDeploymentBuilder) Update(object client.Object, siblings *ChildResources) error {
+	deployment := object.(*appsv1.Deployment)
+	replicas := builder.greenReplicas(siblings)
+
+	builder.Deployment(builder.profile).updateDeployment(
+		deployment,
+		builder.Instance.ChildResourceName(builder.profile.Name, GreenSuffix),
+		siblings,
+	)
+	deployment.Spec.Replicas = &replicas
+
+	if err := controllerutil.SetControllerReference(builder.Instance, deployment, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+// greenReplicas matches the replicas of the profile's Deployment, so the green
+// Deployment can serve all of the profile's requests.
+func (builder *GreenDeploymentBuilder) greenReplicas(siblings *ChildResources) int32 {
+	if deployment := status.GetDeployment(builder.Instance.ChildResourceName(builder.profile.Name, DeploymentSuffix), siblings.Of(ProfileKey(builder.profile.Name))); deployment != nil &&
+		deployment.Spec.Replicas != nil {
+		return *deployment.Spec.Replicas
+	}
+	if builder.profile.MinReplicas != nil {
+		return *builder.profile.MinReplicas
+	}
+	return 1
+}
+
+func (builder *GreenDeploymentBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.isProfileDataReady(builder.profile, resources) &&
+		builder.Instance.Status.GetSwitchover(builder.profile.Name).IsInProgress()
+}
+
+func (builder *GreenServiceBuilder) Build() (client.Object, error) {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(builder.profile.Name, GreenSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
+		},
+	}, nil
+}
+
+func (builder *GreenServiceBuilder) Update(object client.Object, siblings *ChildResources) error {
+	service := object.(*corev1.Service)
+	service.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
+	updateProfileService(service, builder.Instance.ChildResourceName(builder.profile.Name, GreenSuffix))
+
+	if err := controllerutil.SetControllerReference(builder.Instance, service, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *GreenServiceBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.isProfileDataReady(builder.profile, resources) &&
+		builder.Instance.Status.GetSwitchover(builder.profile.Name).IsInProgress()
+}
+
+// PendingMapBuild returns the completion time of a profile's latest map build
+// when the profile's Deployment still serves an earlier one, or nil.
+func (builder *OSRMResourceBuilder) PendingMapBuild(profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) *metav1.Time {
+	lastMapBuildTime := builder.LastMapBuildTime(profile, resources)
+	deployment := status.GetDeployment(builder.Instance.ChildResourceName(profile.Name, DeploymentSuffix), resources.Of(ProfileKey(profile.Name)))
+	if lastMapBuildTime == nil || deployment == nil {
+		return nil
+	}
+	servedMapBuildTime := deployment.Spec.Template.ObjectMeta.Annotations[LastMapBuildTimeAnnotation]
+	if servedMapBuildTime == "" || servedMapBuildTime == lastMapBuildTime.Format(time.RFC3339) {
+		return nil
+	}
+	return lastMapBuildTime
+}
+
+// isMapBuildSwitchedOver returns true when the workers of a profile may move to
+// the map build in the pod template of its Deployment: always with the
+// RollingUpdate strategy, and with the BlueGreen strategy once the switchover
+// to that map build has shifted all of the profile's requests to the green Deployment.
+func isMapBuildSwitchedOver(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec, deployment *appsv1.Deployment) bool {
+	if !instance.Spec.Rollout.IsBlueGreen() {
+		return true
+	}
+	switchover := instance.Status.GetSwitchover(profile.Name)
+	return switchover != nil &&
+		switchover.MapBuildTime != nil &&
+		switchover.MapBuildTime.Format(time.RFC3339) == deployment.Spec.Template.ObjectMeta.Annotations[LastMapBuildTimeAnnotation] &&
+		(switchover.Phase == osrmv1alpha1.SwitchoverPhaseCompleting || switchover.Phase == osrmv1alpha1.SwitchoverPhaseCompleted)
+}
+
+// IsDeploymentRolledOut returns true once every replica of a Deployment runs its latest pod template and is available.
+func IsDeploymentRolledOut(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.Replicas == replicas &&
+		deployment.Status.AvailableReplicas == replicas
+}
@@ -0,0 +1,130 @@
+package resource_test
+
+import (
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Blue/green switchover", func() {
+	var builder *resource.OSRMResourceBuilder
+	var resources []runtime.Object
+	var deployment *appsv1.Deployment
+	servedMapBuildTime := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	newMapBuildTime := metav1.NewTime(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+		builder = &resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}
+
+		strategy := osrmv1alpha1.RolloutStrategyBlueGreen
+		instance.Spec.Rollout = &osrmv1alpha1.RolloutSpec{Strategy: &strategy}
+
+		replicas := int32(3)
+		deployment = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              instance.ChildResourceName(instance.Spec.Profiles[0].Name, resource.DeploymentSuffix),
+				CreationTimestamp: metav1.Now(),
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							resource.LastMapBuildTimeAnnotation: servedMapBuildTime.Format(time.RFC3339),
+						},
+					},
+				},
+			},
+		}
+		resources = append(generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name), deployment)
+		resources[0].(*batchv1.Job).Status.CompletionTime = &newMapBuildTime
+	})
+
+	AfterEach(func() {
+		instance.Spec.Rollout = nil
+		instance.Status.Switchovers = nil
+	})
+
+	It("Should report the map build the workers do not serve yet", func() {
+		Expect(builder.PendingMapBuild(instance.Spec.Profiles[0], newChildResources(resources)).Equal(&newMapBuildTime)).To(BeTrue())
+
+		resources[0].(*batchv1.Job).Status.CompletionTime = &servedMapBuildTime
+		Expect(builder.PendingMapBuild(instance.Spec.Profiles[0], newChildResources(resources))).To(BeNil())
+	})
+
+	It("Should keep the workers on the map build they serve until the switchover completes", func() {
+		deploymentBuilder := builder.Deployment(instance.Spec.Profiles[0])
+		Expect(deploymentBuilder.Update(deployment, newChildResources(resources))).To(Succeed())
+		Expect(deployment.Spec.Template.Annotations[resource.LastMapBuildTimeAnnotation]).To(Equal(servedMapBuildTime.Format(time.RFC3339)))
+
+		instance.Status.Switchovers = []osrmv1alpha1.SwitchoverStatus{{
+			Profile:      instance.Spec.Profiles[0].Name,
+			MapBuildTime: &newMapBuildTime,
+			Phase:        osrmv1alpha1.SwitchoverPhaseCompleting,
+			Weight:       100,
+		}}
+		Expect(deploymentBuilder.Update(deployment, newChildResources(resources))).To(Succeed())
+		Expect(deployment.Spec.Template.Annotations[resource.LastMapBuildTimeAnnotation]).To(Equal(newMapBuildTime.Format(time.RFC3339)))
+	})
+
+	It("Should serve the new map data with as many replicas as the workers", func() {
+		greenBuilder := builder.GreenDeployment(instance.Spec.Profiles[0])
+		Expect(greenBuilder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+
+		instance.Status.Switchovers = []osrmv1alpha1.SwitchoverStatus{{
+			Profile:      instance.Spec.Profiles[0].Name,
+			MapBuildTime: &newMapBuildTime,
+			Phase:        osrmv1alpha1.SwitchoverPhaseDeploying,
+		}}
+		Expect(greenBuilder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
+		Expect(builder.GreenService(instance.Spec.Profiles[0]).ShouldDeploy(newChildResources(resources))).To(Equal(true))
+
+		obj, err := greenBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(greenBuilder.Update(obj, newChildResources(resources))).To(Succeed())
+		green := obj.(*appsv1.Deployment)
+		Expect(green.Name).To(Equal("test-car-green"))
+		Expect(*green.Spec.Replicas).To(Equal(int32(3)))
+		Expect(green.Spec.Selector.MatchLabels).To(Equal(map[string]string{"app": "test-car-green"}))
+		Expect(green.Spec.Template.Annotations[resource.LastMapBuildTimeAnnotation]).To(Equal(newMapBuildTime.Format(time.RFC3339)))
+	})
+
+	It("Should send the switchover's share of requests to the green Deployment from the gateway", func() {
+		instance.Status.Switchovers = []osrmv1alpha1.SwitchoverStatus{{
+			Profile:      instance.Spec.Profiles[0].Name,
+			MapBuildTime: &newMapBuildTime,
+			Phase:        osrmv1alpha1.SwitchoverPhaseShifting,
+			Weight:       50,
+		}}
+
+		configMapBuilder := builder.ConfigMap(instance.Spec.Profiles)
+		obj, err := configMapBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configMapBuilder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+
+		configMap := obj.(*corev1.ConfigMap)
+		Expect(configMap.Data["nginx.tmpl"]).To(ContainSubstring(`split_clients "${request_id}" $osrm_green_car {`))
+		Expect(configMap.Data["nginx.tmpl"]).To(ContainSubstring("50% green;"))
+		Expect(configMap.Data["nginx.tmpl"]).To(ContainSubstring("${TEST_CAR_GREEN_SERVICE_HOST}"))
+		Expect(configMap.Data["nginx.tmpl"]).NotTo(ContainSubstring("js_import"))
+		Expect(resource.IsGatewayRoutingToGreen(instance, instance.Spec.Profiles[0], newChildResources([]runtime.Object{configMap}))).To(Equal(true))
+
+		instance.Status.Switchovers[0].Phase = osrmv1alpha1.SwitchoverPhaseAborted
+		instance.Status.Switchovers[0].Weight = 0
+		Expect(configMapBuilder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+		Expect(configMap.Data["nginx.tmpl"]).NotTo(ContainSubstring("green"))
+		Expect(resource.IsGatewayRoutingToGreen(instance, instance.Spec.Profiles[0], newChildResources([]runtime.Object{configMap}))).To(Equal(false))
+	})
+})
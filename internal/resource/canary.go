@@ -0,0 +1,233 @@
+package resource
+
+import (
+	"fmt"
+	"path"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// CanaryDeploymentBuilder builds the Deployment that serves the canary profile's
+// map data built with the new OSRM release while a canary upgrade is analyzed.
+type CanaryDeploymentBuilder struct {
+	ProfileScopedBuilder
+	*OSRMResourceBuilder
+}
+
+// CanaryServiceBuilder builds the Service the gateway sends the canary's share of requests to.
+type CanaryServiceBuilder struct {
+	ProfileScopedBuilder
+	*OSRMResourceBuilder
+}
+
+// CanaryCleanupJobBuilder builds the Job that removes the canary's map data from
+// the map data volume once the canary upgrade ended and the canary no longer serves it.
+type CanaryCleanupJobBuilder struct {
+	ProfileScopedBuilder
+	*OSRMResourceBuilder
+}
+
+func (builder *OSRMResourceBuilder) CanaryJob(profile *osrmv1alpha1.ProfileSpec) *JobBuilder {
+	return &JobBuilder{
+		ProfileScopedBuilder: ProfileScopedBuilder{profile},
+		OSRMResourceBuilder:  builder,
+		canary:               true,
+	}
+}
+
+func (builder *OSRMResourceBuilder) CanaryDeployment(profile *osrmv1alpha1.ProfileSpec) *CanaryDeploymentBuilder {
+	return &CanaryDeploymentBuilder{
+		ProfileScopedBuilder{profile},
+		builder,
+	}
+}
+
+func (builder *OSRMResourceBuilder) CanaryCleanupJob(profile *osrmv1alpha1.ProfileSpec) *CanaryCleanupJobBuilder {
+	return &CanaryCleanupJobBuilder{
+		ProfileScopedBuilder{profile},
+		builder,
+	}
+}
+
+func (builder *OSRMResourceBuilder) CanaryService(profile *osrmv1alpha1.ProfileSpec) *CanaryServiceBuilder {
+	return &CanaryServiceBuilder{
+		ProfileScopedBuilder{profile},
+		builder,
+	}
+}
+
+func (builder *CanaryDeploymentBuilder) Build() (client.Object, error) {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(builder.profile.Name, CanarySuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
+		},
+	}, nil
+}
+
+func (builder *CanaryDeploymentBuilder) Update(object client.Object, siblings *ChildResources) error {
+	deployment := object.(*appsv1.Deployment)
+	replicas := canaryReplicas(builder.profile, builder.Instance.Spec.Canary.GetWeight())
+
+	canaryBuilder := &DeploymentBuilder{
+		ProfileScopedBuilder: builder.ProfileScopedBuilder,
+		OSRMResourceBuilder:  builder.OSRMResourceBuilder,
+		canary:               true,
+	}
+	canaryBuilder.updateDeployment(deployment, builder.Instance.ChildResourceName(builder.profile.Name, CanarySuffix), siblings)
+	deployment.Spec.Replicas = &replicas
+
+	if err := controllerutil.SetControllerReference(builder.Instance, deployment, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *CanaryDeploymentBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return isCanaryProfile(builder.Instance, builder.profile, osrmv1alpha1.OSRMUpgradePhaseAnalyzing) &&
+		status.IsJobCompleted(builder.Instance.ChildResourceName(builder.profile.Name, CanaryJobSuffix), resources.Of(ProfileKey(builder.profile.Name)))
+}
+
+func (builder *CanaryServiceBuilder) Build() (client.Object, error) {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(builder.profile.Name, CanarySuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
+		},
+	}, nil
+}
+
+func (builder *CanaryServiceBuilder) Update(object client.Object, siblings *ChildResources) error {
+	service := object.(*corev1.Service)
+	service.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
+	updateProfileService(service, builder.Instance.ChildResourceName(builder.profile.Name, CanarySuffix))
+
+	if err := controllerutil.SetControllerReference(builder.Instance, service, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *CanaryServiceBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return isCanaryProfile(builder.Instance, builder.profile, osrmv1alpha1.OSRMUpgradePhaseAnalyzing) &&
+		status.IsJobCompleted(builder.Instance.ChildResourceName(builder.profile.Name, CanaryJobSuffix), resources.Of(ProfileKey(builder.profile.Name)))
+}
+
+func (builder *CanaryCleanupJobBuilder) Build() (client.Object, error) {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(builder.profile.Name, CanaryCleanupJobSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
+		},
+	}, nil
+}
+
+func (builder *CanaryCleanupJobBuilder) Update(object client.Object, siblings *ChildResources) error {
+	job := object.(*batchv1.Job)
+
+	job.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
+
+	// The pod template of a Job is immutable, so it is only set on creation.
+	if job.CreationTimestamp.IsZero() {
+		job.Spec = batchv1.JobSpec{
+			Selector: job.Spec.Selector,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: job.Spec.Template.ObjectMeta.Labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+					Containers: []corev1.Container{
+						{
+							Name:  builder.Instance.ChildResourceName(builder.profile.Name, CanaryCleanupJobSuffix),
+							Image: builder.Instance.Spec.GetImage(),
+							Command: []string{
+								"/bin/sh",
+								"-c",
+							},
+							Args: []string{
+								fmt.Sprintf("rm -rf %s", path.Join(osrmDataPath, osrmCanaryData)),
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      osrmDataVolumeName,
+									MountPath: osrmDataPath,
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: osrmDataVolumeName,
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	if err := controllerutil.SetControllerReference(builder.Instance, job, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+// ShouldDeploy returns true once the canary upgrade of the profile ended and the
+// canary Deployment is gone, until the canary map build Job is deleted.
+func (builder *CanaryCleanupJobBuilder) ShouldDeploy(resources *ChildResources) bool {
+	upgrade := builder.Instance.Status.OSRMUpgrade
+	return upgrade != nil &&
+		upgrade.Profile == builder.profile.Name &&
+		upgrade.Phase != osrmv1alpha1.OSRMUpgradePhaseBuilding &&
+		upgrade.Phase != osrmv1alpha1.OSRMUpgradePhaseAnalyzing &&
+		!IsGatewayRoutingToCanary(builder.Instance, resources) &&
+		status.GetDeployment(builder.Instance.ChildResourceName(builder.profile.Name, CanarySuffix), resources.Of(ProfileKey(builder.profile.Name))) == nil &&
+		status.GetJob(builder.Instance.ChildResourceName(builder.profile.Name, CanaryJobSuffix), resources.Of(ProfileKey(builder.profile.Name))) != nil
+}
+
+// isCanaryProfile returns true when a canary upgrade of the profile is in one of the phases.
+func isCanaryProfile(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec, phases ...osrmv1alpha1.OSRMUpgradePhase) bool {
+	upgrade := instance.Status.OSRMUpgrade
+	if upgrade == nil || upgrade.Profile != profile.Name || upgrade.Version != instance.Spec.GetOSRMVersion() {
+		return false
+	}
+	for _, phase := range phases {
+		if upgrade.Phase == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// canaryReplicas returns the share of the profile's minimum replicas that matches
+// the canary's share of requests, and at least one.
+func canaryReplicas(profile *osrmv1alpha1.ProfileSpec, weight int32) int32 {
+	minReplicas := int32(1)
+	if profile.MinReplicas != nil {
+		minReplicas = *profile.MinReplicas
+	}
+	replicas := (minReplicas*weight + 99) / 100
+	if replicas < 1 {
+		return 1
+	}
+	return replicas
+}
@@ -0,0 +1,175 @@
+package resource_test
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Canary builders", func() {
+	var builder *resource.OSRMResourceBuilder
+	var canaryJob *batchv1.Job
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+		builder = &resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}
+
+		osrmVersion := "v6.0.0"
+		policy := osrmv1alpha1.OSRMVersionUpgradePolicyCanary
+		instance.Spec.OSRMVersion = &osrmVersion
+		instance.Spec.OSRMVersionUpgradePolicy = &policy
+		instance.Status.OSRMUpgrade = &osrmv1alpha1.OSRMUpgradeStatus{
+			Version:     osrmVersion,
+			FromVersion: "v5.27.1",
+			Profile:     instance.Spec.Profiles[0].Name,
+			Phase:       osrmv1alpha1.OSRMUpgradePhaseBuilding,
+		}
+
+		canaryJob = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("%s-%s-%s", instance.Name, instance.Spec.Profiles[0].Name, resource.CanaryJobSuffix),
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{
+					{
+						Type:   batchv1.JobComplete,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		instance.Spec.OSRMVersion = nil
+		instance.Spec.OSRMVersionUpgradePolicy = nil
+		instance.Status.OSRMUpgrade = nil
+	})
+
+	It("Should build the canary profile's map data into a separate directory", func() {
+		jobBuilder := builder.CanaryJob(instance.Spec.Profiles[0])
+		Expect(jobBuilder.ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(true))
+
+		obj, err := jobBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(jobBuilder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+		container := obj.(*batchv1.Job).Spec.Template.Spec.Containers[0]
+		Expect(container.Image).To(Equal("itayankri/osrm-builder:osrm-v6.0.0"))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "ROOT_DIR", Value: "/data/canary"}))
+
+		instance.Status.OSRMUpgrade.Phase = osrmv1alpha1.OSRMUpgradePhaseRolledBack
+		Expect(jobBuilder.ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(false))
+	})
+
+	It("Should serve the canary once its map data is built", func() {
+		deploymentBuilder := builder.CanaryDeployment(instance.Spec.Profiles[0])
+		Expect(deploymentBuilder.ShouldDeploy(newChildResources([]runtime.Object{canaryJob}))).To(Equal(false))
+
+		instance.Status.OSRMUpgrade.Phase = osrmv1alpha1.OSRMUpgradePhaseAnalyzing
+		Expect(deploymentBuilder.ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(false))
+		Expect(deploymentBuilder.ShouldDeploy(newChildResources([]runtime.Object{canaryJob}))).To(Equal(true))
+
+		obj, err := deploymentBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deploymentBuilder.Update(obj, newChildResources([]runtime.Object{canaryJob}))).To(Succeed())
+		deployment := obj.(*appsv1.Deployment)
+		Expect(*deployment.Spec.Replicas).To(Equal(int32(1)))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("ghcr.io/project-osrm/osrm-backend:v6.0.0"))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Args[0]).To(ContainSubstring("/data/canary/customized"))
+	})
+
+	It("Should copy the canary's map data onto a local volume in local worker storage mode", func() {
+		instance.Status.OSRMUpgrade.Phase = osrmv1alpha1.OSRMUpgradePhaseAnalyzing
+		instance.Spec.Persistence.WorkerStorage = &osrmv1alpha1.WorkerStorageSpec{Mode: osrmv1alpha1.WorkerStorageModeLocal}
+		instance.Spec.ObjectStorage = &osrmv1alpha1.ObjectStorageSpec{Bucket: "osrm", Upload: true, Hydrate: true}
+		defer func() {
+			instance.Spec.Persistence.WorkerStorage = nil
+			instance.Spec.ObjectStorage = nil
+		}()
+
+		deploymentBuilder := builder.CanaryDeployment(instance.Spec.Profiles[0])
+		obj, err := deploymentBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deploymentBuilder.Update(obj, newChildResources([]runtime.Object{canaryJob}))).To(Succeed())
+
+		podSpec := obj.(*appsv1.Deployment).Spec.Template.Spec
+		Expect(podSpec.InitContainers).To(HaveLen(1))
+		Expect(podSpec.InitContainers[0].Args[0]).To(ContainSubstring("cp -r /shared/canary/customized /data/customized.tmp"))
+		Expect(podSpec.Containers[0].Args[0]).To(ContainSubstring("cd /data/customized"))
+		Expect(podSpec.Volumes[0].EmptyDir).NotTo(BeNil())
+		Expect(podSpec.Volumes[1].PersistentVolumeClaim.ClaimName).To(Equal("test-car"))
+	})
+
+	It("Should remove the canary's map data once the upgrade ended and the canary is gone", func() {
+		cleanupJobBuilder := builder.CanaryCleanupJob(instance.Spec.Profiles[0])
+		Expect(cleanupJobBuilder.ShouldDeploy(newChildResources([]runtime.Object{canaryJob}))).To(Equal(false))
+
+		instance.Status.OSRMUpgrade.Phase = osrmv1alpha1.OSRMUpgradePhaseRolledBack
+		canaryDeployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s-%s-%s", instance.Name, instance.Spec.Profiles[0].Name, resource.CanarySuffix),
+		}}
+		Expect(cleanupJobBuilder.ShouldDeploy(newChildResources([]runtime.Object{canaryJob, canaryDeployment}))).To(Equal(false))
+		Expect(cleanupJobBuilder.ShouldDeploy(newChildResources([]runtime.Object{canaryJob}))).To(Equal(true))
+		Expect(cleanupJobBuilder.ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(false))
+
+		obj, err := cleanupJobBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cleanupJobBuilder.Update(obj, newChildResources([]runtime.Object{canaryJob}))).To(Succeed())
+		container := obj.(*batchv1.Job).Spec.Template.Spec.Containers[0]
+		Expect(container.Args).To(Equal([]string{"rm -rf /data/canary"}))
+	})
+
+	It("Should keep serving the other workers with the release their map data was built with", func() {
+		resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+		resources[0].(*batchv1.Job).Annotations = map[string]string{resource.OSRMVersionAnnotation: "v6.0.0-rc.1"}
+
+		deploymentBuilder := builder.Deployment(instance.Spec.Profiles[0])
+		obj, err := deploymentBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deploymentBuilder.Update(obj, newChildResources(resources))).To(Succeed())
+		Expect(obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image).To(Equal("ghcr.io/project-osrm/osrm-backend:v6.0.0-rc.1"))
+	})
+
+	It("Should send the canary's share of requests to it from the gateway", func() {
+		instance.Status.OSRMUpgrade.Phase = osrmv1alpha1.OSRMUpgradePhaseAnalyzing
+		instance.Status.OSRMUpgrade.RoutingStartTime = &metav1.Time{Time: metav1.Now().Time}
+
+		configMapBuilder := builder.ConfigMap(instance.Spec.Profiles)
+		obj, err := configMapBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configMapBuilder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+
+		configMap := obj.(*corev1.ConfigMap)
+		Expect(configMap.Data["nginx.tmpl"]).To(ContainSubstring("10% canary;"))
+		Expect(configMap.Data["nginx.tmpl"]).To(ContainSubstring("${TEST_CAR_CANARY_SERVICE_HOST}"))
+		Expect(configMap.Data["nginx.tmpl"]).To(ContainSubstring("rewrite ^/route/v1/driving(.*)$ /route/v1/driving$1 break;"))
+		Expect(resource.IsGatewayRoutingToCanary(instance, newChildResources([]runtime.Object{configMap}))).To(Equal(true))
+
+		gatewayBuilder := builder.GatewayDeployment(instance.Spec.Profiles)
+		gatewayObj, err := gatewayBuilder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		gateway := gatewayObj.(*appsv1.Deployment)
+		Expect(resource.IsGatewayRolledOutToCanary(instance, newChildResources([]runtime.Object{configMap, gateway}))).To(Equal(false))
+		Expect(gatewayBuilder.Update(gateway, newChildResources([]runtime.Object{configMap}))).To(Succeed())
+		gateway.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 2}
+		Expect(resource.IsGatewayRolledOutToCanary(instance, newChildResources([]runtime.Object{configMap, gateway}))).To(Equal(false))
+		gateway.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+		Expect(resource.IsGatewayRolledOutToCanary(instance, newChildResources([]runtime.Object{configMap, gateway}))).To(Equal(true))
+
+		instance.Status.OSRMUpgrade.Phase = osrmv1alpha1.OSRMUpgradePhasePromoting
+		Expect(configMapBuilder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+		Expect(configMap.Data["nginx.tmpl"]).NotTo(ContainSubstring("canary"))
+		Expect(resource.IsGatewayRoutingToCanary(instance, newChildResources([]runtime.Object{configMap}))).To(Equal(false))
+	})
+})
@@ -0,0 +1,162 @@
+package resource
+
+import (
+	"sort"
+	"strings"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ChildKey identifies the child resources of a component of an OSRMCluster.
+// The profile is only set for the child resources of a profile.
+type ChildKey struct {
+	Component metadata.ComponentLabelValue
+	Profile   string
+}
+
+// GatewayKey identifies the child resources shared by the profiles, e.g. the gateway.
+var GatewayKey = ChildKey{Component: metadata.ComponentLabelGateway}
+
+// DownloadKey identifies the child resources that download the map.
+var DownloadKey = ChildKey{Component: metadata.ComponentLabelDownload}
+
+// ProfileKey identifies the child resources of a profile.
+func ProfileKey(profile string) ChildKey {
+	return ChildKey{Component: metadata.ComponentLabelProfile, Profile: profile}
+}
+
+// ChildResources indexes the child resources of an OSRMCluster by component and
+// profile, which are read from their labels, along with the StorageClasses of
+// their claims.
+type ChildResources struct {
+	instance       *osrmv1alpha1.OSRMCluster
+	index          map[ChildKey][]client.Object
+	storageClasses []runtime.Object
+}
+
+// NewChildResources indexes the child resources of an OSRMCluster. Resources of a
+// profile that were created before they were labeled with it are indexed by their name.
+func NewChildResources(instance *osrmv1alpha1.OSRMCluster, objects []client.Object) *ChildResources {
+	children := &ChildResources{
+		instance: instance,
+		index:    map[ChildKey][]client.Object{},
+	}
+	for _, object := range objects {
+		key := children.keyOf(object)
+		children.index[key] = append(children.index[key], object)
+	}
+	return children
+}
+
+// Get returns the child resources of a key.
+func (children *ChildResources) Get(key ChildKey) []client.Object {
+	if children == nil {
+		return nil
+	}
+	return children.index[key]
+}
+
+// Of returns the child resources of a key, to be looked up by the status helpers.
+func (children *ChildResources) Of(key ChildKey) []runtime.Object {
+	return appendObjects(nil, children.Get(key))
+}
+
+// AddStorageClasses adds the StorageClasses of the claims of the profiles, which
+// tell whether the claims can be expanded.
+func (children *ChildResources) AddStorageClasses(storageClasses []runtime.Object) {
+	children.storageClasses = append(children.storageClasses, storageClasses...)
+}
+
+// StorageClasses returns the StorageClasses of the claims of the profiles.
+func (children *ChildResources) StorageClasses() []runtime.Object {
+	if children == nil {
+		return nil
+	}
+	return children.storageClasses
+}
+
+// Objects returns all the child resources: those shared by the profiles first, then
+// those of the download, then those of each profile in the order of the spec, and
+// finally the others, e.g. those of profiles that were removed from the spec.
+func (children *ChildResources) Objects() []runtime.Object {
+	if children == nil {
+		return nil
+	}
+	keys := []ChildKey{GatewayKey, DownloadKey}
+	for _, profile := range children.instance.Spec.Profiles {
+		keys = append(keys, ProfileKey(profile.Name))
+	}
+
+	objects := []runtime.Object{}
+	listed := map[ChildKey]bool{}
+	for _, key := range keys {
+		objects = appendObjects(objects, children.index[key])
+		listed[key] = true
+	}
+	others := []ChildKey{}
+	for key := range children.index {
+		if !listed[key] {
+			others = append(others, key)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool {
+		if others[i].Component != others[j].Component {
+			return others[i].Component < others[j].Component
+		}
+		return others[i].Profile < others[j].Profile
+	})
+	for _, key := range others {
+		objects = appendObjects(objects, children.index[key])
+	}
+	return objects
+}
+
+// keyOf returns the key of a child resource. Resources without a component label
+// are indexed by their name, and resources of the profiles that belong to none of
+// them, like the configuration of the exporter, are shared by the profiles.
+func (children *ChildResources) keyOf(object client.Object) ChildKey {
+	component := metadata.ComponentLabelValue(object.GetLabels()[metadata.ComponentLabelKey])
+	switch component {
+	case metadata.ComponentLabelGateway, metadata.ComponentLabelDownload:
+		return ChildKey{Component: component}
+	case "":
+		if children.isDownload(object.GetName()) {
+			return DownloadKey
+		}
+	}
+	if profile, ok := object.GetLabels()[metadata.ProfileLabelKey]; ok {
+		return ProfileKey(profile)
+	}
+	if profile := children.profileOf(object.GetName()); profile != "" {
+		return ProfileKey(profile)
+	}
+	return GatewayKey
+}
+
+func (children *ChildResources) isDownload(name string) bool {
+	return name == children.instance.ChildResourceName(GatewaySuffix, DownloadJobSuffix) ||
+		name == children.instance.ChildResourceName(GatewaySuffix, PBFPersistentVolumeClaimSuffix)
+}
+
+// profileOf returns the profile whose child resources are named like an object. The
+// longest profile name wins, so that profiles like car and car-eu are told apart.
+func (children *ChildResources) profileOf(name string) string {
+	profileName := ""
+	for _, profile := range children.instance.Spec.Profiles {
+		prefix := children.instance.ChildResourceName(profile.Name, "")
+		if (name == prefix || strings.HasPrefix(name, prefix+"-")) && len(profile.Name) > len(profileName) {
+			profileName = profile.Name
+		}
+	}
+	return profileName
+}
+
+func appendObjects(objects []runtime.Object, keyObjects []client.Object) []runtime.Object {
+	for _, object := range keyObjects {
+		objects = append(objects, object)
+	}
+	return objects
+}
@@ -0,0 +1,109 @@
+package resource_test
+
+import (
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("ChildResources", func() {
+	var profiles osrmv1alpha1.ProfilesSpec
+	var gatewayDeployment, downloadJob, carDeployment, carPVC, carEUJob, bikeDeployment client.Object
+
+	BeforeEach(func() {
+		profiles = instance.Spec.Profiles
+		instance.Spec.Profiles = append(osrmv1alpha1.ProfilesSpec{}, profiles...)
+		instance.Spec.Profiles = append(instance.Spec.Profiles, &osrmv1alpha1.ProfileSpec{Name: "car-eu", EndpointName: "driving-eu"})
+
+		gatewayDeployment = &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:   instance.ChildResourceName(resource.GatewaySuffix, resource.DeploymentSuffix),
+			Labels: metadata.GetLabels(instance, metadata.ComponentLabelGateway),
+		}}
+		downloadJob = &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:   instance.ChildResourceName(resource.GatewaySuffix, resource.DownloadJobSuffix),
+			Labels: metadata.GetLabels(instance, metadata.ComponentLabelDownload),
+		}}
+		carDeployment = &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:   instance.ChildResourceName("car", resource.DeploymentSuffix),
+			Labels: metadata.GetProfileLabels(instance, "car"),
+		}}
+		// Resources created before the profile label are indexed by their name.
+		carPVC = &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Name:   instance.ChildResourceName("car", resource.PersistentVolumeClaimSuffix),
+			Labels: metadata.GetLabels(instance, metadata.ComponentLabelProfile),
+		}}
+		carEUJob = &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:   instance.ChildResourceName("car-eu", resource.JobSuffix),
+			Labels: metadata.GetLabels(instance, metadata.ComponentLabelProfile),
+		}}
+		bikeDeployment = &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:   instance.ChildResourceName("bike", resource.DeploymentSuffix),
+			Labels: metadata.GetProfileLabels(instance, "bike"),
+		}}
+	})
+
+	AfterEach(func() {
+		instance.Spec.Profiles = profiles
+	})
+
+	It("Should index the child resources by component and profile", func() {
+		children := resource.NewChildResources(instance, []client.Object{
+			bikeDeployment, carDeployment, carEUJob, gatewayDeployment, carPVC, downloadJob,
+		})
+
+		Expect(children.Get(resource.GatewayKey)).To(Equal([]client.Object{gatewayDeployment}))
+		Expect(children.Get(resource.DownloadKey)).To(Equal([]client.Object{downloadJob}))
+		Expect(children.Get(resource.ProfileKey("car"))).To(Equal([]client.Object{carDeployment, carPVC}))
+		Expect(children.Get(resource.ProfileKey("car-eu"))).To(Equal([]client.Object{carEUJob}))
+		Expect(children.Get(resource.ProfileKey("bike"))).To(Equal([]client.Object{bikeDeployment}))
+		Expect(children.Get(resource.ProfileKey("truck"))).To(BeEmpty())
+	})
+
+	It("Should index the resources without a component by their name", func() {
+		unlabeledDownloadJob := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name: instance.ChildResourceName(resource.GatewaySuffix, resource.DownloadJobSuffix),
+		}}
+		unlabeledCarJob := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name: instance.ChildResourceName("car", resource.JobSuffix),
+		}}
+		exporterConfigMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:   instance.ChildResourceName(resource.GatewaySuffix, resource.ExporterSuffix),
+			Labels: metadata.GetLabels(instance, metadata.ComponentLabelProfile),
+		}}
+		children := resource.NewChildResources(instance, []client.Object{
+			unlabeledDownloadJob, unlabeledCarJob, exporterConfigMap,
+		})
+
+		Expect(children.Get(resource.DownloadKey)).To(Equal([]client.Object{unlabeledDownloadJob}))
+		Expect(children.Of(resource.ProfileKey("car"))).To(Equal([]runtime.Object{unlabeledCarJob}))
+		Expect(children.Get(resource.GatewayKey)).To(Equal([]client.Object{exporterConfigMap}))
+	})
+
+	It("Should keep the StorageClasses apart from the child resources", func() {
+		storageClass := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}}
+		children := resource.NewChildResources(instance, []client.Object{carPVC})
+		children.AddStorageClasses([]runtime.Object{storageClass})
+
+		Expect(children.StorageClasses()).To(Equal([]runtime.Object{storageClass}))
+		Expect(children.Objects()).To(Equal([]runtime.Object{carPVC}))
+	})
+
+	It("Should list the gateway first and the profiles in the order of the spec", func() {
+		children := resource.NewChildResources(instance, []client.Object{
+			bikeDeployment, carEUJob, carDeployment, gatewayDeployment, downloadJob,
+		})
+
+		Expect(children.Objects()).To(Equal([]runtime.Object{
+			gatewayDeployment, downloadJob, carDeployment, carEUJob, bikeDeployment,
+		}))
+	})
+})
@@ -10,23 +10,91 @@ const (
 )
 
 const osrmContainerName = "osrm-backend"
+const osrmPort = 5000
+const hydrateContainerName = "hydrate-map-data"
+const copyMapDataContainerName = "copy-map-data"
 const osrmDataVolumeName = "osrm-data"
 const osrmDataPath = "/data"
+const sharedDataVolumeName = "shared-osrm-data"
+const sharedDataPath = "/shared"
 const osrmPartitionedData = "partitioned"
 const osrmCustomizedData = "customized"
+const pbfCacheVolumeName = "pbf-cache"
+const pbfCachePath = "/pbf"
+const osrmCanaryData = "canary"
+const monitoredRoutedPort = 5001
+const exporterContainerName = "metrics-exporter"
+const exporterConfigVolumeName = "exporter-conf"
+const metricsPortName = "metrics"
 
 const GatewaySuffix = ""
 const PersistentVolumeClaimSuffix = ""
 const JobSuffix = "map-builder"
+const ImportJobSuffix = "map-import"
 const CronJobSuffix = "speed-updates"
+const SpeedUpdatesJobSuffix = "speed-updates-on-demand"
+const UploadJobSuffix = "map-upload"
+const VolumeSnapshotSuffix = "snapshot"
 const DeploymentSuffix = ""
 const HorizontalPodAutoscalerSuffix = ""
 const PodDisruptionBudgetSuffix = ""
 const ServiceSuffix = ""
 const ConfigMapSuffix = ""
+const PBFPersistentVolumeClaimSuffix = "pbf-cache"
+const DownloadJobSuffix = "pbf-download"
+const CanarySuffix = "canary"
+const CanaryJobSuffix = "map-builder-canary"
+const CanaryCleanupJobSuffix = "canary-cleanup"
+const GreenSuffix = "green"
+const ExporterSuffix = "exporter"
+const MetricsServiceSuffix = "metrics"
 
 const nginxConfigurationTemplateName = "nginx.tmpl"
+const nginxStatsScriptName = "osrm_stats.js"
+const nginxMetricsScriptName = "osrm_metrics.js"
+
+// GatewayStatsPort and GatewayStatsPath serve the requests and errors counted
+// by each gateway pod while a canary upgrade is analyzed.
+const GatewayStatsPort = 8081
+const GatewayStatsPath = "/upgrade-stats"
 const gatewayImage = "nginx"
 
+// MetricsPort and MetricsPath serve the request metrics of the workers and the
+// gateway when monitoring is enabled.
+const MetricsPort = 9113
+const MetricsPath = "/metrics"
+
 const LastTrafficUpdateTimeAnnotation = "osrmcluster.itayankri/lastTrafficUpdateTime"
+const LastMapBuildTimeAnnotation = "osrmcluster.itayankri/lastMapBuildTime"
+const OnDemandTokenAnnotation = "osrmcluster.itayankri/onDemandToken"
+
+// MapDataBuiltAnnotation marks a map data volume whose map data Job completed, so
+// the volume stays ready after the Job is deleted, e.g. by its TTL. Its value is
+// the completion time of the Job.
+const MapDataBuiltAnnotation = "osrmcluster.itayankri/mapDataBuilt"
+
+// RetainedMapDataAnnotation marks a retained map data volume that holds complete
+// map data. Its value is the completion time of the Job that built the data.
+const RetainedMapDataAnnotation = "osrmcluster.itayankri/retainedMapData"
+
+// RestoredFromSnapshotAnnotation marks a map data volume that was restored from
+// the VolumeSnapshot named in its value.
+const RestoredFromSnapshotAnnotation = "osrmcluster.itayankri/restoredFromSnapshot"
+
+// legacyOSRMVersion is the OSRM release of the default images before the release
+// of map data was recorded, which map data without a recorded release was built with.
+const legacyOSRMVersion = "v5.27.1"
+
+// OSRMVersionAnnotation is the OSRM release map data was built with, recorded on
+// map data Jobs and copied onto the map data volume when they complete.
+const OSRMVersionAnnotation = "osrmcluster.itayankri/osrmVersion"
+
+// SpeedUpdatedAnnotation records an on-demand speed update on the map data volume
+// once its Job completed, so the Job can be deleted. Its value is the completion
+// time of the Job, and SpeedUpdatesTokenAnnotation is the token of the request.
+const SpeedUpdatedAnnotation = "osrmcluster.itayankri/speedUpdated"
+const SpeedUpdatesTokenAnnotation = "osrmcluster.itayankri/speedUpdatesToken"
+
+// SnapshotDataTimeAnnotation is the time of the map build or speed update captured by a VolumeSnapshot.
+const SnapshotDataTimeAnnotation = "osrmcluster.itayankri/dataTime"
 const GatewayConfigVersion = "osrmcluter.itayankri/gatewayConfigHash"
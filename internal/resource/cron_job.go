@@ -9,7 +9,6 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -31,15 +30,15 @@ func (builder *CronJobBuilder) Build() (client.Object, error) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      builder.Instance.ChildResourceName(builder.profile.Name, CronJobSuffix),
 			Namespace: builder.Instance.Namespace,
-			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile),
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
 		},
 	}, nil
 }
 
-func (builder *CronJobBuilder) Update(object client.Object, siblings []runtime.Object) error {
+func (builder *CronJobBuilder) Update(object client.Object, siblings *ChildResources) error {
 	cronJob := object.(*batchv1.CronJob)
 
-	cronJob.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile)
+	cronJob.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
 
 	cronJob.Spec = batchv1.CronJobSpec{
 		Suspend:  builder.profile.SpeedUpdates.Suspend,
@@ -49,59 +48,7 @@ func (builder *CronJobBuilder) Update(object client.Object, siblings []runtime.O
 				Name:      builder.Instance.ChildResourceName(builder.profile.Name, CronJobSuffix),
 				Namespace: builder.Instance.Namespace,
 			},
-			Spec: batchv1.JobSpec{
-				Template: corev1.PodTemplateSpec{
-					Spec: corev1.PodSpec{
-						RestartPolicy: corev1.RestartPolicyOnFailure,
-						Containers: []corev1.Container{
-							{
-								Name:      builder.Instance.ChildResourceName(builder.profile.Name, CronJobSuffix),
-								Image:     builder.profile.GetSpeedUpdatesImage(),
-								Resources: *builder.profile.SpeedUpdates.GetResources(),
-								Env: append(builder.profile.SpeedUpdates.Env, []corev1.EnvVar{
-									{
-										Name:  "ROOT_DIR",
-										Value: osrmDataPath,
-									},
-									{
-										Name:  "PARTITIONED_DATA_DIR",
-										Value: osrmPartitionedData,
-									},
-									{
-										Name:  "CUSTOMIZED_DATA_DIR",
-										Value: osrmCustomizedData,
-									},
-									{
-										Name:  "URL",
-										Value: builder.profile.SpeedUpdates.URL,
-									},
-									{
-										Name:  "OSRM_FILE_NAME",
-										Value: builder.Instance.Spec.GetOsrmFileName(),
-									},
-								}...),
-								VolumeMounts: []corev1.VolumeMount{
-									{
-										Name:      osrmDataVolumeName,
-										MountPath: osrmDataPath,
-									},
-								},
-							},
-						},
-						Volumes: []corev1.Volume{
-							{
-								Name: osrmDataVolumeName,
-								VolumeSource: corev1.VolumeSource{
-									PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-										ClaimName: builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
-										ReadOnly:  false,
-									},
-								},
-							},
-						},
-					},
-				},
-			},
+			Spec: speedUpdatesJobSpec(builder.Instance, builder.profile, builder.servingOSRMVersion(builder.profile, siblings)),
 		},
 	}
 
@@ -112,14 +59,67 @@ func (builder *CronJobBuilder) Update(object client.Object, siblings []runtime.O
 	return nil
 }
 
-func (builder *CronJobBuilder) ShouldDeploy(resources []runtime.Object) bool {
+func (builder *CronJobBuilder) ShouldDeploy(resources *ChildResources) bool {
 	return builder.profile.SpeedUpdates != nil &&
 		status.IsPersistentVolumeClaimBound(
 			builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
-			resources,
+			resources.Of(ProfileKey(builder.profile.Name)),
 		) &&
-		status.IsJobCompleted(
-			builder.Instance.ChildResourceName(builder.profile.Name, JobSuffix),
-			resources,
-		)
+		builder.isMapDataBuilt(builder.profile, resources)
+}
+
+func speedUpdatesJobSpec(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec, osrmVersion string) batchv1.JobSpec {
+	return batchv1.JobSpec{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyOnFailure,
+				Containers: []corev1.Container{
+					{
+						Name:      instance.ChildResourceName(profile.Name, CronJobSuffix),
+						Image:     profile.GetSpeedUpdatesImage(osrmVersion),
+						Resources: *profile.SpeedUpdates.GetResources(),
+						Env: append(profile.SpeedUpdates.Env, []corev1.EnvVar{
+							{
+								Name:  "ROOT_DIR",
+								Value: osrmDataPath,
+							},
+							{
+								Name:  "PARTITIONED_DATA_DIR",
+								Value: osrmPartitionedData,
+							},
+							{
+								Name:  "CUSTOMIZED_DATA_DIR",
+								Value: osrmCustomizedData,
+							},
+							{
+								Name:  "URL",
+								Value: profile.SpeedUpdates.URL,
+							},
+							{
+								Name:  "OSRM_FILE_NAME",
+								Value: instance.Spec.GetOsrmFileName(),
+							},
+						}...),
+						VolumeMounts: []corev1.VolumeMount{
+							{
+								Name:      osrmDataVolumeName,
+								MountPath: osrmDataPath,
+							},
+						},
+					},
+				},
+				Volumes: []corev1.Volume{
+					{
+						Name: osrmDataVolumeName,
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix),
+								ReadOnly:  false,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
 }
@@ -20,27 +20,27 @@ var _ = Describe("CronJob builder", func() {
 		It("Should return 'false' if SpeedUpdates is not set, regardless to PVC and Job states", func() {
 			instance.Spec.Profiles[0].SpeedUpdates = nil
 			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'false' when both PVC is bound and map builder Job is not completed yet", func() {
 			resources := generateChildResources(false, false, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'false' when PVC is bound but map builder Job is not completed yet", func() {
 			resources := generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'false' when PVC is not bound but map builder Job is completed", func() {
 			resources := generateChildResources(false, true, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'true' when both PVC is bound and map builder Job is compoleted", func() {
 			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(true))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
 		})
 	})
 })
@@ -2,30 +2,32 @@ package resource
 
 import (
 	"fmt"
-	"strings"
+	"path"
 	"time"
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
-	"github.com/itayankri/OSRM-Operator/internal/status"
 	appsv1 "k8s.io/api/apps/v1"
-	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+// DeploymentBuilder builds the Deployment of the workers of a profile. The canary
+// builder sets up the workers of the canary's map data during a canary upgrade.
 type DeploymentBuilder struct {
 	ProfileScopedBuilder
 	*OSRMResourceBuilder
+	canary bool
 }
 
 func (builder *OSRMResourceBuilder) Deployment(profile *osrmv1alpha1.ProfileSpec) *DeploymentBuilder {
 	return &DeploymentBuilder{
-		ProfileScopedBuilder{profile},
-		builder,
+		ProfileScopedBuilder: ProfileScopedBuilder{profile},
+		OSRMResourceBuilder:  builder,
 	}
 }
 
@@ -34,21 +36,55 @@ func (builder *DeploymentBuilder) Build() (client.Object, error) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      builder.Instance.ChildResourceName(builder.profile.Name, DeploymentSuffix),
 			Namespace: builder.Instance.Namespace,
-			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile),
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
 		},
 	}, nil
 }
 
-func (builder *DeploymentBuilder) Update(object client.Object, siblings []runtime.Object) error {
-	name := builder.Instance.ChildResourceName(builder.profile.Name, DeploymentSuffix)
+func (builder *DeploymentBuilder) Update(object client.Object, siblings *ChildResources) error {
 	deployment := object.(*appsv1.Deployment)
-	pbfFileName := builder.Instance.Spec.GetPbfFileName()
-	osrmFileName := strings.ReplaceAll(pbfFileName, "osm.pbf", "osrm")
+	servedMapBuildTime := deployment.Spec.Template.ObjectMeta.Annotations[LastMapBuildTimeAnnotation]
+	servedDataVolume := getVolume(osrmDataVolumeName, deployment.Spec.Template.Spec.Volumes)
+
+	builder.updateDeployment(deployment, builder.Instance.ChildResourceName(builder.profile.Name, DeploymentSuffix), siblings)
+
+	// With the BlueGreen strategy, workers keep the map build they serve until
+	// the green Deployment has taken over all of the profile's requests. Workers
+	// restored from snapshots also keep the snapshot of that map build.
+	if servedMapBuildTime != "" && !isMapBuildSwitchedOver(builder.Instance, builder.profile, deployment) {
+		setPodTemplateAnnotation(deployment, LastMapBuildTimeAnnotation, servedMapBuildTime)
+		if servedDataVolume != nil && isSnapshotVolume(servedDataVolume) {
+			if volume := getVolume(osrmDataVolumeName, deployment.Spec.Template.Spec.Volumes); volume != nil && isSnapshotVolume(volume) {
+				volume.VolumeSource = servedDataVolume.VolumeSource
+			}
+		}
+	}
+
+	if err := controllerutil.SetControllerReference(builder.Instance, deployment, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+// updateDeployment sets the pod template of the workers of the profile, which
+// serve its latest map data, or the canary's map data built with spec.osrmVersion.
+func (builder *DeploymentBuilder) updateDeployment(deployment *appsv1.Deployment, name string, siblings *ChildResources) {
+	osrmFileName := builder.Instance.Spec.GetOsrmFileName()
+	image := builder.Instance.Spec.GetImageForOSRMVersion(builder.servingOSRMVersion(builder.profile, siblings))
+	if builder.canary {
+		image = builder.Instance.Spec.GetImage()
+	}
+	dataPath := osrmDataPath
+	if !builder.Instance.Spec.Persistence.IsLocalWorkerStorage() && !builder.hydratesFromObjectStorage() {
+		dataPath = path.Join(osrmDataPath, builder.mapDataDir())
+	}
+	snapshotName := builder.workerSnapshotName(siblings)
 	labelSelector := map[string]string{
 		"app": name,
 	}
 
-	deployment.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile)
+	deployment.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
 	deployment.Spec.Selector = &metav1.LabelSelector{
 		MatchLabels: labelSelector,
 	}
@@ -61,10 +97,10 @@ func (builder *DeploymentBuilder) Update(object client.Object, siblings []runtim
 			Containers: []corev1.Container{
 				{
 					Name:  osrmContainerName,
-					Image: builder.Instance.Spec.GetImage(),
+					Image: image,
 					Ports: []corev1.ContainerPort{
 						{
-							ContainerPort: 5000,
+							ContainerPort: routedPort(builder.Instance),
 						},
 					},
 					Resources: *builder.profile.GetResources(),
@@ -75,11 +111,13 @@ func (builder *DeploymentBuilder) Update(object client.Object, siblings []runtim
 					Args: []string{
 						fmt.Sprintf(`
 							cd %s/%s && \
-							osrm-routed %s --algorithm mld --max-matching-size 21474836
+							osrm-routed %s --algorithm %s %s
 						`,
-							osrmDataPath,
+							dataPath,
 							osrmCustomizedData,
 							osrmFileName,
+							builder.Instance.Spec.GetAlgorithm(),
+							shellJoin(routedArgs(builder.Instance, builder.profile)),
 						),
 					},
 					VolumeMounts: []corev1.VolumeMount{
@@ -92,49 +130,264 @@ func (builder *DeploymentBuilder) Update(object client.Object, siblings []runtim
 			},
 			Volumes: []corev1.Volume{
 				{
-					Name: osrmDataVolumeName,
-					VolumeSource: corev1.VolumeSource{
-						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-							ClaimName: builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
-							ReadOnly:  true,
-						},
-					},
+					Name:         osrmDataVolumeName,
+					VolumeSource: builder.dataVolumeSource(snapshotName),
 				},
 			},
 		},
 	}
 
+	setProbes(&deployment.Spec.Template.Spec.Containers[0], builder.Instance, builder.profile)
+	setExporter(&deployment.Spec.Template, builder.Instance, builder.profile)
+
+	if builder.hydratesFromObjectStorage() {
+		objectStorage := builder.Instance.Spec.ObjectStorage
+		deployment.Spec.Template.Spec.InitContainers = []corev1.Container{
+			objectStorageContainer(objectStorage, builder.Instance, builder.profile, hydrateContainerName, hydrateScript, false),
+		}
+	} else if builder.Instance.Spec.Persistence.IsLocalWorkerStorage() && snapshotName == "" {
+		deployment.Spec.Template.Spec.InitContainers = []corev1.Container{
+			builder.copyMapDataContainer(image),
+		}
+		deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name:         sharedDataVolumeName,
+			VolumeSource: builder.sharedVolumeSource(),
+		})
+	}
+
 	builder.setAnnotations(deployment, siblings)
+}
 
-	if err := controllerutil.SetControllerReference(builder.Instance, deployment, builder.Scheme); err != nil {
-		return fmt.Errorf("failed setting controller reference: %v", err)
+// dataVolumeSource returns the volume workers serve map data from: the shared
+// volume, or a per-pod volume when map data is hydrated from object storage,
+// restored from a snapshot or copied from the shared volume.
+func (builder *DeploymentBuilder) dataVolumeSource(snapshotName string) corev1.VolumeSource {
+	if builder.hydratesFromObjectStorage() {
+		return builder.localVolumeSource(builder.Instance.Spec.ObjectStorage.Storage)
+	}
+
+	if builder.Instance.Spec.Persistence.IsLocalWorkerStorage() {
+		source := builder.localVolumeSource(nil)
+		if snapshotName != "" {
+			apiGroup := VolumeSnapshotAPIGroup
+			source.Ephemeral.VolumeClaimTemplate.Spec.DataSource = &corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     VolumeSnapshotGVK.Kind,
+				Name:     snapshotName,
+			}
+		}
+		return source
+	}
+
+	return builder.sharedVolumeSource()
+}
+
+// hydratesFromObjectStorage returns true when workers fetch their map data from
+// object storage, which only holds the map data of the profile, not the canary's.
+func (builder *DeploymentBuilder) hydratesFromObjectStorage() bool {
+	objectStorage := builder.Instance.Spec.ObjectStorage
+	return !builder.canary && objectStorage != nil && objectStorage.Hydrate
+}
+
+// mapDataDir returns the directory of the map data volume that holds the map
+// data the workers serve.
+func (builder *DeploymentBuilder) mapDataDir() string {
+	if builder.canary {
+		return osrmCanaryData
+	}
+	return ""
+}
+
+// workerSnapshotName returns the name of the snapshot of the latest map data
+// that the per-pod volumes of workers are restored from, or an empty string
+// when they are not restored from snapshots.
+func (builder *DeploymentBuilder) workerSnapshotName(siblings *ChildResources) string {
+	// The snapshots only capture the map data of the profile, not the canary's.
+	if builder.canary || !builder.Instance.Spec.Persistence.IsSnapshotWorkerStorage() || builder.hydratesFromObjectStorage() {
+		return ""
+	}
+	snapshot := builder.VolumeSnapshot(builder.profile, siblings)
+	if snapshot == nil {
+		return ""
+	}
+	return snapshot.GetName()
+}
+
+func (builder *DeploymentBuilder) sharedVolumeSource() corev1.VolumeSource {
+	return corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
+			ReadOnly:  true,
+		},
+	}
+}
+
+// localVolumeSource returns an emptyDir, or a generic ephemeral volume when
+// spec.persistence.workerStorage sets a storage class.
+func (builder *DeploymentBuilder) localVolumeSource(sizeLimit *resource.Quantity) corev1.VolumeSource {
+	workerStorage := builder.Instance.Spec.Persistence.WorkerStorage
+	if workerStorage != nil && workerStorage.Storage != nil {
+		sizeLimit = workerStorage.Storage
 	}
 
+	if workerStorage == nil || workerStorage.StorageClassName == "" {
+		return corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				SizeLimit: sizeLimit,
+			},
+		}
+	}
+
+	requests := corev1.ResourceList{}
+	if sizeLimit != nil {
+		requests[corev1.ResourceStorage] = *sizeLimit
+	} else if builder.Instance.Spec.Persistence.Storage != nil {
+		requests[corev1.ResourceStorage] = *builder.Instance.Spec.Persistence.Storage
+	}
+
+	return corev1.VolumeSource{
+		Ephemeral: &corev1.EphemeralVolumeSource{
+			VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					StorageClassName: &workerStorage.StorageClassName,
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: requests,
+					},
+				},
+			},
+		},
+	}
+}
+
+// copyMapDataContainer copies the customized map data from the shared volume
+// onto the worker's local volume. The pod only becomes ready after the copy
+// completed, because osrm-routed is not started before that.
+func (builder *DeploymentBuilder) copyMapDataContainer(image string) corev1.Container {
+	return corev1.Container{
+		Name:  copyMapDataContainerName,
+		Image: image,
+		Command: []string{
+			"/bin/sh",
+			"-c",
+		},
+		Args: []string{
+			fmt.Sprintf(`
+				set -e
+				test -f %[1]s/%[2]s/%[3]s.properties
+				rm -rf %[4]s/%[2]s %[4]s/%[2]s.tmp
+				cp -r %[1]s/%[2]s %[4]s/%[2]s.tmp
+				mv %[4]s/%[2]s.tmp %[4]s/%[2]s
+			`,
+				path.Join(sharedDataPath, builder.mapDataDir()),
+				osrmCustomizedData,
+				builder.Instance.Spec.GetOsrmFileName(),
+				osrmDataPath,
+			),
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      osrmDataVolumeName,
+				MountPath: osrmDataPath,
+			},
+			{
+				Name:      sharedDataVolumeName,
+				MountPath: sharedDataPath,
+				ReadOnly:  true,
+			},
+		},
+	}
+}
+
+func getVolume(name string, volumes []corev1.Volume) *corev1.Volume {
+	for i := range volumes {
+		if volumes[i].Name == name {
+			return &volumes[i]
+		}
+	}
 	return nil
 }
 
-func (builder *DeploymentBuilder) ShouldDeploy(resources []runtime.Object) bool {
-	return status.IsPersistentVolumeClaimBound(
-		builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
-		resources,
-	) &&
-		status.IsJobCompleted(
-			builder.Instance.ChildResourceName(builder.profile.Name, JobSuffix),
-			resources,
-		)
-}
-
-func (builder *DeploymentBuilder) setAnnotations(deployment *appsv1.Deployment, siblings []runtime.Object) {
-	for _, resource := range siblings {
-		if cron, ok := resource.(*batchv1.CronJob); ok {
-			if cron.ObjectMeta.Name == builder.Instance.ChildResourceName(builder.profile.Name, CronJobSuffix) {
-				if cron.Status.LastSuccessfulTime != nil {
-					if deployment.Spec.Template.ObjectMeta.Annotations == nil {
-						deployment.Spec.Template.ObjectMeta.Annotations = map[string]string{}
-					}
-					deployment.Spec.Template.ObjectMeta.Annotations[LastTrafficUpdateTimeAnnotation] = cron.Status.LastSuccessfulTime.Format(time.RFC3339)
-				}
-			}
+// isSnapshotVolume returns true for a per-pod volume restored from a snapshot.
+func isSnapshotVolume(volume *corev1.Volume) bool {
+	return volume.Ephemeral != nil &&
+		volume.Ephemeral.VolumeClaimTemplate != nil &&
+		volume.Ephemeral.VolumeClaimTemplate.Spec.DataSource != nil &&
+		volume.Ephemeral.VolumeClaimTemplate.Spec.DataSource.Kind == VolumeSnapshotGVK.Kind
+}
+
+func (builder *DeploymentBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.isProfileDataReady(builder.profile, resources)
+}
+
+func (builder *DeploymentBuilder) setAnnotations(deployment *appsv1.Deployment, siblings *ChildResources) {
+	if lastTrafficUpdateTime := builder.LastTrafficUpdateTime(builder.profile, siblings); lastTrafficUpdateTime != nil {
+		setPodTemplateAnnotation(deployment, LastTrafficUpdateTimeAnnotation, lastTrafficUpdateTime.Format(time.RFC3339))
+	}
+
+	if lastMapBuildTime := builder.LastMapBuildTime(builder.profile, siblings); lastMapBuildTime != nil {
+		setPodTemplateAnnotation(deployment, LastMapBuildTimeAnnotation, lastMapBuildTime.Format(time.RFC3339))
+	}
+}
+
+// setProbes sets the probes of an osrm-routed container unless they are disabled
+// for its profile. By default they issue a nearest request, which fails until
+// osrm-routed has loaded the map data. The startup probe allows an hour for that,
+// as large datasets load slowly.
+func setProbes(container *corev1.Container, instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec) {
+	if !instance.Spec.HasProbes(profile) {
+		container.StartupProbe = nil
+		container.ReadinessProbe = nil
+		container.LivenessProbe = nil
+		return
+	}
+
+	nearestRequest := nearestRequestProbe(instance, profile, routedPort(instance))
+
+	container.StartupProbe = instance.Spec.GetStartupProbe(profile)
+	if container.StartupProbe == nil {
+		container.StartupProbe = &corev1.Probe{
+			ProbeHandler:     nearestRequest,
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			FailureThreshold: 360,
 		}
 	}
+
+	container.ReadinessProbe = instance.Spec.GetReadinessProbe(profile)
+	if container.ReadinessProbe == nil {
+		container.ReadinessProbe = &corev1.Probe{
+			ProbeHandler:     nearestRequest,
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			FailureThreshold: 3,
+		}
+	}
+
+	container.LivenessProbe = instance.Spec.GetLivenessProbe(profile)
+	if container.LivenessProbe == nil {
+		container.LivenessProbe = &corev1.Probe{
+			ProbeHandler:     nearestRequest,
+			PeriodSeconds:    30,
+			TimeoutSeconds:   10,
+			FailureThreshold: 5,
+		}
+	}
+}
+
+// nearestRequestProbe issues the nearest request of the probes of a profile on a port.
+func nearestRequestProbe(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec, port int32) corev1.ProbeHandler {
+	return corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path: fmt.Sprintf("/%s/v1/%s/%s", NearestService, profile.GetInternalEndpoint(), instance.Spec.GetProbeCoordinate(profile)),
+			Port: intstr.FromInt32(port),
+		},
+	}
+}
+
+func setPodTemplateAnnotation(deployment *appsv1.Deployment, key, value string) {
+	if deployment.Spec.Template.ObjectMeta.Annotations == nil {
+		deployment.Spec.Template.ObjectMeta.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.ObjectMeta.Annotations[key] = value
 }
@@ -1,9 +1,20 @@
 package resource_test
 
 import (
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/resource"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
 )
 
 var _ = Describe("Deployment builder", func() {
@@ -15,22 +26,234 @@ var _ = Describe("Deployment builder", func() {
 
 		It("Should return 'false' when both PVC is bound and map builder Job is not completed yet", func() {
 			resources := generateChildResources(false, false, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'false' when PVC is bound but map builder Job is not completed yet", func() {
 			resources := generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'false' when PVC is not bound but map builder Job is completed", func() {
 			resources := generateChildResources(false, true, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'true' when both PVC is bound and map builder Job is compoleted", func() {
 			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(true))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
+		})
+
+		It("Should return 'true' without a map builder Job when the PVC holds retained map data", func() {
+			resources := generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name)
+			pvc := resources[1].(*corev1.PersistentVolumeClaim)
+			pvc.Annotations = map[string]string{resource.RetainedMapDataAnnotation: "2024-01-01T00:00:00Z"}
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
+		})
+
+		It("Should return 'false' until map data is uploaded when workers hydrate from object storage", func() {
+			instance.Spec.ObjectStorage = &osrmv1alpha1.ObjectStorageSpec{Bucket: "osrm", Upload: true, Hydrate: true}
+			defer func() { instance.Spec.ObjectStorage = nil }()
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+		})
+
+		It("Should return 'true' without a local build when workers only hydrate from object storage", func() {
+			instance.Spec.ObjectStorage = &osrmv1alpha1.ObjectStorageSpec{Bucket: "osrm", Hydrate: true}
+			defer func() { instance.Spec.ObjectStorage = nil }()
+			Expect(builder.ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(true))
+		})
+	})
+
+	Context("Update", func() {
+		It("Should hydrate map data onto an ephemeral volume when configured", func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder := (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Deployment(instance.Spec.Profiles[0])
+
+			instance.Spec.ObjectStorage = &osrmv1alpha1.ObjectStorageSpec{Bucket: "osrm", Hydrate: true}
+			defer func() { instance.Spec.ObjectStorage = nil }()
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+
+			podSpec := obj.(*appsv1.Deployment).Spec.Template.Spec
+			Expect(podSpec.InitContainers).To(HaveLen(1))
+			Expect(podSpec.Volumes[0].EmptyDir).NotTo(BeNil())
+			Expect(podSpec.Volumes[0].PersistentVolumeClaim).To(BeNil())
+		})
+
+		It("Should copy map data from the shared volume onto a local volume in local worker storage mode", func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder := (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Deployment(instance.Spec.Profiles[0])
+
+			storage := k8sresource.MustParse("20Gi")
+			instance.Spec.Persistence.WorkerStorage = &osrmv1alpha1.WorkerStorageSpec{
+				Mode:             osrmv1alpha1.WorkerStorageModeLocal,
+				StorageClassName: "local-path",
+				Storage:          &storage,
+			}
+			defer func() { instance.Spec.Persistence.WorkerStorage = nil }()
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+
+			podSpec := obj.(*appsv1.Deployment).Spec.Template.Spec
+			Expect(podSpec.InitContainers).To(HaveLen(1))
+			Expect(podSpec.Volumes).To(HaveLen(2))
+			claimTemplate := podSpec.Volumes[0].Ephemeral.VolumeClaimTemplate
+			Expect(*claimTemplate.Spec.StorageClassName).To(Equal("local-path"))
+			Expect(claimTemplate.Spec.Resources.Requests.Storage().Equal(storage)).To(BeTrue())
+			Expect(podSpec.Volumes[1].PersistentVolumeClaim.ReadOnly).To(BeTrue())
+		})
+
+		It("Should restore the local volume from the snapshot of the latest map data without mounting the shared volume", func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder := (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Deployment(instance.Spec.Profiles[0])
+
+			instance.Spec.Persistence.WorkerStorage = &osrmv1alpha1.WorkerStorageSpec{
+				Mode:             osrmv1alpha1.WorkerStorageModeLocal,
+				StorageClassName: "ebs-gp3",
+				Source:           osrmv1alpha1.WorkerStorageSourceSnapshot,
+			}
+			instance.Spec.Persistence.Snapshots = &osrmv1alpha1.SnapshotsSpec{}
+			defer func() {
+				instance.Spec.Persistence.WorkerStorage = nil
+				instance.Spec.Persistence.Snapshots = nil
+			}()
+
+			completionTime := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			resources[0].(*batchv1.Job).Status.CompletionTime = &completionTime
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources(resources))).To(Succeed())
+
+			podSpec := obj.(*appsv1.Deployment).Spec.Template.Spec
+			Expect(podSpec.InitContainers).To(BeEmpty())
+			Expect(podSpec.Volumes).To(HaveLen(1))
+			dataSource := podSpec.Volumes[0].Ephemeral.VolumeClaimTemplate.Spec.DataSource
+			Expect(*dataSource.APIGroup).To(Equal(resource.VolumeSnapshotAPIGroup))
+			Expect(dataSource.Kind).To(Equal("VolumeSnapshot"))
+			Expect(dataSource.Name).To(Equal("test-car-snapshot-1704067200"))
+		})
+
+		It("Should serve map data with the OSRM release it was built with until it is rebuilt", func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder := (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Deployment(instance.Spec.Profiles[0])
+
+			osrmVersion := "v6.0.0"
+			instance.Spec.OSRMVersion = &osrmVersion
+			defer func() { instance.Spec.OSRMVersion = nil }()
+
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			resources[0].(*batchv1.Job).Annotations = map[string]string{resource.OSRMVersionAnnotation: "v5.27.1"}
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources(resources))).To(Succeed())
+			Expect(obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image).To(Equal("ghcr.io/project-osrm/osrm-backend:v5.27.1"))
+
+			resources[0].(*batchv1.Job).Annotations[resource.OSRMVersionAnnotation] = "v6.0.1"
+			Expect(builder.Update(obj, newChildResources(resources))).To(Succeed())
+			Expect(obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image).To(Equal("ghcr.io/project-osrm/osrm-backend:v6.0.0"))
+		})
+
+		It("Should pass the profile's osrm-routed options to osrm-routed", func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder := (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Deployment(instance.Spec.Profiles[0])
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+			Expect(obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Args[0]).To(ContainSubstring("--algorithm mld --max-matching-size 21474836\n"))
+
+			maxTableSize := int32(1000)
+			threads := int32(4)
+			mmap := true
+			instance.Spec.Profiles[0].Routed = &osrmv1alpha1.OSRMRoutedSpec{
+				MaxTableSize: &maxTableSize,
+				Threads:      &threads,
+				MMap:         &mmap,
+				ExtraArgs:    []string{"--dataset-name", "it's"},
+			}
+			defer func() { instance.Spec.Profiles[0].Routed = nil }()
+
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+			Expect(obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Args[0]).To(ContainSubstring(
+				`--algorithm mld --max-table-size 1000 --max-matching-size 21474836 --threads 4 --mmap --dataset-name 'it'\''s'`,
+			))
+		})
+
+		It("Should probe osrm-routed with a nearest request unless the probes are overridden", func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder := (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Deployment(instance.Spec.Profiles[0])
+
+			coordinate := "34.78,32.08"
+			instance.Spec.Probes = &osrmv1alpha1.ProbesSpec{Coordinate: &coordinate}
+			defer func() { instance.Spec.Probes = nil }()
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+			container := obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0]
+			Expect(container.StartupProbe.HTTPGet.Path).To(Equal("/nearest/v1/driving/34.78,32.08"))
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/nearest/v1/driving/34.78,32.08"))
+			Expect(container.LivenessProbe.HTTPGet.Path).To(Equal("/nearest/v1/driving/34.78,32.08"))
+
+			liveness := &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(5000)},
+				},
+			}
+			instance.Spec.Profiles[0].Probes = &osrmv1alpha1.ProbesSpec{Liveness: liveness}
+			defer func() { instance.Spec.Profiles[0].Probes = nil }()
+
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+			container = obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0]
+			Expect(container.StartupProbe.HTTPGet.Path).To(Equal("/nearest/v1/driving/34.78,32.08"))
+			Expect(container.LivenessProbe).To(Equal(liveness))
+		})
+
+		It("Should not probe osrm-routed when the probes are disabled", func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder := (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Deployment(instance.Spec.Profiles[0])
+
+			disabled := true
+			instance.Spec.Probes = &osrmv1alpha1.ProbesSpec{Disabled: &disabled}
+			defer func() { instance.Spec.Probes = nil }()
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+			container := obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0]
+			Expect(container.StartupProbe).To(BeNil())
+			Expect(container.ReadinessProbe).To(BeNil())
+			Expect(container.LivenessProbe).To(BeNil())
+
+			profileDisabled := false
+			instance.Spec.Profiles[0].Probes = &osrmv1alpha1.ProbesSpec{Disabled: &profileDisabled}
+			defer func() { instance.Spec.Profiles[0].Probes = nil }()
+
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+			container = obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0]
+			Expect(container.StartupProbe.HTTPGet.Path).To(Equal("/nearest/v1/driving/0,0"))
 		})
 	})
 })
@@ -0,0 +1,140 @@
+package resource
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// downloadScript downloads the PBF file into the shared volume unless a file
+// with a matching checksum is already cached there. The file is verified
+// against PBF_CHECKSUM (SHA-256), or against the MD5 file published next to
+// the PBF when no checksum is given.
+const downloadScript = `
+set -e
+cd "$ROOT_DIR"
+if [ -n "$PBF_CHECKSUM" ] && [ -f "$PBF_FILE_NAME" ] && echo "$PBF_CHECKSUM  $PBF_FILE_NAME" | sha256sum -c -; then
+	echo "Using cached PBF file $PBF_FILE_NAME"
+	exit 0
+fi
+
+echo "Downloading PBF file from $PBF_URL"
+curl -fL -o "$PBF_FILE_NAME.partial" "$PBF_URL"
+
+if [ -n "$PBF_CHECKSUM" ]; then
+	echo "$PBF_CHECKSUM  $PBF_FILE_NAME.partial" | sha256sum -c -
+elif curl -fsL -o "$PBF_FILE_NAME.md5" "$PBF_URL.md5"; then
+	[ "$(cut -d' ' -f1 "$PBF_FILE_NAME.md5")" = "$(md5sum "$PBF_FILE_NAME.partial" | cut -d' ' -f1)" ]
+else
+	echo "No checksum available, skipping verification"
+fi
+
+mv "$PBF_FILE_NAME.partial" "$PBF_FILE_NAME"
+sha256sum "$PBF_FILE_NAME" > "$PBF_FILE_NAME.sha256"
+`
+
+// DownloadJobBuilder builds the cluster-scoped Job that downloads the PBF file
+// once for all profiles.
+type DownloadJobBuilder struct {
+	ClusterScopedBuilder
+	*OSRMResourceBuilder
+}
+
+func (builder *OSRMResourceBuilder) DownloadJob(profiles []*osrmv1alpha1.ProfileSpec) *DownloadJobBuilder {
+	return &DownloadJobBuilder{
+		ClusterScopedBuilder{profiles},
+		builder,
+	}
+}
+
+func (builder *DownloadJobBuilder) Build() (client.Object, error) {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(GatewaySuffix, DownloadJobSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelDownload),
+		},
+	}, nil
+}
+
+func (builder *DownloadJobBuilder) Update(object client.Object, siblings *ChildResources) error {
+	job := object.(*batchv1.Job)
+	sharedDownload := builder.Instance.Spec.MapBuilder.SharedDownload
+
+	job.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelDownload)
+
+	job.Spec = batchv1.JobSpec{
+		Selector: job.Spec.Selector,
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: job.Spec.Template.ObjectMeta.Labels,
+			},
+			Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyOnFailure,
+				Containers: []corev1.Container{
+					{
+						Name:      builder.Instance.ChildResourceName(GatewaySuffix, DownloadJobSuffix),
+						Image:     builder.Instance.Spec.MapBuilder.GetImage(builder.Instance.Spec.GetOSRMVersion()),
+						Resources: *sharedDownload.GetResources(),
+						Command: []string{
+							"/bin/bash",
+							"-c",
+						},
+						Args: []string{downloadScript},
+						Env: []corev1.EnvVar{
+							{
+								Name:  "ROOT_DIR",
+								Value: pbfCachePath,
+							},
+							{
+								Name:  "PBF_URL",
+								Value: builder.Instance.Spec.PBFURL,
+							},
+							{
+								Name:  "PBF_FILE_NAME",
+								Value: builder.Instance.Spec.GetPbfFileName(),
+							},
+							{
+								Name:  "PBF_CHECKSUM",
+								Value: sharedDownload.GetChecksum(),
+							},
+						},
+						VolumeMounts: []corev1.VolumeMount{
+							{
+								Name:      pbfCacheVolumeName,
+								MountPath: pbfCachePath,
+							},
+						},
+					},
+				},
+				Volumes: []corev1.Volume{
+					{
+						Name: pbfCacheVolumeName,
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: builder.Instance.ChildResourceName(GatewaySuffix, PBFPersistentVolumeClaimSuffix),
+								ReadOnly:  false,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(builder.Instance, job, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *DownloadJobBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.Instance.Spec.MapBuilder.SharedDownload != nil && builder.Instance.Spec.HasMapDataVolume() && !builder.Instance.Spec.IsPrebuilt()
+}
@@ -0,0 +1,56 @@
+package resource_test
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("DownloadJob builder", func() {
+	var builder resource.ResourceBuilder
+	BeforeEach(func() {
+		builder = osrmResourceBuilder.DownloadJob(instance.Spec.Profiles)
+		instance.Spec.MapBuilder.SharedDownload = &osrmv1alpha1.SharedDownloadSpec{}
+	})
+
+	AfterEach(func() {
+		instance.Spec.MapBuilder.SharedDownload = nil
+	})
+
+	Context("ShouldDeploy", func() {
+		It("Should return 'false' when shared download is disabled", func() {
+			instance.Spec.MapBuilder.SharedDownload = nil
+			Expect(builder.ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(false))
+		})
+
+		It("Should return 'true' when shared download is enabled", func() {
+			Expect(builder.ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(true))
+		})
+	})
+
+	Context("Update", func() {
+		It("Should pass the expected checksum to the download script", func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder = (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).DownloadJob(instance.Spec.Profiles)
+
+			checksum := "abc123"
+			instance.Spec.MapBuilder.SharedDownload.Checksum = &checksum
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+
+			job := obj.(*batchv1.Job)
+			Expect(job.Name).To(Equal(fmt.Sprintf("%s-%s", instance.Name, resource.DownloadJobSuffix)))
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "PBF_CHECKSUM", Value: checksum}))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"strings"
 
@@ -10,10 +11,9 @@ import (
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
-	"github.com/itayankri/OSRM-Operator/internal/status"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 )
 
 type ConfigMapBuilder struct {
@@ -38,7 +38,7 @@ func (builder *ConfigMapBuilder) Build() (client.Object, error) {
 	}, nil
 }
 
-func (builder *ConfigMapBuilder) Update(object client.Object, siblings []runtime.Object) error {
+func (builder *ConfigMapBuilder) Update(object client.Object, siblings *ChildResources) error {
 	configMap := object.(*corev1.ConfigMap)
 	configMap.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelGateway)
 
@@ -51,6 +51,8 @@ func (builder *ConfigMapBuilder) Update(object client.Object, siblings []runtime
 		builder.profiles,
 		builder.Instance.Spec.Service.ExposingServices,
 	)
+	configMap.Data[nginxStatsScriptName] = nginxStatsScript
+	configMap.Data[nginxMetricsScriptName] = nginxMetricsScript
 
 	if err := controllerutil.SetControllerReference(builder.Instance, configMap, builder.Scheme); err != nil {
 		return fmt.Errorf("failed setting controller reference: %v", err)
@@ -59,6 +61,33 @@ func (builder *ConfigMapBuilder) Update(object client.Object, siblings []runtime
 	return nil
 }
 
+// nginxStatsScript counts the requests and 5xx responses of the canary profile
+// per track, and serves the counters as JSON to the operator.
+const nginxStatsScript = `
+function record(r) {
+	var track = r.variables.osrm_track;
+	ngx.shared.osrm_stats.incr(track + ':requests', 1, 0);
+	if (Number(r.variables.status) >= 500) {
+		ngx.shared.osrm_stats.incr(track + ':errors', 1, 0);
+	}
+	return '';
+}
+
+function report(r) {
+	var stats = {};
+	['stable', 'canary'].forEach(function (track) {
+		stats[track] = {
+			requests: ngx.shared.osrm_stats.get(track + ':requests') || 0,
+			errors: ngx.shared.osrm_stats.get(track + ':errors') || 0,
+		};
+	});
+	r.headersOut['Content-Type'] = 'application/json';
+	r.return(200, JSON.stringify(stats));
+}
+
+export default { record, report };
+`
+
 func generateNginxConf(instance *osrmv1alpha1.OSRMCluster, profiles []*osrmv1alpha1.ProfileSpec, osrmServices []string) string {
 	config := `
 	events {
@@ -74,7 +103,146 @@ func generateNginxConf(instance *osrmv1alpha1.OSRMCluster, profiles []*osrmv1alp
 	}
 	`
 	locations := getNginxLocations(instance, profiles, osrmServices)
-	return fmt.Sprintf(config, locations)
+	switchovers := getNginxSwitchovers(instance, profiles)
+	canaryServing := instance.Status.OSRMUpgrade.IsCanaryServing()
+	monitoring := instance.Spec.Monitoring != nil
+	tracing := instance.Spec.Tracing != nil
+	if !canaryServing && !monitoring && !tracing && switchovers == "" {
+		return fmt.Sprintf(config, locations)
+	}
+
+	trafficSplitConfig := `
+	%s
+	events {
+	
+	}
+	http {
+		large_client_header_buffers 4 128k;%s%s
+		server {
+			listen 80;
+			server_name _;%s
+			%s
+		}%s
+	}
+	`
+	modules := ""
+	tracingDirectives := ""
+	if tracing {
+		modules = `
+	load_module /usr/lib/nginx/modules/ngx_otel_module.so;`
+		tracingDirectives = nginxTracingConfig(instance)
+	}
+	if !canaryServing && !monitoring {
+		return fmt.Sprintf(trafficSplitConfig, modules, tracingDirectives, switchovers, "", locations, "")
+	}
+
+	canaryConfig := `
+		js_import stats from %s;
+		js_shared_dict_zone zone=osrm_stats:1m type=number;
+		js_set $osrm_stats_record stats.record;
+		log_format osrm_stats '$osrm_stats_record';
+		split_clients "${request_id}" $osrm_track {
+			%d%% canary;
+			* stable;
+		}`
+	statsServer := `
+		server {
+			listen %d;
+			location = %s {
+				js_content stats.report;
+			}
+		}`
+	httpDirectives := tracingDirectives + `
+		js_path /etc/nginx;`
+	serverDirectives := ""
+	servers := ""
+	if canaryServing {
+		httpDirectives += fmt.Sprintf(canaryConfig, nginxStatsScriptName, instance.Spec.Canary.GetWeight())
+		servers += fmt.Sprintf(statsServer, GatewayStatsPort, GatewayStatsPath)
+	}
+	if monitoring {
+		httpDirectives += fmt.Sprintf(nginxMetricsConfig, nginxMetricsScriptName)
+		serverDirectives = `
+			access_log /var/log/nginx/access.log;
+			access_log /dev/null osrm_metrics;`
+		servers += fmt.Sprintf(nginxMetricsServer, MetricsPort, MetricsPath)
+	}
+	return fmt.Sprintf(
+		trafficSplitConfig,
+		modules+`
+	load_module /usr/lib/nginx/modules/ngx_http_js_module.so;`,
+		httpDirectives,
+		switchovers,
+		serverDirectives,
+		locations,
+		servers,
+	)
+}
+
+// nginxTracingConfig exports the spans of the gateway to the OpenTelemetry collector and
+// propagates the W3C trace context to the workers. Requests that continue a sampled
+// trace are always traced, the others are sampled by their trace ID.
+func nginxTracingConfig(instance *osrmv1alpha1.OSRMCluster) string {
+	tracing := instance.Spec.Tracing
+	serviceName := instance.ChildResourceName(GatewaySuffix, DeploymentSuffix)
+	if tracing.ServiceName != nil {
+		serviceName = *tracing.ServiceName
+	}
+	directives := fmt.Sprintf(`
+		otel_exporter {
+			endpoint %s;
+		}
+		otel_service_name %s;
+		otel_trace_context propagate;`, tracing.Endpoint, serviceName)
+
+	percentage := tracing.GetSamplingPercentage()
+	if percentage >= 100 {
+		return directives + `
+		otel_trace on;`
+	}
+	return directives + fmt.Sprintf(`
+		split_clients "$otel_trace_id" $osrm_ratio_sampler {
+			%d%% on;
+			* off;
+		}
+		map $otel_parent_sampled $osrm_sampler {
+			"1" on;
+			default $osrm_ratio_sampler;
+		}
+		otel_trace $osrm_sampler;`, percentage)
+}
+
+// getNginxSwitchovers chooses the requests of each profile in a blue/green
+// switchover that are sent to its green Deployment.
+func getNginxSwitchovers(instance *osrmv1alpha1.OSRMCluster, profiles []*osrmv1alpha1.ProfileSpec) string {
+	var switchovers strings.Builder
+	for _, profile := range profiles {
+		if weight := greenWeight(instance, profile); weight > 0 {
+			switchovers.WriteString(fmt.Sprintf(`
+		split_clients "${request_id}" %s {
+			%d%% green;
+			* blue;
+		}`, greenTrackVariable(profile), weight))
+		}
+	}
+	return switchovers.String()
+}
+
+// greenWeight returns the percentage of a profile's requests sent to its green Deployment.
+// The canary of an OSRM upgrade takes precedence over a switchover of the same profile.
+func greenWeight(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec) int32 {
+	if upgrade := instance.Status.OSRMUpgrade; upgrade.IsCanaryServing() && upgrade.Profile == profile.Name {
+		return 0
+	}
+	switchover := instance.Status.GetSwitchover(profile.Name)
+	if !switchover.IsInProgress() {
+		return 0
+	}
+	return switchover.Weight
+}
+
+func greenTrackVariable(profile *osrmv1alpha1.ProfileSpec) string {
+	return fmt.Sprintf("$osrm_green_%s", strings.ReplaceAll(profile.Name, "-", "_"))
 }
 
 func getNginxLocations(instance *osrmv1alpha1.OSRMCluster, profiles []*osrmv1alpha1.ProfileSpec, osrmServices []string) string {
@@ -94,16 +262,129 @@ func formatNginxLocation(instance *osrmv1alpha1.OSRMCluster, profile osrmv1alpha
 	externalPath := fmt.Sprintf("%s/v1/%s", osrmService, profile.EndpointName)
 	serviceName := instance.ChildResourceName(profile.Name, "")
 	envVar := serviceToEnvVariable(serviceName)
+	if upgrade := instance.Status.OSRMUpgrade; upgrade.IsCanaryServing() && upgrade.Profile == profile.Name {
+		// The canary's share of requests is chosen by split_clients. proxy_pass
+		// with a variable does not replace the location prefix, so the path is rewritten.
+		canaryEnvVar := serviceToEnvVariable(instance.ChildResourceName(profile.Name, CanarySuffix))
+		return fmt.Sprintf(`
+			location /%[1]s {%[5]s
+				access_log /var/log/nginx/access.log;
+				access_log /dev/null osrm_stats;
+				set $osrm_upstream ${%[2]s};
+				if ($osrm_track = canary) {
+					set $osrm_upstream ${%[3]s};
+				}
+				rewrite ^/%[1]s(.*)$ /%[4]s$1 break;
+				proxy_pass http://$osrm_upstream;
+			}`, externalPath, envVar, canaryEnvVar, internalPath,
+			nginxLocationMetrics(instance, profile, true)+nginxLocationTracing(instance, profile, osrmService))
+	}
+	if greenWeight(instance, &profile) > 0 {
+		greenEnvVar := serviceToEnvVariable(instance.ChildResourceName(profile.Name, GreenSuffix))
+		return fmt.Sprintf(`
+			location /%[1]s {%[6]s
+				set $osrm_upstream ${%[2]s};
+				if (%[3]s = green) {
+					set $osrm_upstream ${%[4]s};
+				}
+				rewrite ^/%[1]s(.*)$ /%[5]s$1 break;
+				proxy_pass http://$osrm_upstream;
+			}`, externalPath, envVar, greenTrackVariable(&profile), greenEnvVar, internalPath,
+			nginxLocationMetrics(instance, profile, false)+nginxLocationTracing(instance, profile, osrmService))
+	}
 	return fmt.Sprintf(`
-			location /%s {
+			location /%s {%s
 				proxy_pass http://${%s}/%s;
-			}`, externalPath, envVar, internalPath)
+			}`, externalPath, nginxLocationMetrics(instance, profile, false)+nginxLocationTracing(instance, profile, osrmService), envVar, internalPath)
+}
+
+// nginxLocationTracing adds the profile and OSRM service of a location to the spans of its
+// requests when tracing is enabled.
+func nginxLocationTracing(instance *osrmv1alpha1.OSRMCluster, profile osrmv1alpha1.ProfileSpec, osrmService string) string {
+	if instance.Spec.Tracing == nil {
+		return ""
+	}
+	return fmt.Sprintf(`
+				otel_span_name "%[2]s %[1]s";
+				otel_span_attr osrm.profile %[1]s;
+				otel_span_attr osrm.service %[2]s;`, profile.Name, osrmService)
+}
+
+// nginxLocationMetrics labels the requests of a profile's location with the
+// profile when monitoring is enabled. A location that sets its own access logs
+// must also record the requests, as it no longer inherits those of the server.
+func nginxLocationMetrics(instance *osrmv1alpha1.OSRMCluster, profile osrmv1alpha1.ProfileSpec, ownAccessLogs bool) string {
+	if instance.Spec.Monitoring == nil {
+		return ""
+	}
+	directives := fmt.Sprintf(`
+				set $osrm_profile %s;`, profile.Name)
+	if ownAccessLogs {
+		directives += `
+				access_log /dev/null osrm_metrics;`
+	}
+	return directives
+}
+
+// IsGatewayRoutingToCanary returns true while the gateway configuration sends
+// requests to a canary, or the gateway Deployment has not rolled out a change yet.
+func IsGatewayRoutingToCanary(instance *osrmv1alpha1.OSRMCluster, resources *ChildResources) bool {
+	return isGatewayRouting(instance, resources, "$osrm_track")
+}
+
+// IsGatewayRoutingToGreen returns true while the gateway configuration sends requests
+// to the green Deployment of a profile, or the gateway Deployment has not rolled out a change yet.
+func IsGatewayRoutingToGreen(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) bool {
+	return isGatewayRouting(instance, resources, greenTrackVariable(profile)+" ")
+}
+
+// IsGatewayRolledOutToCanary returns true once the gateway configuration sends
+// requests to a canary, and every gateway pod was rolled out with it and is available.
+func IsGatewayRolledOutToCanary(instance *osrmv1alpha1.OSRMCluster, resources *ChildResources) bool {
+	var configHash string
+	var deployment *appsv1.Deployment
+	for _, resource := range resources.Of(GatewayKey) {
+		switch object := resource.(type) {
+		case *corev1.ConfigMap:
+			if object.Name == instance.ChildResourceName(GatewaySuffix, ConfigMapSuffix) &&
+				strings.Contains(object.Data[nginxConfigurationTemplateName], "$osrm_track") {
+				configHash = fmt.Sprintf("%x", sha256.Sum256([]byte(object.Data[nginxConfigurationTemplateName])))
+			}
+		case *appsv1.Deployment:
+			if object.Name == instance.ChildResourceName(GatewaySuffix, DeploymentSuffix) {
+				deployment = object
+			}
+		}
+	}
+	return configHash != "" && deployment != nil &&
+		deployment.Spec.Template.Annotations[GatewayConfigVersion] == configHash &&
+		deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == deployment.Status.Replicas &&
+		deployment.Status.AvailableReplicas == deployment.Status.Replicas
+}
+
+func isGatewayRouting(instance *osrmv1alpha1.OSRMCluster, resources *ChildResources, variable string) bool {
+	name := instance.ChildResourceName(GatewaySuffix, ConfigMapSuffix)
+	for _, resource := range resources.Of(GatewayKey) {
+		switch object := resource.(type) {
+		case *corev1.ConfigMap:
+			if object.Name == name && strings.Contains(object.Data[nginxConfigurationTemplateName], variable) {
+				return true
+			}
+		case *appsv1.Deployment:
+			if object.Name == instance.ChildResourceName(GatewaySuffix, DeploymentSuffix) &&
+				(object.Status.ObservedGeneration < object.Generation ||
+					object.Status.UpdatedReplicas != object.Status.Replicas) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func (builder *ConfigMapBuilder) ShouldDeploy(resources []runtime.Object) bool {
+func (builder *ConfigMapBuilder) ShouldDeploy(resources *ChildResources) bool {
 	for _, profile := range builder.Instance.Spec.Profiles {
-		if !status.IsJobCompleted(builder.Instance.ChildResourceName(profile.Name, JobSuffix), resources) ||
-			!status.IsPersistentVolumeClaimBound(builder.Instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix), resources) {
+		if !builder.isProfileDataReady(profile, resources) {
 			return false
 		}
 	}
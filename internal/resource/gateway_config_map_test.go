@@ -1,10 +1,16 @@
 package resource_test
 
 import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/resource"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
 )
 
 var _ = Describe("ConfigMap builder", func() {
@@ -22,7 +28,7 @@ var _ = Describe("ConfigMap builder", func() {
 					generateChildResources(false, true, instance.Name, profile.Name)...,
 				)
 			}
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'false' if not all Jobs completed", func() {
@@ -33,7 +39,7 @@ var _ = Describe("ConfigMap builder", func() {
 					generateChildResources(false, true, instance.Name, profile.Name)...,
 				)
 			}
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'true' once all PVC's are bound and all Jobs completed", func() {
@@ -44,7 +50,73 @@ var _ = Describe("ConfigMap builder", func() {
 					generateChildResources(true, true, instance.Name, profile.Name)...,
 				)
 			}
-			Expect(builder.ShouldDeploy(resources)).To(Equal(true))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
+		})
+	})
+
+	Context("Tracing", func() {
+		var builder *resource.OSRMResourceBuilder
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder = &resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}
+			instance.Spec.Tracing = &osrmv1alpha1.TracingSpec{Endpoint: "otel-collector:4317"}
+		})
+
+		AfterEach(func() {
+			instance.Spec.Tracing = nil
+		})
+
+		nginxConf := func() string {
+			obj, err := builder.ConfigMap(instance.Spec.Profiles).Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.ConfigMap(instance.Spec.Profiles).Update(obj, nil)).To(Succeed())
+			return obj.(*corev1.ConfigMap).Data["nginx.tmpl"]
+		}
+
+		It("Should trace the requests of each profile and service", func() {
+			conf := nginxConf()
+			Expect(conf).To(ContainSubstring("load_module /usr/lib/nginx/modules/ngx_otel_module.so;"))
+			Expect(conf).NotTo(ContainSubstring("ngx_http_js_module"))
+			Expect(conf).To(ContainSubstring("endpoint otel-collector:4317;"))
+			Expect(conf).To(ContainSubstring("otel_service_name test;"))
+			Expect(conf).To(ContainSubstring("otel_trace on;"))
+			Expect(conf).To(ContainSubstring("otel_trace_context propagate;"))
+			for _, service := range instance.Spec.Service.ExposingServices {
+				Expect(conf).To(ContainSubstring(fmt.Sprintf(`otel_span_name "%s car";`, service)))
+				Expect(conf).To(ContainSubstring(fmt.Sprintf("otel_span_attr osrm.service %s;", service)))
+			}
+			Expect(conf).To(ContainSubstring("otel_span_attr osrm.profile car;"))
+		})
+
+		It("Should sample the requests that do not continue a sampled trace", func() {
+			percentage := int32(10)
+			serviceName := "osrm"
+			instance.Spec.Tracing.SamplingPercentage = &percentage
+			instance.Spec.Tracing.ServiceName = &serviceName
+			instance.Spec.Monitoring = &osrmv1alpha1.MonitoringSpec{}
+			defer func() { instance.Spec.Monitoring = nil }()
+
+			conf := nginxConf()
+			Expect(conf).To(ContainSubstring("load_module /usr/lib/nginx/modules/ngx_otel_module.so;"))
+			Expect(conf).To(ContainSubstring("load_module /usr/lib/nginx/modules/ngx_http_js_module.so;"))
+			Expect(conf).To(ContainSubstring("otel_service_name osrm;"))
+			Expect(conf).To(ContainSubstring("10% on;"))
+			Expect(conf).To(ContainSubstring("otel_trace $osrm_sampler;"))
+		})
+
+		It("Should run the gateway with the OpenTelemetry module", func() {
+			obj, err := builder.GatewayDeployment(instance.Spec.Profiles).Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.GatewayDeployment(instance.Spec.Profiles).Update(obj, nil)).To(Succeed())
+			Expect(obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:otel"))
+
+			instance.Spec.Tracing = nil
+			Expect(builder.GatewayDeployment(instance.Spec.Profiles).Update(obj, nil)).To(Succeed())
+			Expect(obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image).To(Equal("nginx"))
+			Expect(nginxConf()).NotTo(ContainSubstring("otel"))
 		})
 	})
 })
@@ -6,12 +6,10 @@ import (
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
-	"github.com/itayankri/OSRM-Operator/internal/status"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -40,7 +38,7 @@ func (builder *GatewayDeploymentBuilder) Build() (client.Object, error) {
 	}, nil
 }
 
-func (builder *GatewayDeploymentBuilder) Update(object client.Object, siblings []runtime.Object) error {
+func (builder *GatewayDeploymentBuilder) Update(object client.Object, siblings *ChildResources) error {
 	deployment := object.(*appsv1.Deployment)
 	deployment.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelGateway)
 	deployment.Spec = appsv1.DeploymentSpec{
@@ -77,7 +75,7 @@ func (builder *GatewayDeploymentBuilder) Update(object client.Object, siblings [
 							"-c",
 						},
 						Args: []string{`
-								envsubst < /etc/nginx/nginx.tmpl > /etc/nginx.conf &&
+								envsubst "$(printf '${%s} ' $(env | cut -d= -f1))" < /etc/nginx/nginx.tmpl > /etc/nginx.conf &&
 								printenv &&
 								cat /etc/nginx.conf &&
 								nginx -g 'daemon off;' -c /etc/nginx.conf
@@ -104,6 +102,10 @@ func (builder *GatewayDeploymentBuilder) Update(object client.Object, siblings [
 										Key:  nginxConfigurationTemplateName,
 										Path: nginxConfigurationTemplateName,
 									},
+									{
+										Key:  nginxStatsScriptName,
+										Path: nginxStatsScriptName,
+									},
 								},
 							},
 						},
@@ -113,6 +115,9 @@ func (builder *GatewayDeploymentBuilder) Update(object client.Object, siblings [
 		},
 	}
 
+	builder.setMetrics(deployment)
+	builder.setTracing(deployment)
+
 	if err := controllerutil.SetControllerReference(builder.Instance, deployment, builder.Scheme); err != nil {
 		return fmt.Errorf("failed setting controller reference: %v", err)
 	}
@@ -122,18 +127,17 @@ func (builder *GatewayDeploymentBuilder) Update(object client.Object, siblings [
 	return nil
 }
 
-func (builder *GatewayDeploymentBuilder) ShouldDeploy(resources []runtime.Object) bool {
+func (builder *GatewayDeploymentBuilder) ShouldDeploy(resources *ChildResources) bool {
 	for _, profile := range builder.Instance.Spec.Profiles {
-		if !status.IsJobCompleted(builder.Instance.ChildResourceName(profile.Name, JobSuffix), resources) ||
-			!status.IsPersistentVolumeClaimBound(builder.Instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix), resources) {
+		if !builder.isProfileDataReady(profile, resources) {
 			return false
 		}
 	}
 	return true
 }
 
-func (builder *GatewayDeploymentBuilder) setAnnotations(deployment *appsv1.Deployment, siblings []runtime.Object) {
-	for _, resource := range siblings {
+func (builder *GatewayDeploymentBuilder) setAnnotations(deployment *appsv1.Deployment, siblings *ChildResources) {
+	for _, resource := range siblings.Of(GatewayKey) {
 		if cm, ok := resource.(*corev1.ConfigMap); ok {
 			if cm.ObjectMeta.Name == builder.Instance.ChildResourceName(GatewaySuffix, ConfigMapSuffix) {
 				nginxConfig := cm.Data[nginxConfigurationTemplateName]
@@ -146,3 +150,30 @@ func (builder *GatewayDeploymentBuilder) setAnnotations(deployment *appsv1.Deplo
 		}
 	}
 }
+
+// setMetrics exposes the request metrics recorded by the gateway when monitoring is enabled.
+func (builder *GatewayDeploymentBuilder) setMetrics(deployment *appsv1.Deployment) {
+	if builder.Instance.Spec.Monitoring == nil {
+		return
+	}
+
+	template := &deployment.Spec.Template
+	setMetricsLabel(template, builder.Instance)
+	template.Spec.Containers[0].Ports = append(template.Spec.Containers[0].Ports, corev1.ContainerPort{
+		Name:          metricsPortName,
+		ContainerPort: MetricsPort,
+	})
+	configMap := template.Spec.Volumes[0].VolumeSource.ConfigMap
+	configMap.Items = append(configMap.Items, corev1.KeyToPath{
+		Key:  nginxMetricsScriptName,
+		Path: nginxMetricsScriptName,
+	})
+}
+
+// setTracing replaces the gateway image with one that has the OpenTelemetry module when tracing is enabled.
+func (builder *GatewayDeploymentBuilder) setTracing(deployment *appsv1.Deployment) {
+	if builder.Instance.Spec.Tracing == nil {
+		return
+	}
+	deployment.Spec.Template.Spec.Containers[0].Image = builder.Instance.Spec.Tracing.GetImage()
+}
@@ -22,7 +22,7 @@ var _ = Describe("GatewayDeployment builder", func() {
 					generateChildResources(false, true, instance.Name, profile.Name)...,
 				)
 			}
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'false' if not all Jobs completed", func() {
@@ -33,7 +33,7 @@ var _ = Describe("GatewayDeployment builder", func() {
 					generateChildResources(false, true, instance.Name, profile.Name)...,
 				)
 			}
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'true' once all PVC's are bound and all Jobs completed", func() {
@@ -44,7 +44,7 @@ var _ = Describe("GatewayDeployment builder", func() {
 					generateChildResources(true, true, instance.Name, profile.Name)...,
 				)
 			}
-			Expect(builder.ShouldDeploy(resources)).To(Equal(true))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
 		})
 	})
 })
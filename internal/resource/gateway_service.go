@@ -5,10 +5,8 @@ import (
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
-	"github.com/itayankri/OSRM-Operator/internal/status"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -36,7 +34,7 @@ func (builder *GatewayServiceBuilder) Build() (client.Object, error) {
 	}, nil
 }
 
-func (builder *GatewayServiceBuilder) Update(object client.Object, siblings []runtime.Object) error {
+func (builder *GatewayServiceBuilder) Update(object client.Object, siblings *ChildResources) error {
 	service := object.(*corev1.Service)
 
 	service.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelGateway)
@@ -70,10 +68,9 @@ func (builder *GatewayServiceBuilder) Update(object client.Object, siblings []ru
 	return nil
 }
 
-func (builder *GatewayServiceBuilder) ShouldDeploy(resources []runtime.Object) bool {
+func (builder *GatewayServiceBuilder) ShouldDeploy(resources *ChildResources) bool {
 	for _, profile := range builder.Instance.Spec.Profiles {
-		if !status.IsJobCompleted(builder.Instance.ChildResourceName(profile.Name, JobSuffix), resources) ||
-			!status.IsPersistentVolumeClaimBound(builder.Instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix), resources) {
+		if !builder.isProfileDataReady(profile, resources) {
 			return false
 		}
 	}
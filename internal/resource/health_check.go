@@ -0,0 +1,235 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HealthCheckAnswer is the travel time and distance OSRM answered a health check with.
+type HealthCheckAnswer struct {
+	Duration *time.Duration
+	Distance *float64
+}
+
+type osrmAnswer struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	Routes    []osrmRoute  `json:"routes"`
+	Trips     []osrmRoute  `json:"trips"`
+	Matchings []osrmRoute  `json:"matchings"`
+	Waypoints []osrmRoute  `json:"waypoints"`
+	Durations [][]*float64 `json:"durations"`
+	Distances [][]*float64 `json:"distances"`
+}
+
+type osrmRoute struct {
+	Duration *float64 `json:"duration"`
+	Distance *float64 `json:"distance"`
+}
+
+// HealthCheckURL returns the URL of the query of a health check, sent to the
+// gateway Service like the requests of the clients of the OSRMCluster.
+func HealthCheckURL(instance *osrmv1alpha1.OSRMCluster, check *osrmv1alpha1.HealthCheckSpec) (string, error) {
+	var profile *osrmv1alpha1.ProfileSpec
+	for _, profileSpec := range instance.Spec.Profiles {
+		if profileSpec.Name == check.Profile {
+			profile = profileSpec
+		}
+	}
+	if profile == nil {
+		return "", fmt.Errorf("profile %s does not exist", check.Profile)
+	}
+
+	url := fmt.Sprintf(
+		"http://%s.%s.svc/%s/v1/%s/%s",
+		instance.ChildResourceName(GatewaySuffix, ServiceSuffix),
+		instance.Namespace,
+		check.GetService(),
+		profile.EndpointName,
+		strings.Join(check.Coordinates, ";"),
+	)
+	switch OSRMService(check.GetService()) {
+	case TableService:
+		url += "?annotations=duration,distance"
+	case RouteService, TripService, MatchService:
+		url += "?overview=false"
+	}
+	return url, nil
+}
+
+// ParseHealthCheckAnswer reads the travel time and distance of the answer to a
+// health check, which depend on its OSRM service.
+func ParseHealthCheckAnswer(check *osrmv1alpha1.HealthCheckSpec, body []byte) (*HealthCheckAnswer, error) {
+	answer := &osrmAnswer{}
+	if err := json.Unmarshal(body, answer); err != nil {
+		return nil, fmt.Errorf("failed parsing the answer: %v", err)
+	}
+	if answer.Code != "Ok" {
+		return nil, fmt.Errorf("OSRM answered %s: %s", answer.Code, answer.Message)
+	}
+
+	var routes []osrmRoute
+	switch OSRMService(check.GetService()) {
+	case RouteService:
+		routes = answer.Routes
+	case TripService:
+		routes = answer.Trips
+	case MatchService:
+		routes = answer.Matchings
+	case NearestService:
+		// The distance of a waypoint is its distance from the coordinate, it has no travel time.
+		if len(answer.Waypoints) == 0 {
+			return nil, fmt.Errorf("OSRM answered without waypoints")
+		}
+		return &HealthCheckAnswer{Distance: answer.Waypoints[0].Distance}, nil
+	case TableService:
+		duration := lastOfFirstRow(answer.Durations)
+		distance := lastOfFirstRow(answer.Distances)
+		if duration == nil && distance == nil {
+			return nil, fmt.Errorf("OSRM found no route from the first to the last coordinate")
+		}
+		return newHealthCheckAnswer(duration, distance), nil
+	}
+
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("OSRM answered without a %s", check.GetService())
+	}
+	return newHealthCheckAnswer(routes[0].Duration, routes[0].Distance), nil
+}
+
+// Verify returns why an answer is not within the bounds expected by a health check.
+func (answer *HealthCheckAnswer) Verify(check *osrmv1alpha1.HealthCheckSpec) error {
+	if check.MaxDuration != nil && answer.Duration != nil && *answer.Duration > check.MaxDuration.Duration {
+		return fmt.Errorf("the duration %s exceeds %s", answer.Duration.Round(time.Second), check.MaxDuration.Duration)
+	}
+	if check.MaxDistance != nil && answer.Distance != nil && *answer.Distance > float64(*check.MaxDistance) {
+		return fmt.Errorf("the distance %.0fm exceeds %dm", *answer.Distance, *check.MaxDistance)
+	}
+	return nil
+}
+
+func newHealthCheckAnswer(seconds, distance *float64) *HealthCheckAnswer {
+	answer := &HealthCheckAnswer{Distance: distance}
+	if seconds != nil {
+		duration := time.Duration(*seconds * float64(time.Second))
+		answer.Duration = &duration
+	}
+	return answer
+}
+
+// lastOfFirstRow returns the value of a table from the first to the last coordinate.
+// OSRM answers null when there is no route between them.
+func lastOfFirstRow(table [][]*float64) *float64 {
+	if len(table) == 0 || len(table[0]) == 0 {
+		return nil
+	}
+	return table[0][len(table[0])-1]
+}
+
+// ServedMapData returns the completion times of the map build and of the speed
+// update in the pod template of the Deployment that serves the requests of a
+// profile: the green Deployment while a switchover sends it requests, and the
+// profile's Deployment otherwise.
+func ServedMapData(
+	instance *osrmv1alpha1.OSRMCluster,
+	profile string,
+	resources *ChildResources,
+) (mapBuildTime *metav1.Time, speedUpdateTime *metav1.Time) {
+	profileResources := resources.Of(ProfileKey(profile))
+	deployment := status.GetDeployment(instance.ChildResourceName(profile, DeploymentSuffix), profileResources)
+	if switchover := instance.Status.GetSwitchover(profile); switchover.IsInProgress() && switchover.Weight > 0 {
+		if green := status.GetDeployment(instance.ChildResourceName(profile, GreenSuffix), profileResources); green != nil {
+			deployment = green
+		}
+	}
+	if deployment == nil {
+		return nil, nil
+	}
+	annotations := deployment.Spec.Template.ObjectMeta.Annotations
+	return parseTimeAnnotation(annotations[LastMapBuildTimeAnnotation]), parseTimeAnnotation(annotations[LastTrafficUpdateTimeAnnotation])
+}
+
+func parseTimeAnnotation(value string) *metav1.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &metav1.Time{Time: parsed}
+}
+
+// DetectRegression flags a health check that started failing after the map data
+// of its profile was rebuilt or its speeds were updated, until it passes again.
+func DetectRegression(result *osrmv1alpha1.HealthCheckStatus, previous *osrmv1alpha1.HealthCheckStatus) {
+	if previous == nil || result.Healthy {
+		return
+	}
+	if !previous.Healthy {
+		result.Regression = previous.Regression
+		return
+	}
+
+	switch {
+	case isLater(result.MapBuildTime, previous.MapBuildTime):
+		result.Regression = osrmv1alpha1.HealthCheckRegressionMapBuild
+	case isLater(result.SpeedUpdateTime, previous.SpeedUpdateTime):
+		result.Regression = osrmv1alpha1.HealthCheckRegressionSpeedUpdate
+	}
+}
+
+// RoutingHealthyCondition reports the failed health checks, and whether they
+// regressed since a map build or a speed update.
+func RoutingHealthyCondition(results []osrmv1alpha1.HealthCheckStatus) metav1.Condition {
+	failures := []string{}
+	regressed := false
+	for _, result := range results {
+		if result.Healthy {
+			continue
+		}
+		failure := fmt.Sprintf("%s: %s", result.Name, result.Message)
+		if result.Regression != "" {
+			regressed = true
+			failure = fmt.Sprintf("%s (since the %s of profile %s)", failure, RegressionCause(result.Regression), result.Profile)
+		}
+		failures = append(failures, failure)
+	}
+
+	if len(failures) == 0 {
+		return metav1.Condition{
+			Type:    status.ConditionRoutingHealthy,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Healthy",
+			Message: fmt.Sprintf("All %d health checks passed", len(results)),
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:    status.ConditionRoutingHealthy,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Unhealthy",
+		Message: strings.Join(failures, "\n"),
+	}
+	if regressed {
+		condition.Reason = "Regression"
+	}
+	return condition
+}
+
+// RegressionCause describes what a health check regressed since.
+func RegressionCause(regression osrmv1alpha1.HealthCheckRegression) string {
+	if regression == osrmv1alpha1.HealthCheckRegressionSpeedUpdate {
+		return "speed update"
+	}
+	return "map build"
+}
+
+// isLater returns true when a time is set and later than a previous one.
+func isLater(t, previous *metav1.Time) bool {
+	return t != nil && (previous == nil || t.After(previous.Time))
+}
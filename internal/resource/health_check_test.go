@@ -0,0 +1,205 @@
+package resource_test
+
+import (
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var _ = Describe("HealthCheck", func() {
+	var check *osrmv1alpha1.HealthCheckSpec
+
+	BeforeEach(func() {
+		instance.Namespace = "osrm"
+		check = &osrmv1alpha1.HealthCheckSpec{
+			Name:        "tel-aviv-haifa",
+			Profile:     "car",
+			Coordinates: []string{"34.78,32.08", "34.99,32.79"},
+		}
+	})
+
+	AfterEach(func() {
+		instance.Namespace = ""
+	})
+
+	It("Should query the gateway with the endpoint of the profile", func() {
+		url, err := resource.HealthCheckURL(instance, check)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(url).To(Equal("http://test.osrm.svc/route/v1/driving/34.78,32.08;34.99,32.79?overview=false"))
+
+		service := "table"
+		check.Service = &service
+		url, err = resource.HealthCheckURL(instance, check)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(url).To(HaveSuffix("/table/v1/driving/34.78,32.08;34.99,32.79?annotations=duration,distance"))
+	})
+
+	It("Should fail for a profile that does not exist", func() {
+		check.Profile = "bicycle"
+		_, err := resource.HealthCheckURL(instance, check)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should read the duration and distance of the first route", func() {
+		answer, err := resource.ParseHealthCheckAnswer(check, []byte(`{"code":"Ok","routes":[{"duration":5400.5,"distance":95000.2},{"duration":1,"distance":1}]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*answer.Duration).To(Equal(5400500 * time.Millisecond))
+		Expect(*answer.Distance).To(Equal(95000.2))
+
+		check.MaxDuration = &metav1.Duration{Duration: 2 * time.Hour}
+		Expect(answer.Verify(check)).To(Succeed())
+		check.MaxDuration = &metav1.Duration{Duration: time.Hour}
+		Expect(answer.Verify(check)).To(MatchError(ContainSubstring("exceeds 1h0m0s")))
+
+		maxDistance := int32(90000)
+		check.MaxDuration = nil
+		check.MaxDistance = &maxDistance
+		Expect(answer.Verify(check)).To(MatchError(ContainSubstring("exceeds 90000m")))
+	})
+
+	It("Should read the travel time from the first to the last coordinate of a table", func() {
+		service := "table"
+		check.Service = &service
+		answer, err := resource.ParseHealthCheckAnswer(check, []byte(`{"code":"Ok","durations":[[0,600]],"distances":[[0,null]]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*answer.Duration).To(Equal(10 * time.Minute))
+		Expect(answer.Distance).To(BeNil())
+
+		_, err = resource.ParseHealthCheckAnswer(check, []byte(`{"code":"Ok","durations":[[0,null]],"distances":[[0,null]]}`))
+		Expect(err).To(MatchError(ContainSubstring("no route")))
+	})
+
+	It("Should read the distance of the nearest waypoint", func() {
+		service := "nearest"
+		check.Service = &service
+		answer, err := resource.ParseHealthCheckAnswer(check, []byte(`{"code":"Ok","waypoints":[{"distance":12.5}]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answer.Duration).To(BeNil())
+		Expect(*answer.Distance).To(Equal(12.5))
+	})
+
+	It("Should fail when OSRM answers with an error", func() {
+		_, err := resource.ParseHealthCheckAnswer(check, []byte(`{"code":"NoRoute","message":"Impossible route between points"}`))
+		Expect(err).To(MatchError("OSRM answered NoRoute: Impossible route between points"))
+
+		_, err = resource.ParseHealthCheckAnswer(check, []byte(`<html>502 Bad Gateway</html>`))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("DetectRegression", func() {
+	var earlier, later metav1.Time
+
+	BeforeEach(func() {
+		earlier = metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		later = metav1.NewTime(earlier.Add(time.Hour))
+	})
+
+	It("Should flag a health check that fails since a map build", func() {
+		previous := &osrmv1alpha1.HealthCheckStatus{Name: "check", Healthy: true, MapBuildTime: &earlier}
+		result := &osrmv1alpha1.HealthCheckStatus{Name: "check", MapBuildTime: &later}
+		resource.DetectRegression(result, previous)
+		Expect(result.Regression).To(Equal(osrmv1alpha1.HealthCheckRegressionMapBuild))
+	})
+
+	It("Should flag a health check that fails since a speed update", func() {
+		previous := &osrmv1alpha1.HealthCheckStatus{Name: "check", Healthy: true, MapBuildTime: &earlier}
+		result := &osrmv1alpha1.HealthCheckStatus{Name: "check", MapBuildTime: &earlier, SpeedUpdateTime: &later}
+		resource.DetectRegression(result, previous)
+		Expect(result.Regression).To(Equal(osrmv1alpha1.HealthCheckRegressionSpeedUpdate))
+	})
+
+	It("Should not flag a health check that fails without a change of map data", func() {
+		previous := &osrmv1alpha1.HealthCheckStatus{Name: "check", Healthy: true, MapBuildTime: &earlier}
+		result := &osrmv1alpha1.HealthCheckStatus{Name: "check", MapBuildTime: &earlier}
+		resource.DetectRegression(result, previous)
+		Expect(result.Regression).To(BeEmpty())
+
+		resource.DetectRegression(result, nil)
+		Expect(result.Regression).To(BeEmpty())
+	})
+
+	It("Should keep the regression until the health check passes again", func() {
+		previous := &osrmv1alpha1.HealthCheckStatus{Name: "check", Regression: osrmv1alpha1.HealthCheckRegressionMapBuild}
+		result := &osrmv1alpha1.HealthCheckStatus{Name: "check"}
+		resource.DetectRegression(result, previous)
+		Expect(result.Regression).To(Equal(osrmv1alpha1.HealthCheckRegressionMapBuild))
+
+		result = &osrmv1alpha1.HealthCheckStatus{Name: "check", Healthy: true}
+		resource.DetectRegression(result, previous)
+		Expect(result.Regression).To(BeEmpty())
+	})
+})
+
+var _ = Describe("RoutingHealthyCondition", func() {
+	It("Should be true when all health checks passed", func() {
+		condition := resource.RoutingHealthyCondition([]osrmv1alpha1.HealthCheckStatus{
+			{Name: "a", Healthy: true},
+			{Name: "b", Healthy: true},
+		})
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("All 2 health checks passed"))
+	})
+
+	It("Should report the failed health checks and their regressions", func() {
+		condition := resource.RoutingHealthyCondition([]osrmv1alpha1.HealthCheckStatus{
+			{Name: "a", Healthy: true},
+			{Name: "b", Message: "no route"},
+		})
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("Unhealthy"))
+		Expect(condition.Message).To(Equal("b: no route"))
+
+		condition = resource.RoutingHealthyCondition([]osrmv1alpha1.HealthCheckStatus{
+			{Name: "b", Profile: "car", Message: "no route", Regression: osrmv1alpha1.HealthCheckRegressionSpeedUpdate},
+			{Name: "c", Message: "timeout"},
+		})
+		Expect(condition.Reason).To(Equal("Regression"))
+		Expect(condition.Message).To(Equal("b: no route (since the speed update of profile car)\nc: timeout"))
+	})
+})
+
+var _ = Describe("ServedMapData", func() {
+	var deployment, green *appsv1.Deployment
+
+	BeforeEach(func() {
+		deployment = &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: instance.ChildResourceName("car", resource.DeploymentSuffix)}}
+		deployment.Spec.Template.Annotations = map[string]string{
+			resource.LastMapBuildTimeAnnotation:      "2024-01-01T00:00:00Z",
+			resource.LastTrafficUpdateTimeAnnotation: "2024-01-01T01:00:00Z",
+		}
+		green = &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: instance.ChildResourceName("car", resource.GreenSuffix)}}
+		green.Spec.Template.Annotations = map[string]string{
+			resource.LastMapBuildTimeAnnotation: "2024-01-02T00:00:00Z",
+		}
+	})
+
+	AfterEach(func() {
+		instance.Status.Switchovers = nil
+	})
+
+	It("Should read the map data served by the profile's Deployment", func() {
+		mapBuildTime, speedUpdateTime := resource.ServedMapData(instance, "car", newChildResources([]runtime.Object{deployment, green}))
+		Expect(mapBuildTime.Time).To(Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+		Expect(speedUpdateTime.Time).To(Equal(time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)))
+
+		mapBuildTime, speedUpdateTime = resource.ServedMapData(instance, "car", nil)
+		Expect(mapBuildTime).To(BeNil())
+		Expect(speedUpdateTime).To(BeNil())
+	})
+
+	It("Should read the map data served by the green Deployment while it receives requests", func() {
+		instance.Status.Switchovers = []osrmv1alpha1.SwitchoverStatus{
+			{Profile: "car", Phase: osrmv1alpha1.SwitchoverPhaseShifting, Weight: 10},
+		}
+		mapBuildTime, speedUpdateTime := resource.ServedMapData(instance, "car", newChildResources([]runtime.Object{deployment, green}))
+		Expect(mapBuildTime.Time).To(Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)))
+		Expect(speedUpdateTime).To(BeNil())
+	})
+})
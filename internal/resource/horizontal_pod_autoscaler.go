@@ -5,10 +5,8 @@ import (
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
-	"github.com/itayankri/OSRM-Operator/internal/status"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -30,16 +28,16 @@ func (builder *HorizontalPodAutoscalerBuilder) Build() (client.Object, error) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      builder.Instance.ChildResourceName(builder.profile.Name, HorizontalPodAutoscalerSuffix),
 			Namespace: builder.Instance.Namespace,
-			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile),
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
 		},
 	}, nil
 }
 
-func (builder *HorizontalPodAutoscalerBuilder) Update(object client.Object, siblings []runtime.Object) error {
+func (builder *HorizontalPodAutoscalerBuilder) Update(object client.Object, siblings *ChildResources) error {
 	name := builder.Instance.ChildResourceName(builder.profile.Name, HorizontalPodAutoscalerSuffix)
 	hpa := object.(*autoscalingv1.HorizontalPodAutoscaler)
 
-	hpa.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile)
+	hpa.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
 
 	targetCPUUtilizationPercentage := int32(85)
 	profileSpec := getProfileSpec(builder.profile.Name, builder.Instance)
@@ -60,13 +58,6 @@ func (builder *HorizontalPodAutoscalerBuilder) Update(object client.Object, sibl
 	return nil
 }
 
-func (builder *HorizontalPodAutoscalerBuilder) ShouldDeploy(resources []runtime.Object) bool {
-	return status.IsPersistentVolumeClaimBound(
-		builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
-		resources,
-	) &&
-		status.IsJobCompleted(
-			builder.Instance.ChildResourceName(builder.profile.Name, JobSuffix),
-			resources,
-		)
+func (builder *HorizontalPodAutoscalerBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.isProfileDataReady(builder.profile, resources)
 }
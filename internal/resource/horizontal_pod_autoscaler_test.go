@@ -15,22 +15,22 @@ var _ = Describe("HorizontalPodAutoscaler builder", func() {
 
 		It("Should return 'false' when both PVC is bound and map builder Job is not completed yet", func() {
 			resources := generateChildResources(false, false, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'false' when PVC is bound but map builder Job is not completed yet", func() {
 			resources := generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'false' when PVC is not bound but map builder Job is completed", func() {
 			resources := generateChildResources(false, true, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(false))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
 		})
 
 		It("Should return 'true' when both PVC is bound and map builder Job is compoleted", func() {
 			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
-			Expect(builder.ShouldDeploy(resources)).To(Equal(true))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
 		})
 	})
 })
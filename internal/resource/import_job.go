@@ -0,0 +1,260 @@
+package resource
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const prebuiltSourceVolumeName = "prebuilt-source"
+const prebuiltSourcePath = "/source"
+
+// requiredDatasetFiles lists the files, by extension, that osrm-routed needs for each algorithm.
+var requiredDatasetFiles = map[string][]string{
+	osrmv1alpha1.AlgorithmMLD: {".osrm.properties", ".osrm.names", ".osrm.geometry", ".osrm.partition", ".osrm.cells", ".osrm.mldgr"},
+	osrmv1alpha1.AlgorithmCH:  {".osrm.properties", ".osrm.names", ".osrm.geometry", ".osrm.hsgr"},
+}
+
+// fetchArchiveScript, syncBucketScript and copyVolumeScript fetch a prebuilt
+// dataset into the current directory and verify it against CHECKSUM.
+const fetchArchiveScript = `
+curl -fL -o /tmp/dataset.tar "$SOURCE"
+if [ -n "$CHECKSUM" ]; then
+	echo "$CHECKSUM  /tmp/dataset.tar" | sha256sum -c -
+fi
+tar -xf /tmp/dataset.tar
+rm /tmp/dataset.tar
+`
+
+const syncBucketScript = `
+aws s3 sync "$OBJECT_STORAGE_URL/" .
+`
+
+const copyVolumeScript = `
+cp -r "$SOURCE"/. .
+`
+
+// importScript stages the dataset next to the data directories, validates it
+// and only then replaces the partitioned and customized map data with it.
+const importScript = `
+set -e
+STAGING_DIR="$ROOT_DIR/import"
+rm -rf "$STAGING_DIR"
+mkdir -p "$STAGING_DIR"
+cd "$STAGING_DIR"
+
+%s
+
+if [ "$SOURCE_TYPE" != "url" ] && [ -n "$CHECKSUM" ]; then
+	echo "$CHECKSUM  SHA256SUMS" | sha256sum -c -
+fi
+if [ -f SHA256SUMS ]; then
+	sha256sum -c SHA256SUMS
+fi
+
+for EXTENSION in $REQUIRED_FILES; do
+	if [ ! -f "$OSRM_FILE_NAME$EXTENSION" ]; then
+		echo "Dataset is missing $OSRM_FILE_NAME$EXTENSION required by the $ALGORITHM algorithm"
+		exit 1
+	fi
+done
+
+rm -rf "$ROOT_DIR/$PARTITIONED_DATA_DIR" "$ROOT_DIR/$CUSTOMIZED_DATA_DIR"
+cp -r "$STAGING_DIR" "$ROOT_DIR/$PARTITIONED_DATA_DIR"
+mv "$STAGING_DIR" "$ROOT_DIR/$CUSTOMIZED_DATA_DIR"
+`
+
+// ImportJobBuilder builds the Job that seeds a profile's map data volume from a
+// prebuilt dataset. It replaces the map builder Job when spec.mapSource.prebuilt is set.
+type ImportJobBuilder struct {
+	ProfileScopedBuilder
+	*OSRMResourceBuilder
+}
+
+func (builder *OSRMResourceBuilder) ImportJob(profile *osrmv1alpha1.ProfileSpec) *ImportJobBuilder {
+	return &ImportJobBuilder{
+		ProfileScopedBuilder{profile},
+		builder,
+	}
+}
+
+func (builder *ImportJobBuilder) Build() (client.Object, error) {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(builder.profile.Name, ImportJobSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
+		},
+	}, nil
+}
+
+func (builder *ImportJobBuilder) Update(object client.Object, siblings *ChildResources) error {
+	job := object.(*batchv1.Job)
+	prebuilt := builder.Instance.Spec.MapSource.Prebuilt
+	name := builder.Instance.ChildResourceName(builder.profile.Name, ImportJobSuffix)
+	algorithm := builder.Instance.Spec.GetAlgorithm()
+
+	job.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "ROOT_DIR",
+			Value: osrmDataPath,
+		},
+		{
+			Name:  "PARTITIONED_DATA_DIR",
+			Value: osrmPartitionedData,
+		},
+		{
+			Name:  "CUSTOMIZED_DATA_DIR",
+			Value: osrmCustomizedData,
+		},
+		{
+			Name:  "OSRM_FILE_NAME",
+			Value: builder.Instance.Spec.GetOsrmFileName(),
+		},
+		{
+			Name:  "ALGORITHM",
+			Value: algorithm,
+		},
+		{
+			Name:  "REQUIRED_FILES",
+			Value: strings.Join(requiredDatasetFiles[algorithm], " "),
+		},
+		{
+			Name:  "CHECKSUM",
+			Value: prebuilt.Checksums[builder.profile.Name],
+		},
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name: osrmDataVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
+					ReadOnly:  false,
+				},
+			},
+		},
+	}
+
+	var container corev1.Container
+	switch {
+	case prebuilt.ObjectStorage != nil:
+		container = objectStorageContainer(
+			prebuilt.ObjectStorage,
+			builder.Instance,
+			builder.profile,
+			name,
+			fmt.Sprintf(importScript, syncBucketScript),
+			false,
+		)
+		container.Env = appendMissingEnv(container.Env, append(env, corev1.EnvVar{Name: "SOURCE_TYPE", Value: "objectStorage"})...)
+	case prebuilt.PersistentVolumeClaim != nil:
+		container = builder.importContainer(name, copyVolumeScript, append(env, []corev1.EnvVar{
+			{
+				Name:  "SOURCE_TYPE",
+				Value: "persistentVolumeClaim",
+			},
+			{
+				Name:  "SOURCE",
+				Value: path.Join(prebuiltSourcePath, prebuilt.PersistentVolumeClaim.Path, builder.profile.Name),
+			},
+		}...))
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      prebuiltSourceVolumeName,
+			MountPath: prebuiltSourcePath,
+			ReadOnly:  true,
+		})
+		volumes = append(volumes, corev1.Volume{
+			Name: prebuiltSourceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: prebuilt.PersistentVolumeClaim.ClaimName,
+					ReadOnly:  true,
+				},
+			},
+		})
+	default:
+		container = builder.importContainer(name, fetchArchiveScript, append(env, []corev1.EnvVar{
+			{
+				Name:  "SOURCE_TYPE",
+				Value: "url",
+			},
+			{
+				Name:  "SOURCE",
+				Value: prebuilt.GetURL(builder.profile.Name),
+			},
+		}...))
+	}
+
+	container.TerminationMessagePolicy = corev1.TerminationMessageFallbackToLogsOnError
+
+	// The pod template of a Job is immutable, so it is only set on creation.
+	if job.CreationTimestamp.IsZero() {
+		setMapRebuildToken(job, builder.Instance)
+		setOSRMVersion(job, builder.Instance)
+		job.Spec = batchv1.JobSpec{
+			Selector: job.Spec.Selector,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: job.Spec.Template.ObjectMeta.Labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+					Containers:    []corev1.Container{container},
+					Volumes:       volumes,
+				},
+			},
+		}
+	}
+
+	if backoffLimit := builder.Instance.Spec.GetMapBuilderBackoffLimit(builder.profile, ""); backoffLimit != nil {
+		job.Spec.BackoffLimit = backoffLimit
+	}
+	job.Spec.ActiveDeadlineSeconds = builder.Instance.Spec.GetMapBuilderActiveDeadlineSeconds(builder.profile, "")
+	job.Spec.TTLSecondsAfterFinished = builder.Instance.Spec.MapBuilder.TTLSecondsAfterFinished
+
+	if err := controllerutil.SetControllerReference(builder.Instance, job, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *ImportJobBuilder) importContainer(name string, fetchScript string, env []corev1.EnvVar) corev1.Container {
+	return corev1.Container{
+		Name:      name,
+		Image:     builder.Instance.Spec.MapBuilder.GetImage(builder.Instance.Spec.GetOSRMVersion()),
+		Resources: *builder.Instance.Spec.MapBuilder.GetResources(),
+		Command:   []string{"/bin/bash", "-c"},
+		Args:      []string{fmt.Sprintf(importScript, fetchScript)},
+		Env:       env,
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      osrmDataVolumeName,
+				MountPath: osrmDataPath,
+			},
+		},
+	}
+}
+
+func (builder *ImportJobBuilder) ShouldDeploy(resources *ChildResources) bool {
+	if !builder.Instance.Spec.IsPrebuilt() || !builder.Instance.Spec.HasMapDataVolume() {
+		return false
+	}
+	if builder.hasExistingMapData(builder.profile, resources) {
+		return status.GetJob(builder.Instance.ChildResourceName(builder.profile.Name, ImportJobSuffix), resources.Of(ProfileKey(builder.profile.Name))) != nil
+	}
+	return true
+}
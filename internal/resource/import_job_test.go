@@ -0,0 +1,108 @@
+package resource_test
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("ImportJob builder", func() {
+	var builder resource.ResourceBuilder
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+		builder = (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).ImportJob(instance.Spec.Profiles[0])
+		url := "https://example.com/{profile}.tar"
+		instance.Spec.MapSource = &osrmv1alpha1.MapSourceSpec{
+			Prebuilt: &osrmv1alpha1.PrebuiltMapSpec{
+				URL:       &url,
+				Checksums: map[string]string{"car": "abc123"},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		instance.Spec.MapSource = nil
+	})
+
+	Context("ShouldDeploy", func() {
+		It("Should return 'false' when map data is built from the PBF file", func() {
+			instance.Spec.MapSource = nil
+			Expect(builder.ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(false))
+		})
+
+		It("Should return 'true' when map data is prebuilt", func() {
+			Expect(builder.ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(true))
+		})
+
+		It("Should replace the map builder Job", func() {
+			Expect(osrmResourceBuilder.Job(instance.Spec.Profiles[0]).ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(false))
+		})
+
+		It("Should gate workers on the import Job instead of the map builder Job", func() {
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			deploymentBuilder := osrmResourceBuilder.Deployment(instance.Spec.Profiles[0])
+			Expect(deploymentBuilder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+
+			resources = append(resources, &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("%s-%s-%s", instance.Name, instance.Spec.Profiles[0].Name, resource.ImportJobSuffix),
+				},
+				Status: batchv1.JobStatus{
+					Conditions: []batchv1.JobCondition{
+						{
+							Type:   batchv1.JobComplete,
+							Status: corev1.ConditionTrue,
+						},
+					},
+				},
+			})
+			Expect(deploymentBuilder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
+		})
+	})
+
+	Context("Update", func() {
+		It("Should fetch the profile's archive and validate it for the chosen algorithm", func() {
+			algorithm := osrmv1alpha1.AlgorithmCH
+			instance.Spec.MapSource.Prebuilt.Algorithm = &algorithm
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+
+			container := obj.(*batchv1.Job).Spec.Template.Spec.Containers[0]
+			Expect(container.Env).To(ContainElements(
+				corev1.EnvVar{Name: "SOURCE", Value: "https://example.com/car.tar"},
+				corev1.EnvVar{Name: "CHECKSUM", Value: "abc123"},
+				corev1.EnvVar{Name: "ALGORITHM", Value: "ch"},
+				corev1.EnvVar{Name: "REQUIRED_FILES", Value: ".osrm.properties .osrm.names .osrm.geometry .osrm.hsgr"},
+			))
+		})
+
+		It("Should mount the source volume when importing from a PersistentVolumeClaim", func() {
+			instance.Spec.MapSource.Prebuilt.URL = nil
+			instance.Spec.MapSource.Prebuilt.PersistentVolumeClaim = &osrmv1alpha1.PrebuiltVolumeSource{
+				ClaimName: "datasets",
+				Path:      "/israel/",
+			}
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+
+			podSpec := obj.(*batchv1.Job).Spec.Template.Spec
+			Expect(podSpec.Volumes).To(HaveLen(2))
+			Expect(podSpec.Volumes[1].PersistentVolumeClaim.ClaimName).To(Equal("datasets"))
+			Expect(podSpec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "SOURCE", Value: "/source/israel/car"}))
+		})
+	})
+})
@@ -5,45 +5,106 @@ import (
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/status"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+// JobBuilder builds the map builder Job of a profile, the Job of a single
+// stage when the map build is split into stages, or the Job that builds the
+// canary's map data into a separate directory during a canary upgrade.
 type JobBuilder struct {
 	ProfileScopedBuilder
 	*OSRMResourceBuilder
+	stage  osrmv1alpha1.MapBuilderStage
+	canary bool
 }
 
 func (builder *OSRMResourceBuilder) Job(profile *osrmv1alpha1.ProfileSpec) *JobBuilder {
 	return &JobBuilder{
 		ProfileScopedBuilder{profile},
 		builder,
+		"",
+		false,
 	}
 }
 
+func (builder *OSRMResourceBuilder) StageJob(profile *osrmv1alpha1.ProfileSpec, stage osrmv1alpha1.MapBuilderStage) *JobBuilder {
+	return &JobBuilder{
+		ProfileScopedBuilder{profile},
+		builder,
+		stage,
+		false,
+	}
+}
+
+func (builder *JobBuilder) suffix() string {
+	if builder.canary {
+		return CanaryJobSuffix
+	}
+	if builder.stage == "" {
+		return JobSuffix
+	}
+	return MapBuilderStageJobSuffix(builder.stage)
+}
+
 func (builder *JobBuilder) Build() (client.Object, error) {
 	return &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      builder.Instance.ChildResourceName(builder.profile.Name, JobSuffix),
+			Name:      builder.Instance.ChildResourceName(builder.profile.Name, builder.suffix()),
 			Namespace: builder.Instance.Namespace,
-			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile),
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
 		},
 	}, nil
 }
 
-func (builder *JobBuilder) Update(object client.Object, siblings []runtime.Object) error {
+func (builder *JobBuilder) Update(object client.Object, siblings *ChildResources) error {
 	job := object.(*batchv1.Job)
 
-	job.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile)
+	job.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
+
+	// The pod template of a Job is immutable, so it is only set on creation.
+	// Rerunning a Job with a new template requires deleting it first.
+	if job.CreationTimestamp.IsZero() {
+		setMapRebuildToken(job, builder.Instance)
+		setOSRMVersion(job, builder.Instance)
+		job.Spec = batchv1.JobSpec{
+			Selector: job.Spec.Selector,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: job.Spec.Template.ObjectMeta.Labels,
+				},
+				Spec: builder.podSpec(),
+			},
+		}
+	}
+
+	if backoffLimit := builder.Instance.Spec.GetMapBuilderBackoffLimit(builder.profile, builder.stage); backoffLimit != nil {
+		job.Spec.BackoffLimit = backoffLimit
+	}
+	job.Spec.ActiveDeadlineSeconds = builder.Instance.Spec.GetMapBuilderActiveDeadlineSeconds(builder.profile, builder.stage)
+	job.Spec.TTLSecondsAfterFinished = builder.Instance.Spec.MapBuilder.TTLSecondsAfterFinished
+
+	if err := controllerutil.SetControllerReference(builder.Instance, job, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *JobBuilder) podSpec() corev1.PodSpec {
+	rootDir := osrmDataPath
+	if builder.canary {
+		rootDir = fmt.Sprintf("%s/%s", osrmDataPath, osrmCanaryData)
+	}
 
 	env := []corev1.EnvVar{
 		{
 			Name:  "ROOT_DIR",
-			Value: osrmDataPath,
+			Value: rootDir,
 		},
 		{
 			Name:  "PARTITIONED_DATA_DIR",
@@ -63,6 +124,13 @@ func (builder *JobBuilder) Update(object client.Object, siblings []runtime.Objec
 		},
 	}
 
+	if builder.stage != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  "STAGE",
+			Value: string(builder.stage),
+		})
+	}
+
 	if builder.Instance.Spec.MapBuilder.ExtractOptions != nil {
 		env = append(env, corev1.EnvVar{
 			Name:  "EXTRACT_OPTIONS",
@@ -84,50 +152,128 @@ func (builder *JobBuilder) Update(object client.Object, siblings []runtime.Objec
 		})
 	}
 
-	job.Spec = batchv1.JobSpec{
-		Selector: job.Spec.Selector,
-		Template: corev1.PodTemplateSpec{
-			ObjectMeta: metav1.ObjectMeta{
-				Labels: job.Spec.Template.ObjectMeta.Labels,
-			},
-			Spec: corev1.PodSpec{
-				RestartPolicy: corev1.RestartPolicyOnFailure,
-				Containers: []corev1.Container{
-					{
-						Name:      builder.Instance.ChildResourceName(builder.profile.Name, JobSuffix),
-						Image:     builder.Instance.Spec.MapBuilder.GetImage(),
-						Resources: *builder.Instance.Spec.MapBuilder.GetResources(),
-						Env:       env,
-						VolumeMounts: []corev1.VolumeMount{
-							{
-								Name:      osrmDataVolumeName,
-								MountPath: osrmDataPath,
-							},
-						},
-					},
-				},
-				Volumes: []corev1.Volume{
-					{
-						Name: osrmDataVolumeName,
-						VolumeSource: corev1.VolumeSource{
-							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-								ClaimName: builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
-								ReadOnly:  false,
-							},
-						},
-					},
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      osrmDataVolumeName,
+			MountPath: osrmDataPath,
+		},
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name: osrmDataVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
+					ReadOnly:  false,
 				},
 			},
 		},
 	}
 
-	if err := controllerutil.SetControllerReference(builder.Instance, job, builder.Scheme); err != nil {
-		return fmt.Errorf("failed setting controller reference: %v", err)
+	if builder.Instance.Spec.MapBuilder.SharedDownload != nil && builder.downloadsPBF() {
+		env = append(env, corev1.EnvVar{
+			Name:  "PBF_PATH",
+			Value: fmt.Sprintf("%s/%s", pbfCachePath, builder.Instance.Spec.GetPbfFileName()),
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      pbfCacheVolumeName,
+			MountPath: pbfCachePath,
+			ReadOnly:  true,
+		})
+		volumes = append(volumes, corev1.Volume{
+			Name: pbfCacheVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: builder.Instance.ChildResourceName(GatewaySuffix, PBFPersistentVolumeClaimSuffix),
+					ReadOnly:  true,
+				},
+			},
+		})
 	}
 
-	return nil
+	return corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyOnFailure,
+		Containers: []corev1.Container{
+			{
+				Name:         builder.Instance.ChildResourceName(builder.profile.Name, builder.suffix()),
+				Image:        builder.Instance.Spec.GetMapBuilderImage(builder.profile, builder.stage),
+				Resources:    *builder.Instance.Spec.GetMapBuilderResources(builder.profile, builder.stage),
+				Env:          env,
+				VolumeMounts: volumeMounts,
+				// The tail of the logs becomes the termination message of a failed
+				// container, which is reported by the MapBuildFailed condition.
+				TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+			},
+		},
+		Volumes: volumes,
+	}
 }
 
-func (builder *JobBuilder) ShouldDeploy(resources []runtime.Object) bool {
+func (builder *JobBuilder) ShouldDeploy(resources *ChildResources) bool {
+	if !builder.Instance.Spec.HasMapDataVolume() || builder.Instance.Spec.IsPrebuilt() {
+		return false
+	}
+	if builder.canary {
+		return isCanaryProfile(builder.Instance, builder.profile, osrmv1alpha1.OSRMUpgradePhaseBuilding, osrmv1alpha1.OSRMUpgradePhaseAnalyzing) &&
+			builder.isPBFDownloaded(resources)
+	}
+	if builder.Instance.Spec.SplitsMapBuild(builder.profile) != (builder.stage != "") {
+		return false
+	}
+	// Volumes with existing map data are not built again, but an existing Job
+	// is kept up to date so it is not garbage collected.
+	jobName := builder.Instance.ChildResourceName(builder.profile.Name, builder.suffix())
+	if builder.hasExistingMapData(builder.profile, resources) {
+		return status.GetJob(jobName, resources.Of(ProfileKey(builder.profile.Name))) != nil
+	}
+	if previousStage := builder.previousStage(); previousStage != "" {
+		return status.IsJobCompleted(
+			builder.Instance.ChildResourceName(builder.profile.Name, MapBuilderStageJobSuffix(previousStage)),
+			resources.Of(ProfileKey(builder.profile.Name)),
+		)
+	}
+	return builder.isPBFDownloaded(resources)
+}
+
+// isPBFDownloaded returns false until the shared download Job completed, if enabled.
+// A download Job that is being deleted by a rebuild no longer counts.
+func (builder *JobBuilder) isPBFDownloaded(resources *ChildResources) bool {
+	if builder.Instance.Spec.MapBuilder.SharedDownload != nil {
+		jobName := builder.Instance.ChildResourceName(GatewaySuffix, DownloadJobSuffix)
+		job := status.GetJob(jobName, resources.Of(DownloadKey))
+		return job != nil && job.DeletionTimestamp == nil && status.IsJobCompleted(jobName, resources.Of(DownloadKey))
+	}
 	return true
 }
+
+// downloadsPBF returns true when the Job needs the PBF file, i.e. it runs the extract stage.
+func (builder *JobBuilder) downloadsPBF() bool {
+	return builder.stage == "" || builder.stage == osrmv1alpha1.MapBuilderStageExtract
+}
+
+func (builder *JobBuilder) previousStage() osrmv1alpha1.MapBuilderStage {
+	for i, stage := range osrmv1alpha1.MapBuilderStages {
+		if stage == builder.stage && i > 0 {
+			return osrmv1alpha1.MapBuilderStages[i-1]
+		}
+	}
+	return ""
+}
+
+// setMapRebuildToken records the map rebuild request a map data Job serves, which
+// tells it apart from a Job of an earlier build that is still being deleted.
+func setMapRebuildToken(job *batchv1.Job, instance *osrmv1alpha1.OSRMCluster) {
+	if token := instance.Status.MapRebuildToken; token != "" {
+		job.ObjectMeta.Annotations = metadata.ReconcileAnnotations(job.ObjectMeta.Annotations, map[string]string{
+			OnDemandTokenAnnotation: token,
+		})
+	}
+}
+
+// setOSRMVersion records the OSRM release a map data Job builds the map data with.
+func setOSRMVersion(job *batchv1.Job, instance *osrmv1alpha1.OSRMCluster) {
+	job.ObjectMeta.Annotations = metadata.ReconcileAnnotations(job.ObjectMeta.Annotations, map[string]string{
+		OSRMVersionAnnotation: instance.Spec.GetOSRMVersion(),
+	})
+}
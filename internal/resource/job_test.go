@@ -1,10 +1,19 @@
 package resource_test
 
 import (
+	"fmt"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/resource"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
 )
 
 var _ = Describe("Job builder", func() {
@@ -16,7 +25,152 @@ var _ = Describe("Job builder", func() {
 
 		It("Should always return 'true'", func() {
 			resources := []runtime.Object{}
-			Expect(builder.ShouldDeploy(resources)).To(Equal(true))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
+		})
+
+		It("Should return 'false' when the PVC holds retained map data", func() {
+			resources := []runtime.Object{
+				&corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        fmt.Sprintf("%s-%s", instance.Name, instance.Spec.Profiles[0].Name),
+						Annotations: map[string]string{resource.RetainedMapDataAnnotation: "2024-01-01T00:00:00Z"},
+					},
+				},
+			}
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+		})
+
+		It("Should return 'false' until the shared download Job is completed", func() {
+			instance.Spec.MapBuilder.SharedDownload = &osrmv1alpha1.SharedDownloadSpec{}
+			defer func() { instance.Spec.MapBuilder.SharedDownload = nil }()
+
+			downloadJob := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("%s-%s", instance.Name, resource.DownloadJobSuffix),
+				},
+			}
+			Expect(builder.ShouldDeploy(newChildResources([]runtime.Object{downloadJob}))).To(Equal(false))
+
+			downloadJob.Status.Conditions = []batchv1.JobCondition{
+				{
+					Type:   batchv1.JobComplete,
+					Status: corev1.ConditionTrue,
+				},
+			}
+			Expect(builder.ShouldDeploy(newChildResources([]runtime.Object{downloadJob}))).To(Equal(true))
+
+			downloadJob.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			Expect(builder.ShouldDeploy(newChildResources([]runtime.Object{downloadJob}))).To(Equal(false))
+		})
+	})
+
+	Context("Update", func() {
+		var builder resource.ResourceBuilder
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder = (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Job(instance.Spec.Profiles[0])
+		})
+
+		AfterEach(func() {
+			instance.Spec.MapBuilder.BackoffLimit = nil
+			instance.Spec.MapBuilder.ActiveDeadlineSeconds = nil
+			instance.Spec.MapBuilder.TTLSecondsAfterFinished = nil
+		})
+
+		It("Should apply the retry, deadline and TTL settings", func() {
+			backoffLimit := int32(2)
+			activeDeadlineSeconds := int64(3600)
+			ttlSecondsAfterFinished := int32(600)
+			instance.Spec.MapBuilder.BackoffLimit = &backoffLimit
+			instance.Spec.MapBuilder.ActiveDeadlineSeconds = &activeDeadlineSeconds
+			instance.Spec.MapBuilder.TTLSecondsAfterFinished = &ttlSecondsAfterFinished
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+
+			job := obj.(*batchv1.Job)
+			Expect(*job.Spec.BackoffLimit).To(Equal(backoffLimit))
+			Expect(*job.Spec.ActiveDeadlineSeconds).To(Equal(activeDeadlineSeconds))
+			Expect(*job.Spec.TTLSecondsAfterFinished).To(Equal(ttlSecondsAfterFinished))
+			Expect(job.Spec.Template.Spec.Containers[0].TerminationMessagePolicy).To(Equal(corev1.TerminationMessageFallbackToLogsOnError))
+		})
+
+		It("Should not change the pod template of an existing Job", func() {
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			job := obj.(*batchv1.Job)
+			job.CreationTimestamp = metav1.Now()
+
+			Expect(builder.Update(job, newChildResources([]runtime.Object{}))).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers).To(BeEmpty())
+		})
+	})
+
+	Context("Stages", func() {
+		BeforeEach(func() {
+			instance.Spec.MapBuilder.Stages = &osrmv1alpha1.MapBuilderStagesSpec{
+				Extract: &osrmv1alpha1.MapBuilderStageSpec{
+					Resources: &corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: k8sresource.MustParse("16Gi")},
+					},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			instance.Spec.MapBuilder.Stages = nil
+			instance.Spec.Profiles[0].MapBuilder = nil
+		})
+
+		It("Should replace the map builder Job with a Job per stage", func() {
+			profile := instance.Spec.Profiles[0]
+			Expect(osrmResourceBuilder.Job(profile).ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(false))
+			Expect(osrmResourceBuilder.StageJob(profile, osrmv1alpha1.MapBuilderStageExtract).ShouldDeploy(newChildResources([]runtime.Object{}))).To(Equal(true))
+		})
+
+		It("Should run each stage after the previous one is completed", func() {
+			partitionBuilder := osrmResourceBuilder.StageJob(instance.Spec.Profiles[0], osrmv1alpha1.MapBuilderStagePartition)
+			extractJob := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("%s-%s-%s-extract", instance.Name, instance.Spec.Profiles[0].Name, resource.JobSuffix),
+				},
+			}
+			Expect(partitionBuilder.ShouldDeploy(newChildResources([]runtime.Object{extractJob}))).To(Equal(false))
+
+			extractJob.Status.Conditions = []batchv1.JobCondition{
+				{
+					Type:   batchv1.JobComplete,
+					Status: corev1.ConditionTrue,
+				},
+			}
+			Expect(partitionBuilder.ShouldDeploy(newChildResources([]runtime.Object{extractJob}))).To(Equal(true))
+		})
+
+		It("Should prefer the profile's settings over the cluster's stage settings", func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			stageBuilder := func(stage osrmv1alpha1.MapBuilderStage) resource.ResourceBuilder {
+				return (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).StageJob(instance.Spec.Profiles[0], stage)
+			}
+			footResources := &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: k8sresource.MustParse("2Gi")},
+			}
+
+			obj, err := stageBuilder(osrmv1alpha1.MapBuilderStageExtract).Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stageBuilder(osrmv1alpha1.MapBuilderStageExtract).Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+			container := obj.(*batchv1.Job).Spec.Template.Spec.Containers[0]
+			Expect(container.Resources.Requests.Memory().String()).To(Equal("16Gi"))
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "STAGE", Value: "extract"}))
+
+			instance.Spec.Profiles[0].MapBuilder = &osrmv1alpha1.ProfileMapBuilderSpec{Resources: footResources}
+			Expect(stageBuilder(osrmv1alpha1.MapBuilderStageExtract).Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+			container = obj.(*batchv1.Job).Spec.Template.Spec.Containers[0]
+			Expect(container.Resources.Requests.Memory().String()).To(Equal("2Gi"))
 		})
 	})
 })
@@ -0,0 +1,18 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+)
+
+// ParseMapInfo parses the build report the map builder writes to the
+// termination message of its container once the map data is customized.
+func ParseMapInfo(terminationMessage string) (*osrmv1alpha1.MapInfo, error) {
+	mapInfo := &osrmv1alpha1.MapInfo{}
+	if err := json.Unmarshal([]byte(terminationMessage), mapInfo); err != nil {
+		return nil, fmt.Errorf("failed parsing map build report: %v", err)
+	}
+	return mapInfo, nil
+}
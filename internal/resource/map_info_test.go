@@ -0,0 +1,25 @@
+package resource_test
+
+import (
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseMapInfo", func() {
+	It("Should parse the map builder's build report", func() {
+		mapInfo, err := resource.ParseMapInfo(`{"nodes":120,"edges":340,"boundingBox":"34.2,29.4,35.9,33.3","osrmVersion":"v5.27.1","profileHash":"abc","stageDurationSeconds":{"extract":10,"partition":5,"customize":2},"outputSizeBytes":4096}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mapInfo.Nodes).To(Equal(int64(120)))
+		Expect(mapInfo.Edges).To(Equal(int64(340)))
+		Expect(mapInfo.BoundingBox).To(Equal("34.2,29.4,35.9,33.3"))
+		Expect(mapInfo.OSRMVersion).To(Equal("v5.27.1"))
+		Expect(mapInfo.StageDurationSeconds).To(HaveKeyWithValue("extract", int64(10)))
+		Expect(mapInfo.OutputSizeBytes).To(Equal(int64(4096)))
+	})
+
+	It("Should fail on a message that is not a build report", func() {
+		_, err := resource.ParseMapInfo("osrm-customize: out of memory")
+		Expect(err).To(HaveOccurred())
+	})
+})
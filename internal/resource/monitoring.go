@@ -0,0 +1,359 @@
+package resource
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// The PodMonitor and ServiceMonitor APIs are served by the Prometheus Operator
+// CRDs, so monitors are handled as unstructured objects.
+const MonitoringAPIGroup = "monitoring.coreos.com"
+const MonitoringAPIVersion = "v1"
+
+// MonitorGVK returns the GroupVersionKind of a Prometheus Operator monitor.
+func MonitorGVK(kind osrmv1alpha1.MonitorKind) schema.GroupVersionKind {
+	return schema.GroupVersionKind{
+		Group:   MonitoringAPIGroup,
+		Version: MonitoringAPIVersion,
+		Kind:    string(kind),
+	}
+}
+
+// nginxMetricsScript counts the requests and their latency per OSRM service,
+// profile and status code, and serves them in the Prometheus text format.
+// Unknown services are counted as "other" to bound the number of series.
+const nginxMetricsScript = `
+var services = ['route', 'nearest', 'table', 'match', 'trip', 'tile'];
+var buckets = ['0.005', '0.01', '0.025', '0.05', '0.1', '0.25', '0.5', '1', '2.5', '5', '10', '+Inf'];
+
+function record(r) {
+	var service = r.uri.split('/')[1];
+	if (services.indexOf(service) < 0) {
+		service = 'other';
+	}
+	var labels = 'service="' + service + '",profile="' + (r.variables.osrm_profile || '') + '"';
+	var duration = Number(r.variables.request_time);
+	var metrics = ngx.shared.osrm_metrics;
+	metrics.incr('osrm_requests_total{' + labels + ',status="' + r.variables.status + '"}', 1, 0);
+	buckets.forEach(function (le) {
+		metrics.incr('osrm_request_duration_seconds_bucket{' + labels + ',le="' + le + '"}', duration <= Number(le) ? 1 : 0, 0);
+	});
+	metrics.incr('osrm_request_duration_seconds_sum{' + labels + '}', duration, 0);
+	metrics.incr('osrm_request_duration_seconds_count{' + labels + '}', 1, 0);
+	return '';
+}
+
+function report(r) {
+	var metrics = ngx.shared.osrm_metrics;
+	var lines = [];
+	var family;
+	metrics.keys(100000).sort().forEach(function (key) {
+		var name = key.split('{')[0].replace(/_(bucket|sum|count)$/, '');
+		if (name !== family) {
+			family = name;
+			lines.push('# TYPE ' + name + (name === 'osrm_requests_total' ? ' counter' : ' histogram'));
+		}
+		lines.push(key + ' ' + metrics.get(key));
+	});
+	r.headersOut['Content-Type'] = 'text/plain; version=0.0.4';
+	r.return(200, lines.join('\n') + '\n');
+}
+
+export default { record, report };
+`
+
+// nginxMetricsConfig records every request of the server it is included in.
+const nginxMetricsConfig = `
+		js_import metrics from %s;
+		js_shared_dict_zone zone=osrm_metrics:1m type=number;
+		js_set $osrm_metrics_record metrics.record;
+		log_format osrm_metrics '$osrm_metrics_record';`
+
+const nginxMetricsServer = `
+		server {
+			listen %d;
+			access_log off;
+			location = %s {
+				js_content metrics.report;
+			}
+		}`
+
+// exporterNginxConf proxies the requests of a worker pod to osrm-routed, which
+// listens on another port while the exporter is injected.
+var exporterNginxConf = fmt.Sprintf(`
+	load_module /usr/lib/nginx/modules/ngx_http_js_module.so;
+	events {
+
+	}
+	http {
+		large_client_header_buffers 4 128k;
+		js_path /etc/nginx;%s
+		server {
+			listen %d;
+			set $osrm_profile ${OSRM_PROFILE};
+			access_log /dev/null osrm_metrics;
+			location / {
+				proxy_pass http://127.0.0.1:%d;
+			}
+		}%s
+	}
+	`,
+	fmt.Sprintf(nginxMetricsConfig, nginxMetricsScriptName),
+	osrmPort,
+	monitoredRoutedPort,
+	fmt.Sprintf(nginxMetricsServer, MetricsPort, MetricsPath),
+)
+
+// routedPort returns the port osrm-routed listens on. With monitoring, the
+// exporter takes over the port of osrm-routed, so Services and the gateway
+// send their requests through it.
+func routedPort(instance *osrmv1alpha1.OSRMCluster) int32 {
+	if instance.Spec.Monitoring != nil {
+		return monitoredRoutedPort
+	}
+	return osrmPort
+}
+
+// routedArgs returns the arguments of osrm-routed set by the operator and the profile.
+func routedArgs(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec) []string {
+	args := profile.Routed.GetArgs()
+	if instance.Spec.Monitoring != nil {
+		args = append([]string{"--port", fmt.Sprint(monitoredRoutedPort)}, args...)
+	}
+	return args
+}
+
+// setExporter injects the metrics exporter sidecar into the pod template of osrm-routed workers.
+func setExporter(template *corev1.PodTemplateSpec, instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec) {
+	monitoring := instance.Spec.Monitoring
+	if monitoring == nil {
+		return
+	}
+
+	// The exporter is ready once the requests it proxies are answered, or with the
+	// probes of the profile disabled, once it listens on the port of osrm-routed.
+	readinessProbe := &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(osrmPort)},
+		},
+		PeriodSeconds:    10,
+		TimeoutSeconds:   5,
+		FailureThreshold: 3,
+	}
+	if instance.Spec.HasProbes(profile) {
+		readinessProbe.ProbeHandler = nearestRequestProbe(instance, profile, osrmPort)
+	}
+
+	setMetricsLabel(template, instance)
+	template.Spec.Containers = append(template.Spec.Containers, corev1.Container{
+		Name:  exporterContainerName,
+		Image: monitoring.GetExporterImage(gatewayImage),
+		Ports: []corev1.ContainerPort{
+			{
+				ContainerPort: osrmPort,
+			},
+			{
+				Name:          metricsPortName,
+				ContainerPort: MetricsPort,
+			},
+		},
+		Resources:      monitoring.GetExporterResources(),
+		ReadinessProbe: readinessProbe,
+		Env: []corev1.EnvVar{
+			{
+				Name:  "OSRM_PROFILE",
+				Value: profile.Name,
+			},
+		},
+		Command: []string{
+			"/bin/sh",
+			"-c",
+		},
+		Args: []string{`
+				envsubst '${OSRM_PROFILE}' < /etc/nginx/nginx.tmpl > /etc/nginx.conf &&
+				nginx -g 'daemon off;' -c /etc/nginx.conf
+			`,
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      exporterConfigVolumeName,
+				MountPath: "/etc/nginx",
+			},
+		},
+	})
+	template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+		Name: exporterConfigVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: instance.ChildResourceName(GatewaySuffix, ExporterSuffix),
+				},
+			},
+		},
+	})
+}
+
+// setMetricsLabel marks the pods whose exporter is scraped by the monitor of the OSRMCluster.
+// The labels are copied, as the pod template often shares them with the selector.
+func setMetricsLabel(template *corev1.PodTemplateSpec, instance *osrmv1alpha1.OSRMCluster) {
+	labels := map[string]string{}
+	for label, value := range template.ObjectMeta.Labels {
+		labels[label] = value
+	}
+	labels[metadata.MetricsLabelKey] = instance.Name
+	template.ObjectMeta.Labels = labels
+}
+
+// ExporterConfigMapBuilder builds the configuration of the exporter sidecar of the workers.
+type ExporterConfigMapBuilder struct {
+	ClusterScopedBuilder
+	*OSRMResourceBuilder
+}
+
+// MetricsServiceBuilder builds the headless Service a ServiceMonitor discovers the exporters through.
+type MetricsServiceBuilder struct {
+	ClusterScopedBuilder
+	*OSRMResourceBuilder
+}
+
+func (builder *OSRMResourceBuilder) ExporterConfigMap(profiles []*osrmv1alpha1.ProfileSpec) *ExporterConfigMapBuilder {
+	return &ExporterConfigMapBuilder{
+		ClusterScopedBuilder{profiles},
+		builder,
+	}
+}
+
+func (builder *OSRMResourceBuilder) MetricsService(profiles []*osrmv1alpha1.ProfileSpec) *MetricsServiceBuilder {
+	return &MetricsServiceBuilder{
+		ClusterScopedBuilder{profiles},
+		builder,
+	}
+}
+
+func (builder *ExporterConfigMapBuilder) Build() (client.Object, error) {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(GatewaySuffix, ExporterSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile),
+		},
+	}, nil
+}
+
+func (builder *ExporterConfigMapBuilder) Update(object client.Object, siblings *ChildResources) error {
+	configMap := object.(*corev1.ConfigMap)
+	configMap.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile)
+	configMap.Data = map[string]string{
+		nginxConfigurationTemplateName: exporterNginxConf,
+		nginxMetricsScriptName:         nginxMetricsScript,
+	}
+
+	if err := controllerutil.SetControllerReference(builder.Instance, configMap, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *ExporterConfigMapBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.Instance.Spec.Monitoring != nil
+}
+
+func (builder *MetricsServiceBuilder) Build() (client.Object, error) {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(GatewaySuffix, MetricsServiceSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    builder.metricsServiceLabels(),
+		},
+	}, nil
+}
+
+func (builder *MetricsServiceBuilder) Update(object client.Object, siblings *ChildResources) error {
+	service := object.(*corev1.Service)
+	service.ObjectMeta.Labels = builder.metricsServiceLabels()
+	service.Spec.ClusterIP = corev1.ClusterIPNone
+	service.Spec.Ports = []corev1.ServicePort{
+		{
+			Name:       metricsPortName,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       MetricsPort,
+			TargetPort: intstr.FromString(metricsPortName),
+		},
+	}
+	service.Spec.Selector = map[string]string{
+		metadata.MetricsLabelKey: builder.Instance.Name,
+	}
+
+	if err := controllerutil.SetControllerReference(builder.Instance, service, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *MetricsServiceBuilder) metricsServiceLabels() map[string]string {
+	labels := metadata.GetLabels(builder.Instance, metadata.ComponentLabelGateway)
+	labels[metadata.MetricsLabelKey] = builder.Instance.Name
+	return labels
+}
+
+func (builder *MetricsServiceBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.Instance.Spec.Monitoring != nil &&
+		builder.Instance.Spec.Monitoring.GetKind() == osrmv1alpha1.MonitorKindServiceMonitor
+}
+
+// Monitor returns an empty Prometheus Operator monitor of the OSRMCluster.
+func (builder *OSRMResourceBuilder) Monitor(kind osrmv1alpha1.MonitorKind) *unstructured.Unstructured {
+	monitor := &unstructured.Unstructured{}
+	monitor.SetGroupVersionKind(MonitorGVK(kind))
+	monitor.SetName(builder.Instance.Name)
+	monitor.SetNamespace(builder.Instance.Namespace)
+	return monitor
+}
+
+// UpdateMonitor sets the spec of a monitor that scrapes the exporters of the workers and the gateway.
+func (builder *OSRMResourceBuilder) UpdateMonitor(monitor *unstructured.Unstructured) error {
+	monitoring := builder.Instance.Spec.Monitoring
+
+	labels := metadata.GetLabels(builder.Instance, metadata.ComponentLabelGateway)
+	for label, value := range monitoring.Labels {
+		labels[label] = value
+	}
+	monitor.SetLabels(labels)
+
+	endpoint := map[string]interface{}{
+		"port": metricsPortName,
+		"path": MetricsPath,
+	}
+	if monitoring.Interval != nil {
+		endpoint["interval"] = *monitoring.Interval
+	}
+	endpointsField := "podMetricsEndpoints"
+	if monitor.GetKind() == string(osrmv1alpha1.MonitorKindServiceMonitor) {
+		endpointsField = "endpoints"
+	}
+	monitor.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				metadata.MetricsLabelKey: builder.Instance.Name,
+			},
+		},
+		endpointsField: []interface{}{endpoint},
+	}
+
+	if err := controllerutil.SetControllerReference(builder.Instance, monitor, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package resource_test
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("Monitoring", func() {
+	var builder *resource.OSRMResourceBuilder
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+		builder = &resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}
+
+		interval := "30s"
+		instance.Spec.Monitoring = &osrmv1alpha1.MonitoringSpec{
+			Interval: &interval,
+			Labels:   map[string]string{"release": "prometheus"},
+		}
+	})
+
+	AfterEach(func() {
+		instance.Spec.Monitoring = nil
+	})
+
+	It("Should inject the exporter in front of osrm-routed", func() {
+		profile := instance.Spec.Profiles[0]
+		resources := generateChildResources(true, true, instance.Name, profile.Name)
+		obj, err := builder.Deployment(profile).Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builder.Deployment(profile).Update(obj, newChildResources(resources))).To(Succeed())
+		deployment := obj.(*appsv1.Deployment)
+
+		containers := deployment.Spec.Template.Spec.Containers
+		Expect(containers).To(HaveLen(2))
+		Expect(containers[0].Args[0]).To(ContainSubstring("--port 5001"))
+		Expect(containers[0].Ports[0].ContainerPort).To(Equal(int32(5001)))
+		Expect(containers[0].ReadinessProbe.HTTPGet.Port.IntVal).To(Equal(int32(5001)))
+		Expect(containers[1].Ports[0].ContainerPort).To(Equal(int32(5000)))
+		Expect(containers[1].ReadinessProbe.HTTPGet.Port.IntVal).To(Equal(int32(5000)))
+		Expect(containers[1].Ports[1].ContainerPort).To(Equal(int32(resource.MetricsPort)))
+		Expect(containers[1].Env).To(ContainElement(corev1.EnvVar{Name: "OSRM_PROFILE", Value: profile.Name}))
+
+		Expect(deployment.Spec.Template.Labels).To(HaveKeyWithValue(metadata.MetricsLabelKey, instance.Name))
+		Expect(deployment.Spec.Selector.MatchLabels).NotTo(HaveKey(metadata.MetricsLabelKey))
+	})
+
+	It("Should check that the exporter listens without probes", func() {
+		disabled := true
+		instance.Spec.Probes = &osrmv1alpha1.ProbesSpec{Disabled: &disabled}
+		DeferCleanup(func() { instance.Spec.Probes = nil })
+		profile := instance.Spec.Profiles[0]
+		resources := generateChildResources(true, true, instance.Name, profile.Name)
+		obj, err := builder.Deployment(profile).Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builder.Deployment(profile).Update(obj, newChildResources(resources))).To(Succeed())
+
+		containers := obj.(*appsv1.Deployment).Spec.Template.Spec.Containers
+		Expect(containers[0].ReadinessProbe).To(BeNil())
+		Expect(containers[1].ReadinessProbe.TCPSocket.Port.IntVal).To(Equal(int32(5000)))
+	})
+
+	It("Should leave the workers untouched without monitoring", func() {
+		instance.Spec.Monitoring = nil
+		profile := instance.Spec.Profiles[0]
+		resources := generateChildResources(true, true, instance.Name, profile.Name)
+		obj, err := builder.Deployment(profile).Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builder.Deployment(profile).Update(obj, newChildResources(resources))).To(Succeed())
+		deployment := obj.(*appsv1.Deployment)
+
+		Expect(deployment.Spec.Template.Spec.Containers).To(HaveLen(1))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Args[0]).NotTo(ContainSubstring("--port"))
+		Expect(deployment.Spec.Template.Labels).NotTo(HaveKey(metadata.MetricsLabelKey))
+		Expect(builder.ExporterConfigMap(instance.Spec.Profiles).ShouldDeploy(newChildResources(resources))).To(BeFalse())
+	})
+
+	It("Should configure the exporter to record the requests of the profile", func() {
+		obj, err := builder.ExporterConfigMap(instance.Spec.Profiles).Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builder.ExporterConfigMap(instance.Spec.Profiles).Update(obj, nil)).To(Succeed())
+		configMap := obj.(*corev1.ConfigMap)
+
+		Expect(configMap.Name).To(Equal(fmt.Sprintf("%s-%s", instance.Name, resource.ExporterSuffix)))
+		Expect(configMap.Data["nginx.tmpl"]).To(ContainSubstring("set $osrm_profile ${OSRM_PROFILE};"))
+		Expect(configMap.Data["nginx.tmpl"]).To(ContainSubstring("proxy_pass http://127.0.0.1:5001;"))
+		Expect(configMap.Data["osrm_metrics.js"]).To(ContainSubstring("osrm_request_duration_seconds_bucket"))
+	})
+
+	It("Should record the requests of the gateway", func() {
+		obj, err := builder.ConfigMap(instance.Spec.Profiles).Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builder.ConfigMap(instance.Spec.Profiles).Update(obj, nil)).To(Succeed())
+		nginxConf := obj.(*corev1.ConfigMap).Data["nginx.tmpl"]
+
+		Expect(nginxConf).To(ContainSubstring("js_import metrics from osrm_metrics.js;"))
+		Expect(nginxConf).To(ContainSubstring("access_log /dev/null osrm_metrics;"))
+		Expect(nginxConf).To(ContainSubstring(fmt.Sprintf("set $osrm_profile %s;", instance.Spec.Profiles[0].Name)))
+		Expect(nginxConf).To(ContainSubstring(fmt.Sprintf("listen %d;", resource.MetricsPort)))
+
+		obj, err = builder.GatewayDeployment(instance.Spec.Profiles).Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builder.GatewayDeployment(instance.Spec.Profiles).Update(obj, nil)).To(Succeed())
+		deployment := obj.(*appsv1.Deployment)
+		Expect(deployment.Spec.Template.Labels).To(HaveKeyWithValue(metadata.MetricsLabelKey, instance.Name))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Ports).To(ContainElement(corev1.ContainerPort{
+			Name:          "metrics",
+			ContainerPort: resource.MetricsPort,
+		}))
+	})
+
+	It("Should scrape the pods with a PodMonitor by default", func() {
+		monitor := builder.Monitor(osrmv1alpha1.MonitorKindPodMonitor)
+		Expect(builder.UpdateMonitor(monitor)).To(Succeed())
+
+		Expect(monitor.GetAPIVersion()).To(Equal("monitoring.coreos.com/v1"))
+		Expect(monitor.GetKind()).To(Equal("PodMonitor"))
+		Expect(monitor.GetLabels()).To(HaveKeyWithValue("release", "prometheus"))
+		Expect(monitor.GetOwnerReferences()).To(HaveLen(1))
+
+		selector, _, err := unstructured.NestedStringMap(monitor.Object, "spec", "selector", "matchLabels")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selector).To(Equal(map[string]string{metadata.MetricsLabelKey: instance.Name}))
+		endpoints, _, err := unstructured.NestedSlice(monitor.Object, "spec", "podMetricsEndpoints")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(endpoints).To(Equal([]interface{}{map[string]interface{}{
+			"port":     "metrics",
+			"path":     "/metrics",
+			"interval": "30s",
+		}}))
+		Expect(builder.MetricsService(instance.Spec.Profiles).ShouldDeploy(nil)).To(BeFalse())
+	})
+
+	It("Should scrape the metrics Service with a ServiceMonitor", func() {
+		kind := osrmv1alpha1.MonitorKindServiceMonitor
+		instance.Spec.Monitoring.Kind = &kind
+		monitor := builder.Monitor(kind)
+		Expect(builder.UpdateMonitor(monitor)).To(Succeed())
+
+		endpoints, found, err := unstructured.NestedSlice(monitor.Object, "spec", "endpoints")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(endpoints).To(HaveLen(1))
+
+		Expect(builder.MetricsService(instance.Spec.Profiles).ShouldDeploy(nil)).To(BeTrue())
+		obj, err := builder.MetricsService(instance.Spec.Profiles).Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builder.MetricsService(instance.Spec.Profiles).Update(obj, nil)).To(Succeed())
+		service := obj.(*corev1.Service)
+		Expect(service.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+		Expect(service.Labels).To(HaveKeyWithValue(metadata.MetricsLabelKey, instance.Name))
+		Expect(service.Spec.Selector).To(Equal(map[string]string{metadata.MetricsLabelKey: instance.Name}))
+	})
+})
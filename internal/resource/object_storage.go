@@ -0,0 +1,131 @@
+package resource
+
+import (
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const objectStorageManifestName = "manifest.json"
+const objectStorageChecksumsName = "SHA256SUMS"
+
+// uploadScript uploads the customized map data of a profile along with a
+// checksums file and a manifest. The manifest is uploaded last so its presence
+// marks a complete upload.
+const uploadScript = `
+set -e
+cd "$ROOT_DIR/$CUSTOMIZED_DATA_DIR"
+sha256sum $OSRM_FILE_NAME* > /tmp/$CHECKSUMS_NAME
+
+{
+	echo "{"
+	echo "  \"profile\": \"$PROFILE\","
+	echo "  \"osrmFileName\": \"$OSRM_FILE_NAME\","
+	echo "  \"createdAt\": \"$(date -u +%Y-%m-%dT%H:%M:%SZ)\","
+	echo "  \"files\": ["
+	SEPARATOR=""
+	while read -r SUM FILE; do
+		echo "    $SEPARATOR{\"name\": \"$FILE\", \"size\": $(stat -c %s "$FILE"), \"sha256\": \"$SUM\"}"
+		SEPARATOR=","
+	done < /tmp/$CHECKSUMS_NAME
+	echo "  ]"
+	echo "}"
+} > /tmp/$MANIFEST_NAME
+
+aws s3 rm "$OBJECT_STORAGE_URL/$MANIFEST_NAME" || true
+aws s3 sync . "$OBJECT_STORAGE_URL/" --delete --exclude "*" --include "$OSRM_FILE_NAME*"
+aws s3 cp /tmp/$CHECKSUMS_NAME "$OBJECT_STORAGE_URL/$CHECKSUMS_NAME"
+aws s3 cp /tmp/$MANIFEST_NAME "$OBJECT_STORAGE_URL/$MANIFEST_NAME"
+`
+
+// hydrateScript copies the map data of a profile from the bucket onto the
+// worker's local volume and verifies it against the uploaded checksums.
+const hydrateScript = `
+set -e
+mkdir -p "$ROOT_DIR/$CUSTOMIZED_DATA_DIR"
+cd "$ROOT_DIR/$CUSTOMIZED_DATA_DIR"
+aws s3 cp "$OBJECT_STORAGE_URL/$MANIFEST_NAME" $MANIFEST_NAME
+aws s3 cp "$OBJECT_STORAGE_URL/$CHECKSUMS_NAME" $CHECKSUMS_NAME
+aws s3 sync "$OBJECT_STORAGE_URL/" . --exclude "$MANIFEST_NAME" --exclude "$CHECKSUMS_NAME"
+sha256sum -c $CHECKSUMS_NAME
+`
+
+func objectStorageContainer(
+	objectStorage *osrmv1alpha1.ObjectStorageSpec,
+	instance *osrmv1alpha1.OSRMCluster,
+	profile *osrmv1alpha1.ProfileSpec,
+	name string,
+	script string,
+	readOnly bool,
+) corev1.Container {
+	env := []corev1.EnvVar{
+		{
+			Name:  "ROOT_DIR",
+			Value: osrmDataPath,
+		},
+		{
+			Name:  "CUSTOMIZED_DATA_DIR",
+			Value: osrmCustomizedData,
+		},
+		{
+			Name:  "OSRM_FILE_NAME",
+			Value: instance.Spec.GetOsrmFileName(),
+		},
+		{
+			Name:  "PROFILE",
+			Value: profile.GetProfile(),
+		},
+		{
+			Name:  "OBJECT_STORAGE_URL",
+			Value: objectStorage.GetProfileURL(instance.Name, profile.Name),
+		},
+		{
+			Name:  "MANIFEST_NAME",
+			Value: objectStorageManifestName,
+		},
+		{
+			Name:  "CHECKSUMS_NAME",
+			Value: objectStorageChecksumsName,
+		},
+	}
+
+	if objectStorage.Endpoint != nil {
+		env = append(env, corev1.EnvVar{
+			Name:  "AWS_ENDPOINT_URL",
+			Value: *objectStorage.Endpoint,
+		})
+	}
+
+	if objectStorage.Region != nil {
+		env = append(env, corev1.EnvVar{
+			Name:  "AWS_DEFAULT_REGION",
+			Value: *objectStorage.Region,
+		})
+	}
+
+	var envFrom []corev1.EnvFromSource
+	if objectStorage.CredentialsSecretName != nil {
+		envFrom = append(envFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: *objectStorage.CredentialsSecretName,
+				},
+			},
+		})
+	}
+
+	return corev1.Container{
+		Name:    name,
+		Image:   objectStorage.GetImage(),
+		Command: []string{"/bin/bash", "-c"},
+		Args:    []string{script},
+		Env:     env,
+		EnvFrom: envFrom,
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      osrmDataVolumeName,
+				MountPath: osrmDataPath,
+				ReadOnly:  readOnly,
+			},
+		},
+	}
+}
@@ -1,7 +1,13 @@
 package resource
 
 import (
+	"fmt"
+	"time"
+
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -10,8 +16,8 @@ type OSRMService string
 
 type ResourceBuilder interface {
 	Build() (client.Object, error)
-	Update(client.Object, []runtime.Object) error
-	ShouldDeploy(resources []runtime.Object) bool
+	Update(client.Object, *ChildResources) error
+	ShouldDeploy(resources *ChildResources) bool
 }
 
 type OSRMResourceBuilder struct {
@@ -29,25 +35,252 @@ type ClusterScopedBuilder struct {
 
 func (builder *OSRMResourceBuilder) ResourceBuilders() []ResourceBuilder {
 	builders := []ResourceBuilder{}
+	if builder.Instance.Spec.MapBuilder.SharedDownload != nil {
+		builders = append(builders, []ResourceBuilder{
+			builder.PBFPersistentVolumeClaim(builder.Instance.Spec.Profiles),
+			builder.DownloadJob(builder.Instance.Spec.Profiles),
+		}...)
+	}
+
+	builders = append(builders, builder.ExporterConfigMap(builder.Instance.Spec.Profiles))
+
 	for _, profile := range builder.Instance.Spec.Profiles {
 		builders = append(builders, []ResourceBuilder{
 			builder.PersistentVolumeClaim(profile),
 			builder.Job(profile),
+			builder.StageJob(profile, osrmv1alpha1.MapBuilderStageExtract),
+			builder.StageJob(profile, osrmv1alpha1.MapBuilderStagePartition),
+			builder.StageJob(profile, osrmv1alpha1.MapBuilderStageCustomize),
+			builder.ImportJob(profile),
+			builder.CanaryJob(profile),
+			builder.CanaryCleanupJob(profile),
 			builder.Deployment(profile),
 			builder.Service(profile),
+			builder.CanaryDeployment(profile),
+			builder.CanaryService(profile),
+			builder.GreenDeployment(profile),
+			builder.GreenService(profile),
 			builder.CronJob(profile),
+			builder.SpeedUpdatesJob(profile),
+			builder.UploadJob(profile),
 			builder.PodDisruptionBudget(profile),
 			builder.HorizontalPodAutoscaler(profile),
 		}...)
 	}
 
-	if len(builders) > 0 {
+	if len(builder.Instance.Spec.Profiles) > 0 {
 		builders = append(builders, []ResourceBuilder{
 			builder.ConfigMap(builder.Instance.Spec.Profiles),
 			builder.GatewayService(builder.Instance.Spec.Profiles),
 			builder.GatewayDeployment(builder.Instance.Spec.Profiles),
+			builder.MetricsService(builder.Instance.Spec.Profiles),
 		}...)
 	}
 
 	return builders
 }
+
+// isProfileDataReady returns true once the map data of a profile can be served by workers.
+func (builder *OSRMResourceBuilder) isProfileDataReady(profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) bool {
+	if !builder.Instance.Spec.HasMapDataVolume() {
+		return true
+	}
+
+	profileResources := resources.Of(ProfileKey(profile.Name))
+	ready := status.IsPersistentVolumeClaimBound(
+		builder.Instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix),
+		profileResources,
+	) &&
+		builder.isMapDataBuilt(profile, resources)
+
+	if objectStorage := builder.Instance.Spec.ObjectStorage; objectStorage != nil && objectStorage.Hydrate {
+		ready = ready && status.IsJobCompleted(
+			builder.Instance.ChildResourceName(profile.Name, UploadJobSuffix),
+			profileResources,
+		)
+	}
+
+	return ready
+}
+
+// MapDataJobSuffixes returns the suffixes of the Jobs that populate a profile's
+// map data volume, in the order they run: the import Job for prebuilt map data,
+// a Job per stage for a split map build, the map builder Job otherwise.
+func MapDataJobSuffixes(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec) []string {
+	if instance.Spec.IsPrebuilt() {
+		return []string{ImportJobSuffix}
+	}
+	if instance.Spec.SplitsMapBuild(profile) {
+		suffixes := []string{}
+		for _, stage := range osrmv1alpha1.MapBuilderStages {
+			suffixes = append(suffixes, MapBuilderStageJobSuffix(stage))
+		}
+		return suffixes
+	}
+	return []string{JobSuffix}
+}
+
+// MapDataJobSuffix returns the suffix of the last Job that populates a profile's map data volume.
+func MapDataJobSuffix(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec) string {
+	suffixes := MapDataJobSuffixes(instance, profile)
+	return suffixes[len(suffixes)-1]
+}
+
+// AllMapDataJobSuffixes returns the suffixes of every Job that can populate a map data volume.
+func AllMapDataJobSuffixes() []string {
+	suffixes := []string{JobSuffix, ImportJobSuffix}
+	for _, stage := range osrmv1alpha1.MapBuilderStages {
+		suffixes = append(suffixes, MapBuilderStageJobSuffix(stage))
+	}
+	return suffixes
+}
+
+func MapBuilderStageJobSuffix(stage osrmv1alpha1.MapBuilderStage) string {
+	return fmt.Sprintf("%s-%s", JobSuffix, stage)
+}
+
+// isMapDataBuilt returns true when the map data Job of a profile completed, or
+// when the profile adopted a retained volume or restored a snapshot that already holds map data.
+func (builder *OSRMResourceBuilder) isMapDataBuilt(profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) bool {
+	return status.IsJobCompleted(builder.mapDataJobName(profile), resources.Of(ProfileKey(profile.Name))) ||
+		builder.hasExistingMapData(profile, resources)
+}
+
+func (builder *OSRMResourceBuilder) hasExistingMapData(profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) bool {
+	pvc := status.GetPersistentVolumeClaim(
+		builder.Instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix),
+		resources.Of(ProfileKey(profile.Name)),
+	)
+	return pvc != nil &&
+		(pvc.Annotations[MapDataBuiltAnnotation] != "" ||
+			pvc.Annotations[RetainedMapDataAnnotation] != "" ||
+			pvc.Annotations[RestoredFromSnapshotAnnotation] != "")
+}
+
+// LastMapBuildTime returns the completion time of the map data Job of a profile,
+// as recorded on the map data volume when the Job no longer exists.
+func (builder *OSRMResourceBuilder) LastMapBuildTime(profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) *metav1.Time {
+	profileResources := resources.Of(ProfileKey(profile.Name))
+	if job := status.GetJob(builder.mapDataJobName(profile), profileResources); job != nil {
+		return job.Status.CompletionTime
+	}
+
+	pvc := status.GetPersistentVolumeClaim(
+		builder.Instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix),
+		profileResources,
+	)
+	if pvc == nil {
+		return nil
+	}
+	completionTime, err := time.Parse(time.RFC3339, pvc.Annotations[MapDataBuiltAnnotation])
+	if err != nil {
+		return nil
+	}
+	return &metav1.Time{Time: completionTime}
+}
+
+// LastTrafficUpdateTime returns the latest successful speed update of a profile,
+// either scheduled or on-demand.
+func (builder *OSRMResourceBuilder) LastTrafficUpdateTime(profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) *metav1.Time {
+	var lastTrafficUpdateTime *metav1.Time
+	profileResources := resources.Of(ProfileKey(profile.Name))
+	for _, resource := range profileResources {
+		if cron, ok := resource.(*batchv1.CronJob); ok {
+			if cron.ObjectMeta.Name == builder.Instance.ChildResourceName(profile.Name, CronJobSuffix) {
+				lastTrafficUpdateTime = latestTime(lastTrafficUpdateTime, cron.Status.LastSuccessfulTime)
+			}
+		}
+	}
+
+	if job := status.GetJob(builder.Instance.ChildResourceName(profile.Name, SpeedUpdatesJobSuffix), profileResources); job != nil {
+		lastTrafficUpdateTime = latestTime(lastTrafficUpdateTime, job.Status.CompletionTime)
+	}
+
+	// The on-demand speed update is recorded on the volume once its Job is deleted.
+	pvc := status.GetPersistentVolumeClaim(builder.Instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix), profileResources)
+	if pvc != nil {
+		if updateTime, err := time.Parse(time.RFC3339, pvc.Annotations[SpeedUpdatedAnnotation]); err == nil {
+			lastTrafficUpdateTime = latestTime(lastTrafficUpdateTime, &metav1.Time{Time: updateTime})
+		}
+	}
+
+	return lastTrafficUpdateTime
+}
+
+// MapDataOSRMVersion returns the OSRM release a profile's map data was built
+// with, or an empty string when there is no map data or it was imported from
+// an unknown release. Map data built before its release was recorded is
+// assumed to be of legacyOSRMVersion.
+func MapDataOSRMVersion(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) string {
+	profileResources := resources.Of(ProfileKey(profile.Name))
+	jobName := instance.ChildResourceName(profile.Name, MapDataJobSuffix(instance, profile))
+	if job := status.GetJob(jobName, profileResources); job != nil && status.IsJobCompleted(jobName, profileResources) {
+		if osrmVersion := job.Annotations[OSRMVersionAnnotation]; osrmVersion != "" {
+			return osrmVersion
+		}
+		if !instance.Spec.IsPrebuilt() {
+			return legacyOSRMVersion
+		}
+	}
+
+	pvc := status.GetPersistentVolumeClaim(
+		instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix),
+		profileResources,
+	)
+	if pvc == nil {
+		return ""
+	}
+	if osrmVersion := pvc.Annotations[OSRMVersionAnnotation]; osrmVersion != "" {
+		return osrmVersion
+	}
+	if !instance.Spec.IsPrebuilt() && (&OSRMResourceBuilder{Instance: instance}).hasExistingMapData(profile, resources) {
+		return legacyOSRMVersion
+	}
+	return ""
+}
+
+// IsMapDataOSRMVersionCompatible returns false when a profile's map data was built
+// with an OSRM release that spec.osrmVersion cannot serve.
+func IsMapDataOSRMVersionCompatible(instance *osrmv1alpha1.OSRMCluster, profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) bool {
+	osrmVersion := MapDataOSRMVersion(instance, profile, resources)
+	return osrmVersion == "" || osrmv1alpha1.IsOSRMVersionCompatible(osrmVersion, instance.Spec.GetOSRMVersion())
+}
+
+// servingOSRMVersion returns the OSRM release that serves a profile's map data:
+// spec.osrmVersion, or the release the map data was built with until it is
+// rebuilt. With the Canary policy, that release serves it until the upgrade is promoted.
+func (builder *OSRMResourceBuilder) servingOSRMVersion(profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) string {
+	osrmVersion := MapDataOSRMVersion(builder.Instance, profile, resources)
+	if osrmVersion == "" || osrmVersion == builder.Instance.Spec.GetOSRMVersion() {
+		return builder.Instance.Spec.GetOSRMVersion()
+	}
+	if builder.Instance.Spec.GetOSRMVersionUpgradePolicy() == osrmv1alpha1.OSRMVersionUpgradePolicyCanary ||
+		!osrmv1alpha1.IsOSRMVersionCompatible(osrmVersion, builder.Instance.Spec.GetOSRMVersion()) {
+		return osrmVersion
+	}
+	return builder.Instance.Spec.GetOSRMVersion()
+}
+
+// IsOutdatedOSRMVersion returns true when map data built with an OSRM release
+// is to be rebuilt with spec.osrmVersion: with the Rebuild policy when the
+// release cannot serve it, and with the Canary policy once the upgrade is promoted.
+func IsOutdatedOSRMVersion(instance *osrmv1alpha1.OSRMCluster, osrmVersion string) bool {
+	if osrmVersion == "" || instance.Spec.IsPrebuilt() {
+		return false
+	}
+	switch instance.Spec.GetOSRMVersionUpgradePolicy() {
+	case osrmv1alpha1.OSRMVersionUpgradePolicyRebuild:
+		return !osrmv1alpha1.IsOSRMVersionCompatible(osrmVersion, instance.Spec.GetOSRMVersion())
+	case osrmv1alpha1.OSRMVersionUpgradePolicyCanary:
+		upgrade := instance.Status.OSRMUpgrade
+		return upgrade != nil &&
+			upgrade.Phase == osrmv1alpha1.OSRMUpgradePhasePromoting &&
+			upgrade.Version == instance.Spec.GetOSRMVersion() &&
+			osrmVersion != upgrade.Version
+	}
+	return false
+}
+
+func (builder *OSRMResourceBuilder) mapDataJobName(profile *osrmv1alpha1.ProfileSpec) string {
+	return builder.Instance.ChildResourceName(profile.Name, MapDataJobSuffix(builder.Instance, profile))
+}
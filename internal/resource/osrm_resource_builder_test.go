@@ -0,0 +1,151 @@
+package resource_test
+
+import (
+	"fmt"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("ResourceBuilders", func() {
+	// The env, ports and volumes of a pod are maps keyed by name, whose
+	// duplicate keys fail the server-side apply of the child resource.
+	DescribeTable("Should build pods with unique env, port and volume names",
+		func(configure func(spec *osrmv1alpha1.OSRMClusterSpec)) {
+			spec := instance.Spec.DeepCopy()
+			DeferCleanup(func() { instance.Spec = *spec })
+			configure(&instance.Spec)
+
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder := &resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			resources[0].(*batchv1.Job).Status.CompletionTime = &metav1.Time{Time: time.Now()}
+
+			for _, resourceBuilder := range builder.ResourceBuilders() {
+				if !resourceBuilder.ShouldDeploy(newChildResources(resources)) {
+					continue
+				}
+				object, err := resourceBuilder.Build()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resourceBuilder.Update(object, newChildResources(resources))).To(Succeed())
+
+				var podSpec *corev1.PodSpec
+				switch object := object.(type) {
+				case *appsv1.Deployment:
+					podSpec = &object.Spec.Template.Spec
+				case *batchv1.Job:
+					podSpec = &object.Spec.Template.Spec
+				case *batchv1.CronJob:
+					podSpec = &object.Spec.JobTemplate.Spec.Template.Spec
+				default:
+					continue
+				}
+
+				Expect(uniqueNames(podSpec.Volumes, func(volume corev1.Volume) string { return volume.Name })).
+					To(Succeed(), "volumes of %s", object.GetName())
+				containers := append(append([]corev1.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+				Expect(uniqueNames(containers, func(container corev1.Container) string { return container.Name })).
+					To(Succeed(), "containers of %s", object.GetName())
+				for _, container := range containers {
+					Expect(uniqueNames(container.Env, func(env corev1.EnvVar) string { return env.Name })).
+						To(Succeed(), "env of %s in %s", container.Name, object.GetName())
+					Expect(uniqueNames(container.Ports, func(port corev1.ContainerPort) string { return port.Name })).
+						To(Succeed(), "ports of %s in %s", container.Name, object.GetName())
+					Expect(uniqueNames(container.VolumeMounts, func(mount corev1.VolumeMount) string { return mount.MountPath })).
+						To(Succeed(), "volume mounts of %s in %s", container.Name, object.GetName())
+				}
+			}
+		},
+		Entry("by default", func(spec *osrmv1alpha1.OSRMClusterSpec) {}),
+		Entry("with prebuilt map data in object storage", func(spec *osrmv1alpha1.OSRMClusterSpec) {
+			spec.MapSource = &osrmv1alpha1.MapSourceSpec{
+				Prebuilt: &osrmv1alpha1.PrebuiltMapSpec{
+					ObjectStorage: &osrmv1alpha1.ObjectStorageSpec{Bucket: "osrm"},
+					Checksums:     map[string]string{"car": "abc123"},
+				},
+			}
+		}),
+		Entry("with object storage upload and hydration", func(spec *osrmv1alpha1.OSRMClusterSpec) {
+			spec.ObjectStorage = &osrmv1alpha1.ObjectStorageSpec{Bucket: "osrm", Upload: true, Hydrate: true}
+		}),
+		Entry("with local worker storage", func(spec *osrmv1alpha1.OSRMClusterSpec) {
+			storage := k8sresource.MustParse("20Gi")
+			spec.Persistence.WorkerStorage = &osrmv1alpha1.WorkerStorageSpec{
+				Mode:             osrmv1alpha1.WorkerStorageModeLocal,
+				StorageClassName: "local-path",
+				Storage:          &storage,
+			}
+		}),
+		Entry("with speed updates and monitoring", func(spec *osrmv1alpha1.OSRMClusterSpec) {
+			spec.Profiles[0].SpeedUpdates = &osrmv1alpha1.SpeedUpdatesSpec{Schedule: "30 * * * *"}
+			spec.Monitoring = &osrmv1alpha1.MonitoringSpec{}
+		}),
+	)
+})
+
+var _ = Describe("MapDataOSRMVersion", func() {
+	BeforeEach(func() {
+		osrmVersion := "v6.0.0"
+		instance.Spec.OSRMVersion = &osrmVersion
+		DeferCleanup(func() { instance.Spec.OSRMVersion = nil })
+	})
+
+	It("Should assume the legacy release for map data built before its release was recorded", func() {
+		resources := generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name)
+		resources[1].(*corev1.PersistentVolumeClaim).Annotations = map[string]string{
+			resource.MapDataBuiltAnnotation: "2024-01-01T00:00:00Z",
+		}
+
+		Expect(resource.MapDataOSRMVersion(instance, instance.Spec.Profiles[0], newChildResources(resources))).To(Equal("v5.27.1"))
+		Expect(resource.IsMapDataOSRMVersionCompatible(instance, instance.Spec.Profiles[0], newChildResources(resources))).To(BeFalse())
+		Expect(resource.IsOutdatedOSRMVersion(instance, "v5.27.1")).To(BeTrue())
+
+		scheme := runtime.NewScheme()
+		Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+		builder := (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).Deployment(instance.Spec.Profiles[0])
+		deployment, err := builder.Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(builder.Update(deployment, newChildResources(resources))).To(Succeed())
+		Expect(deployment.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image).To(Equal("ghcr.io/project-osrm/osrm-backend:v5.27.1"))
+	})
+
+	It("Should assume the legacy release for a completed map builder Job without a recorded release", func() {
+		resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+		Expect(resource.MapDataOSRMVersion(instance, instance.Spec.Profiles[0], newChildResources(resources))).To(Equal("v5.27.1"))
+	})
+
+	It("Should return an empty string when there is no map data", func() {
+		resources := generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name)
+		Expect(resource.MapDataOSRMVersion(instance, instance.Spec.Profiles[0], newChildResources(resources))).To(BeEmpty())
+		Expect(resource.IsMapDataOSRMVersionCompatible(instance, instance.Spec.Profiles[0], newChildResources(resources))).To(BeTrue())
+	})
+})
+
+// uniqueNames fails when two items have the same non-empty name.
+func uniqueNames[T any](items []T, name func(T) string) error {
+	seen := map[string]bool{}
+	for _, item := range items {
+		key := name(item)
+		if key == "" {
+			continue
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate name %q", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
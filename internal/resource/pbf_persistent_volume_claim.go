@@ -0,0 +1,69 @@
+package resource
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// PBFPersistentVolumeClaimBuilder builds the volume that caches the PBF file
+// shared by all profiles' map builder Jobs.
+type PBFPersistentVolumeClaimBuilder struct {
+	ClusterScopedBuilder
+	*OSRMResourceBuilder
+}
+
+func (builder *OSRMResourceBuilder) PBFPersistentVolumeClaim(profiles []*osrmv1alpha1.ProfileSpec) *PBFPersistentVolumeClaimBuilder {
+	return &PBFPersistentVolumeClaimBuilder{
+		ClusterScopedBuilder{profiles},
+		builder,
+	}
+}
+
+func (builder *PBFPersistentVolumeClaimBuilder) Build() (client.Object, error) {
+	storage := builder.Instance.Spec.Persistence.Storage
+	if builder.Instance.Spec.MapBuilder.SharedDownload.Storage != nil {
+		storage = builder.Instance.Spec.MapBuilder.SharedDownload.Storage
+	}
+
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(GatewaySuffix, PBFPersistentVolumeClaimSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelDownload),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{
+				builder.Instance.Spec.Persistence.GetAccessMode(),
+			},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: map[corev1.ResourceName]resource.Quantity{
+					corev1.ResourceStorage: *storage,
+				},
+			},
+			StorageClassName: &builder.Instance.Spec.Persistence.StorageClassName,
+		},
+	}, nil
+}
+
+func (builder *PBFPersistentVolumeClaimBuilder) Update(object client.Object, siblings *ChildResources) error {
+	pvc := object.(*corev1.PersistentVolumeClaim)
+
+	pvc.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelDownload)
+
+	if err := controllerutil.SetControllerReference(builder.Instance, pvc, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *PBFPersistentVolumeClaimBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.Instance.Spec.MapBuilder.SharedDownload != nil && builder.Instance.Spec.HasMapDataVolume() && !builder.Instance.Spec.IsPrebuilt()
+}
@@ -2,13 +2,15 @@ package resource
 
 import (
 	"fmt"
+	"time"
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -27,11 +29,12 @@ func (builder *OSRMResourceBuilder) PersistentVolumeClaim(profile *osrmv1alpha1.
 
 func (builder *PersistentVolumeClaimBuilder) Build() (client.Object, error) {
 	name := builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix)
-	return &corev1.PersistentVolumeClaim{
+	storageClassName := builder.Instance.Spec.GetStorageClassName(builder.profile)
+	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: builder.Instance.Namespace,
-			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile),
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{
@@ -39,19 +42,72 @@ func (builder *PersistentVolumeClaimBuilder) Build() (client.Object, error) {
 			},
 			Resources: corev1.VolumeResourceRequirements{
 				Requests: map[corev1.ResourceName]resource.Quantity{
-					corev1.ResourceStorage: *builder.Instance.Spec.Persistence.Storage,
+					corev1.ResourceStorage: *builder.Instance.Spec.GetStorage(builder.profile),
 				},
 			},
 			VolumeName:       "",
-			StorageClassName: &builder.Instance.Spec.Persistence.StorageClassName,
+			StorageClassName: &storageClassName,
 		},
-	}, nil
+	}
+
+	// The data source only applies when the claim is created.
+	if snapshotName, ok := builder.Instance.Spec.Persistence.RestoreFrom[builder.profile.Name]; ok {
+		apiGroup := VolumeSnapshotAPIGroup
+		pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     VolumeSnapshotGVK.Kind,
+			Name:     snapshotName,
+		}
+		pvc.ObjectMeta.Annotations = map[string]string{
+			RestoredFromSnapshotAnnotation: snapshotName,
+		}
+	}
+
+	return pvc, nil
 }
 
-func (builder *PersistentVolumeClaimBuilder) Update(object client.Object, siblings []runtime.Object) error {
+func (builder *PersistentVolumeClaimBuilder) Update(object client.Object, siblings *ChildResources) error {
 	pvc := object.(*corev1.PersistentVolumeClaim)
 
-	pvc.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile)
+	pvc.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
+
+	profileSiblings := siblings.Of(ProfileKey(builder.profile.Name))
+	jobName := builder.mapDataJobName(builder.profile)
+	if job := status.GetJob(jobName, profileSiblings); job != nil &&
+		job.DeletionTimestamp == nil &&
+		!builder.isReplacedByRebuild(job) &&
+		!builder.isOutdated(job) &&
+		status.IsJobCompleted(jobName, profileSiblings) {
+		annotations := map[string]string{
+			MapDataBuiltAnnotation: job.Status.CompletionTime.Format(time.RFC3339),
+		}
+		if osrmVersion := job.Annotations[OSRMVersionAnnotation]; osrmVersion != "" {
+			annotations[OSRMVersionAnnotation] = osrmVersion
+		}
+		pvc.ObjectMeta.Annotations = metadata.ReconcileAnnotations(pvc.ObjectMeta.Annotations, annotations)
+	}
+
+	speedUpdatesJobName := builder.Instance.ChildResourceName(builder.profile.Name, SpeedUpdatesJobSuffix)
+	if job := status.GetJob(speedUpdatesJobName, profileSiblings); job != nil &&
+		job.DeletionTimestamp == nil &&
+		status.IsJobCompleted(speedUpdatesJobName, profileSiblings) {
+		pvc.ObjectMeta.Annotations = metadata.ReconcileAnnotations(pvc.ObjectMeta.Annotations, map[string]string{
+			SpeedUpdatedAnnotation:      job.Status.CompletionTime.Format(time.RFC3339),
+			SpeedUpdatesTokenAnnotation: job.Annotations[OnDemandTokenAnnotation],
+		})
+	}
+
+	// Existing claims can only grow, and only when their StorageClass allows it.
+	// Other changes are reported by the StorageSynced condition.
+	storage := builder.Instance.Spec.GetStorage(builder.profile)
+	if !pvc.CreationTimestamp.IsZero() &&
+		pvc.Spec.Resources.Requests.Storage().Cmp(*storage) < 0 &&
+		status.IsVolumeExpansionAllowed(pvc, siblings.StorageClasses()) {
+		if pvc.Spec.Resources.Requests == nil {
+			pvc.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = *storage
+	}
 
 	if err := controllerutil.SetControllerReference(builder.Instance, pvc, builder.Scheme); err != nil {
 		return fmt.Errorf("failed setting controller reference: %v", err)
@@ -60,6 +116,21 @@ func (builder *PersistentVolumeClaimBuilder) Update(object client.Object, siblin
 	return nil
 }
 
-func (*PersistentVolumeClaimBuilder) ShouldDeploy(resources []runtime.Object) bool {
-	return true
+// isReplacedByRebuild returns true for a map data Job that was deleted by the
+// latest rebuild request but may still be cached.
+func (builder *PersistentVolumeClaimBuilder) isReplacedByRebuild(job *batchv1.Job) bool {
+	request := builder.Instance.MapRebuildRequest()
+	return request != nil &&
+		request.Includes(builder.profile.Name) &&
+		job.Annotations[OnDemandTokenAnnotation] != builder.Instance.Status.MapRebuildToken
+}
+
+// isOutdated returns true for a map data Job that built the map data with an
+// outdated OSRM release, which is deleted and rebuilt but may still be cached.
+func (builder *PersistentVolumeClaimBuilder) isOutdated(job *batchv1.Job) bool {
+	return IsOutdatedOSRMVersion(builder.Instance, job.Annotations[OSRMVersionAnnotation])
+}
+
+func (builder *PersistentVolumeClaimBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.Instance.Spec.HasMapDataVolume()
 }
@@ -1,10 +1,19 @@
 package resource_test
 
 import (
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/resource"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
 )
 
 var _ = Describe("PersistentVolumeClaim builder", func() {
@@ -16,7 +25,157 @@ var _ = Describe("PersistentVolumeClaim builder", func() {
 
 		It("Should always return 'true'", func() {
 			resources := []runtime.Object{}
-			Expect(builder.ShouldDeploy(resources)).To(Equal(true))
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
+		})
+	})
+
+	Context("Build", func() {
+		It("Should use the profile's persistence overrides", func() {
+			storageClassName := "fast"
+			storage := k8sresource.MustParse("50Gi")
+			instance.Spec.Profiles[0].Persistence = &osrmv1alpha1.ProfilePersistenceSpec{
+				StorageClassName: &storageClassName,
+				Storage:          &storage,
+			}
+			defer func() { instance.Spec.Profiles[0].Persistence = nil }()
+
+			obj, err := osrmResourceBuilder.PersistentVolumeClaim(instance.Spec.Profiles[0]).Build()
+			Expect(err).NotTo(HaveOccurred())
+
+			pvc := obj.(*corev1.PersistentVolumeClaim)
+			Expect(*pvc.Spec.StorageClassName).To(Equal("fast"))
+			Expect(pvc.Spec.Resources.Requests.Storage().Equal(storage)).To(BeTrue())
+		})
+	})
+
+	Context("Restore", func() {
+		It("Should restore the claim from the profile's snapshot and skip the map build", func() {
+			instance.Spec.Persistence.RestoreFrom = map[string]string{"car": "test-car-snapshot-1704067200"}
+			defer func() { instance.Spec.Persistence.RestoreFrom = nil }()
+
+			obj, err := osrmResourceBuilder.PersistentVolumeClaim(instance.Spec.Profiles[0]).Build()
+			Expect(err).NotTo(HaveOccurred())
+
+			pvc := obj.(*corev1.PersistentVolumeClaim)
+			Expect(*pvc.Spec.DataSource.APIGroup).To(Equal(resource.VolumeSnapshotAPIGroup))
+			Expect(pvc.Spec.DataSource.Kind).To(Equal("VolumeSnapshot"))
+			Expect(pvc.Spec.DataSource.Name).To(Equal("test-car-snapshot-1704067200"))
+
+			Expect(osrmResourceBuilder.Job(instance.Spec.Profiles[0]).ShouldDeploy(newChildResources([]runtime.Object{pvc}))).To(Equal(false))
+		})
+	})
+
+	Context("Update", func() {
+		var builder resource.ResourceBuilder
+		var pvc *corev1.PersistentVolumeClaim
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+			Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+			builder = (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).PersistentVolumeClaim(instance.Spec.Profiles[0])
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			pvc = obj.(*corev1.PersistentVolumeClaim)
+			pvc.CreationTimestamp = metav1.Now()
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = k8sresource.MustParse("10Mi")
+		})
+
+		It("Should record a completed map build on the claim", func() {
+			completionTime := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			resources[0].(*batchv1.Job).Status.CompletionTime = &completionTime
+
+			Expect(builder.Update(pvc, newChildResources(resources))).To(Succeed())
+			Expect(pvc.Annotations).To(HaveKeyWithValue(resource.MapDataBuiltAnnotation, "2024-01-01T00:00:00Z"))
+		})
+
+		It("Should record the OSRM release the map data was built with", func() {
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			job := resources[0].(*batchv1.Job)
+			job.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+			job.Annotations = map[string]string{resource.OSRMVersionAnnotation: "v5.27.1"}
+
+			Expect(builder.Update(pvc, newChildResources(resources))).To(Succeed())
+			Expect(pvc.Annotations).To(HaveKeyWithValue(resource.OSRMVersionAnnotation, "v5.27.1"))
+		})
+
+		It("Should ignore a map builder Job that built the map data with an incompatible OSRM release", func() {
+			osrmVersion := "v6.0.0"
+			instance.Spec.OSRMVersion = &osrmVersion
+			defer func() { instance.Spec.OSRMVersion = nil }()
+
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			job := resources[0].(*batchv1.Job)
+			job.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+			job.Annotations = map[string]string{resource.OSRMVersionAnnotation: "v5.27.1"}
+
+			Expect(builder.Update(pvc, newChildResources(resources))).To(Succeed())
+			Expect(pvc.Annotations).NotTo(HaveKey(resource.MapDataBuiltAnnotation))
+		})
+
+		It("Should ignore a map builder Job that was replaced by a rebuild", func() {
+			instance.SetAnnotations(map[string]string{osrmv1alpha1.RebuildMapAnnotation: "second"})
+			instance.Status.MapRebuildToken = "second"
+			defer func() {
+				instance.SetAnnotations(nil)
+				instance.Status.MapRebuildToken = ""
+			}()
+
+			completionTime := metav1.Now()
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			resources[0].(*batchv1.Job).Status.CompletionTime = &completionTime
+
+			Expect(builder.Update(pvc, newChildResources(resources))).To(Succeed())
+			Expect(pvc.Annotations).NotTo(HaveKey(resource.MapDataBuiltAnnotation))
+		})
+
+		It("Should record a completed speed update on the claim", func() {
+			completionTime := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			resources := append(generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name), &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        instance.ChildResourceName(instance.Spec.Profiles[0].Name, resource.SpeedUpdatesJobSuffix),
+					Annotations: map[string]string{resource.OnDemandTokenAnnotation: "token"},
+				},
+				Status: batchv1.JobStatus{
+					CompletionTime: &completionTime,
+					Conditions: []batchv1.JobCondition{
+						{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+					},
+				},
+			})
+
+			Expect(builder.Update(pvc, newChildResources(resources))).To(Succeed())
+			Expect(pvc.Annotations).To(HaveKeyWithValue(resource.SpeedUpdatedAnnotation, "2024-01-01T00:00:00Z"))
+			Expect(pvc.Annotations).To(HaveKeyWithValue(resource.SpeedUpdatesTokenAnnotation, "token"))
+
+			// The update time is kept once the Job is deleted.
+			lastTrafficUpdateTime := osrmResourceBuilder.LastTrafficUpdateTime(instance.Spec.Profiles[0], newChildResources([]runtime.Object{pvc}))
+			Expect(lastTrafficUpdateTime.Equal(&completionTime)).To(BeTrue())
+		})
+
+		It("Should expand the claim when the StorageClass allows it", func() {
+			allowVolumeExpansion := true
+			siblings := []runtime.Object{
+				&storagev1.StorageClass{
+					ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+					AllowVolumeExpansion: &allowVolumeExpansion,
+				},
+			}
+
+			Expect(builder.Update(pvc, newChildResources(siblings))).To(Succeed())
+			Expect(pvc.Spec.Resources.Requests.Storage().Equal(*instance.Spec.Persistence.Storage)).To(BeTrue())
+		})
+
+		It("Should not expand the claim when the StorageClass does not allow it", func() {
+			siblings := []runtime.Object{
+				&storagev1.StorageClass{
+					ObjectMeta: metav1.ObjectMeta{Name: "standard"},
+				},
+			}
+
+			Expect(builder.Update(pvc, newChildResources(siblings))).To(Succeed())
+			Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("10Mi"))
 		})
 	})
 })
@@ -5,10 +5,8 @@ import (
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
-	"github.com/itayankri/OSRM-Operator/internal/status"
 	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -30,15 +28,15 @@ func (builder *PodDisruptionBudgetBuilder) Build() (client.Object, error) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      builder.Instance.ChildResourceName(builder.profile.Name, PodDisruptionBudgetSuffix),
 			Namespace: builder.Instance.Namespace,
-			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile),
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
 		},
 	}, nil
 }
 
-func (builder *PodDisruptionBudgetBuilder) Update(object client.Object, siblings []runtime.Object) error {
+func (builder *PodDisruptionBudgetBuilder) Update(object client.Object, siblings *ChildResources) error {
 	name := builder.Instance.ChildResourceName(builder.profile.Name, PodDisruptionBudgetSuffix)
 	pdb := object.(*policyv1.PodDisruptionBudget)
-	pdb.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile)
+	pdb.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
 	pdb.Spec.MinAvailable = builder.profile.GetMinAvailable()
 	pdb.Spec.Selector = &metav1.LabelSelector{
 		MatchLabels: map[string]string{
@@ -53,13 +51,6 @@ func (builder *PodDisruptionBudgetBuilder) Update(object client.Object, siblings
 	return nil
 }
 
-func (builder *PodDisruptionBudgetBuilder) ShouldDeploy(resources []runtime.Object) bool {
-	return status.IsPersistentVolumeClaimBound(
-		builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
-		resources,
-	) &&
-		status.IsJobCompleted(
-			builder.Instance.ChildResourceName(builder.profile.Name, JobSuffix),
-			resources,
-		)
+func (builder *PodDisruptionBudgetBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.isProfileDataReady(builder.profile, resources)
 }
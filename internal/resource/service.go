@@ -5,10 +5,8 @@ import (
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/internal/metadata"
-	"github.com/itayankri/OSRM-Operator/internal/status"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -31,18 +29,28 @@ func (builder *ServiceBuilder) Build() (client.Object, error) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      builder.Instance.ChildResourceName(builder.profile.Name, ServiceSuffix),
 			Namespace: builder.Instance.Namespace,
-			Labels:    metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile),
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
 		},
 	}, nil
 }
 
-func (builder *ServiceBuilder) Update(object client.Object, siblings []runtime.Object) error {
+func (builder *ServiceBuilder) Update(object client.Object, siblings *ChildResources) error {
 	name := builder.Instance.ChildResourceName(builder.profile.Name, ServiceSuffix)
 
 	service := object.(*corev1.Service)
 
-	service.ObjectMeta.Labels = metadata.GetLabels(builder.Instance, metadata.ComponentLabelProfile)
+	service.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
+	updateProfileService(service, name)
 
+	if err := controllerutil.SetControllerReference(builder.Instance, service, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+// updateProfileService exposes the osrm-routed pods of a Deployment.
+func updateProfileService(service *corev1.Service, name string) {
 	service.Spec.Type = corev1.ServiceTypeClusterIP
 	service.Spec.Ports = []corev1.ServicePort{
 		{
@@ -51,28 +59,15 @@ func (builder *ServiceBuilder) Update(object client.Object, siblings []runtime.O
 			Port:     80,
 			TargetPort: intstr.IntOrString{
 				Type:   intstr.Int,
-				IntVal: 5000,
+				IntVal: osrmPort,
 			},
 		},
 	}
 	service.Spec.Selector = map[string]string{
 		"app": name,
 	}
-
-	if err := controllerutil.SetControllerReference(builder.Instance, service, builder.Scheme); err != nil {
-		return fmt.Errorf("failed setting controller reference: %v", err)
-	}
-
-	return nil
 }
 
-func (builder *ServiceBuilder) ShouldDeploy(resources []runtime.Object) bool {
-	return status.IsPersistentVolumeClaimBound(
-		builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
-		resources,
-	) &&
-		status.IsJobCompleted(
-			builder.Instance.ChildResourceName(builder.profile.Name, JobSuffix),
-			resources,
-		)
+func (builder *ServiceBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.isProfileDataReady(builder.profile, resources)
 }
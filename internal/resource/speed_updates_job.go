@@ -0,0 +1,81 @@
+package resource
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// SpeedUpdatesJobBuilder builds the one-off speed updates Job requested
+// through the UpdateSpeedsAnnotation. It shares its spec with the CronJob.
+type SpeedUpdatesJobBuilder struct {
+	ProfileScopedBuilder
+	*OSRMResourceBuilder
+}
+
+func (builder *OSRMResourceBuilder) SpeedUpdatesJob(profile *osrmv1alpha1.ProfileSpec) *SpeedUpdatesJobBuilder {
+	return &SpeedUpdatesJobBuilder{
+		ProfileScopedBuilder{profile},
+		builder,
+	}
+}
+
+func (builder *SpeedUpdatesJobBuilder) Build() (client.Object, error) {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(builder.profile.Name, SpeedUpdatesJobSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
+		},
+	}, nil
+}
+
+func (builder *SpeedUpdatesJobBuilder) Update(object client.Object, siblings *ChildResources) error {
+	job := object.(*batchv1.Job)
+
+	job.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
+
+	// The pod template of a Job is immutable, so the spec is only set on creation.
+	// A new request is served by deleting this Job and creating it again.
+	if job.CreationTimestamp.IsZero() {
+		job.ObjectMeta.Annotations = metadata.ReconcileAnnotations(job.ObjectMeta.Annotations, map[string]string{
+			OnDemandTokenAnnotation: builder.Instance.SpeedUpdatesRequest().Token,
+		})
+		job.Spec = speedUpdatesJobSpec(builder.Instance, builder.profile, builder.servingOSRMVersion(builder.profile, siblings))
+	}
+
+	if err := controllerutil.SetControllerReference(builder.Instance, job, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *SpeedUpdatesJobBuilder) ShouldDeploy(resources *ChildResources) bool {
+	request := builder.Instance.SpeedUpdatesRequest()
+	return builder.profile.SpeedUpdates != nil &&
+		request != nil &&
+		request.Includes(builder.profile.Name) &&
+		!builder.isSpeedUpdated(request, resources) &&
+		status.IsPersistentVolumeClaimBound(
+			builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
+			resources.Of(ProfileKey(builder.profile.Name)),
+		) &&
+		builder.isMapDataBuilt(builder.profile, resources)
+}
+
+// isSpeedUpdated returns true once the Job of a request completed and was recorded
+// on the map data volume, after which the Job is deleted.
+func (builder *SpeedUpdatesJobBuilder) isSpeedUpdated(request *osrmv1alpha1.OnDemandRequest, resources *ChildResources) bool {
+	pvc := status.GetPersistentVolumeClaim(
+		builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
+		resources.Of(ProfileKey(builder.profile.Name)),
+	)
+	return pvc != nil && pvc.Annotations[SpeedUpdatesTokenAnnotation] == request.Token
+}
@@ -0,0 +1,65 @@
+package resource_test
+
+import (
+	"github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("SpeedUpdatesJob builder", func() {
+	Context("ShouldDeploy", func() {
+		var builder resource.ResourceBuilder
+		BeforeEach(func() {
+			builder = osrmResourceBuilder.SpeedUpdatesJob(instance.Spec.Profiles[0])
+			instance.Spec.Profiles[0].SpeedUpdates = &v1alpha1.SpeedUpdatesSpec{
+				Schedule: "30 * * * *",
+			}
+			instance.Annotations = map[string]string{
+				v1alpha1.UpdateSpeedsAnnotation: "token",
+			}
+		})
+
+		AfterEach(func() {
+			instance.Annotations = nil
+		})
+
+		It("Should return 'false' if no speed update was requested", func() {
+			instance.Annotations = nil
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+		})
+
+		It("Should return 'false' if SpeedUpdates is not set", func() {
+			instance.Spec.Profiles[0].SpeedUpdates = nil
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+		})
+
+		It("Should return 'false' if the request selects other profiles", func() {
+			instance.Annotations[v1alpha1.UpdateSpeedsProfilesAnnotation] = "foot, bicycle"
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+		})
+
+		It("Should return 'false' when map builder Job is not completed yet", func() {
+			resources := generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name)
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+		})
+
+		It("Should return 'false' once the request was recorded on the map data volume", func() {
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			resources[1].(*corev1.PersistentVolumeClaim).Annotations = map[string]string{
+				resource.SpeedUpdatesTokenAnnotation: "token",
+			}
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+		})
+
+		It("Should return 'true' when requested for the profile and map builder Job is completed", func() {
+			instance.Annotations[v1alpha1.UpdateSpeedsProfilesAnnotation] = "foot," + instance.Spec.Profiles[0].Name
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
+		})
+	})
+})
@@ -10,9 +10,11 @@ import (
 	. "github.com/onsi/gomega"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	k8sresource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var osrmResourceBuilder *resource.OSRMResourceBuilder
@@ -73,6 +75,23 @@ func generateChildResources(
 	return childResources
 }
 
+// newChildResources indexes fixtures as the child resources of the instance, and
+// the StorageClasses among them as those of its claims.
+func newChildResources(objects []runtime.Object) *resource.ChildResources {
+	children := []client.Object{}
+	storageClasses := []runtime.Object{}
+	for _, object := range objects {
+		if storageClass, ok := object.(*storagev1.StorageClass); ok {
+			storageClasses = append(storageClasses, storageClass)
+			continue
+		}
+		children = append(children, object.(client.Object))
+	}
+	childResources := resource.NewChildResources(instance, children)
+	childResources.AddStorageClasses(storageClasses)
+	return childResources
+}
+
 func generateOSRMCluster() *osrmv1alpha1.OSRMCluster {
 	minReplicas := int32(2)
 	maxReplicas := int32(4)
@@ -0,0 +1,93 @@
+package resource
+
+import (
+	"fmt"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/status"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// UploadJobBuilder builds the Job that uploads a profile's map data to object
+// storage once the map builder Job is completed.
+type UploadJobBuilder struct {
+	ProfileScopedBuilder
+	*OSRMResourceBuilder
+}
+
+func (builder *OSRMResourceBuilder) UploadJob(profile *osrmv1alpha1.ProfileSpec) *UploadJobBuilder {
+	return &UploadJobBuilder{
+		ProfileScopedBuilder{profile},
+		builder,
+	}
+}
+
+func (builder *UploadJobBuilder) Build() (client.Object, error) {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      builder.Instance.ChildResourceName(builder.profile.Name, UploadJobSuffix),
+			Namespace: builder.Instance.Namespace,
+			Labels:    metadata.GetProfileLabels(builder.Instance, builder.profile.Name),
+		},
+	}, nil
+}
+
+func (builder *UploadJobBuilder) Update(object client.Object, siblings *ChildResources) error {
+	job := object.(*batchv1.Job)
+
+	job.ObjectMeta.Labels = metadata.GetProfileLabels(builder.Instance, builder.profile.Name)
+
+	job.Spec = batchv1.JobSpec{
+		Selector: job.Spec.Selector,
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: job.Spec.Template.ObjectMeta.Labels,
+			},
+			Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyOnFailure,
+				Containers: []corev1.Container{
+					objectStorageContainer(
+						builder.Instance.Spec.ObjectStorage,
+						builder.Instance,
+						builder.profile,
+						builder.Instance.ChildResourceName(builder.profile.Name, UploadJobSuffix),
+						uploadScript,
+						true,
+					),
+				},
+				Volumes: []corev1.Volume{
+					{
+						Name: osrmDataVolumeName,
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
+								ReadOnly:  true,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(builder.Instance, job, builder.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference: %v", err)
+	}
+
+	return nil
+}
+
+func (builder *UploadJobBuilder) ShouldDeploy(resources *ChildResources) bool {
+	return builder.Instance.Spec.ObjectStorage != nil &&
+		builder.Instance.Spec.ObjectStorage.Upload &&
+		status.IsPersistentVolumeClaimBound(
+			builder.Instance.ChildResourceName(builder.profile.Name, PersistentVolumeClaimSuffix),
+			resources.Of(ProfileKey(builder.profile.Name)),
+		) &&
+		builder.isMapDataBuilt(builder.profile, resources)
+}
@@ -0,0 +1,69 @@
+package resource_test
+
+import (
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	defaultscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("UploadJob builder", func() {
+	var builder resource.ResourceBuilder
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(osrmv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(defaultscheme.AddToScheme(scheme)).To(Succeed())
+		builder = (&resource.OSRMResourceBuilder{Instance: instance, Scheme: scheme}).UploadJob(instance.Spec.Profiles[0])
+		instance.Spec.ObjectStorage = &osrmv1alpha1.ObjectStorageSpec{
+			Bucket: "osrm",
+			Upload: true,
+		}
+	})
+
+	AfterEach(func() {
+		instance.Spec.ObjectStorage = nil
+	})
+
+	Context("ShouldDeploy", func() {
+		It("Should return 'false' when upload is disabled", func() {
+			instance.Spec.ObjectStorage.Upload = false
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+		})
+
+		It("Should return 'false' when map builder Job is not completed yet", func() {
+			resources := generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name)
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(false))
+		})
+
+		It("Should return 'true' when map builder Job is completed", func() {
+			resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+			Expect(builder.ShouldDeploy(newChildResources(resources))).To(Equal(true))
+		})
+	})
+
+	Context("Update", func() {
+		It("Should point the upload container to the configured endpoint and bucket", func() {
+			endpoint := "http://minio:9000"
+			secret := "minio-credentials"
+			instance.Spec.ObjectStorage.Endpoint = &endpoint
+			instance.Spec.ObjectStorage.CredentialsSecretName = &secret
+
+			obj, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(builder.Update(obj, newChildResources([]runtime.Object{}))).To(Succeed())
+
+			container := obj.(*batchv1.Job).Spec.Template.Spec.Containers[0]
+			Expect(container.Env).To(ContainElements(
+				corev1.EnvVar{Name: "AWS_ENDPOINT_URL", Value: endpoint},
+				corev1.EnvVar{Name: "OBJECT_STORAGE_URL", Value: "s3://osrm/test/car"},
+			))
+			Expect(container.EnvFrom[0].SecretRef.Name).To(Equal(secret))
+			Expect(container.VolumeMounts[0].ReadOnly).To(BeTrue())
+		})
+	})
+})
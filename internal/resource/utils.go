@@ -2,9 +2,12 @@ package resource
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func getProfileSpec(profileName string, instance *osrmv1alpha1.OSRMCluster) *osrmv1alpha1.ProfileSpec {
@@ -16,6 +19,41 @@ func getProfileSpec(profileName string, instance *osrmv1alpha1.OSRMCluster) *osr
 	return nil
 }
 
+// shellJoin joins arguments into a shell command line, quoting the ones that
+// contain characters the shell would interpret.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=.,:/+") == "" {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
 func serviceToEnvVariable(serviceName string) string {
 	return fmt.Sprintf("%s_SERVICE_HOST", strings.ReplaceAll(strings.ToUpper(serviceName), "-", "_"))
 }
+
+func latestTime(a, b *metav1.Time) *metav1.Time {
+	if a == nil {
+		return b
+	}
+	if b == nil || b.Before(a) {
+		return a
+	}
+	return b
+}
+
+// appendMissingEnv appends the environment variables that are not set yet, since
+// the env of a container is a map keyed by name.
+func appendMissingEnv(env []corev1.EnvVar, extra ...corev1.EnvVar) []corev1.EnvVar {
+	for _, variable := range extra {
+		if !slices.ContainsFunc(env, func(existing corev1.EnvVar) bool { return existing.Name == variable.Name }) {
+			env = append(env, variable)
+		}
+	}
+	return env
+}
@@ -0,0 +1,70 @@
+package resource
+
+import (
+	"fmt"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The VolumeSnapshot API is served by the CSI external-snapshotter CRDs,
+// so snapshots are handled as unstructured objects.
+const VolumeSnapshotAPIGroup = "snapshot.storage.k8s.io"
+
+var VolumeSnapshotGVK = schema.GroupVersionKind{
+	Group:   VolumeSnapshotAPIGroup,
+	Version: "v1",
+	Kind:    "VolumeSnapshot",
+}
+
+var VolumeSnapshotListGVK = schema.GroupVersionKind{
+	Group:   VolumeSnapshotAPIGroup,
+	Version: "v1",
+	Kind:    "VolumeSnapshotList",
+}
+
+// VolumeSnapshot returns the snapshot of a profile's map data volume that captures
+// its latest map build or speed update, or nil when there is nothing to snapshot.
+// Snapshots are named after the time of the update they capture and have no
+// owner, so they outlive the OSRMCluster.
+func (builder *OSRMResourceBuilder) VolumeSnapshot(profile *osrmv1alpha1.ProfileSpec, resources *ChildResources) *unstructured.Unstructured {
+	snapshots := builder.Instance.Spec.Persistence.Snapshots
+	if snapshots == nil || !builder.Instance.Spec.HasMapDataVolume() || !builder.isMapDataBuilt(profile, resources) {
+		return nil
+	}
+
+	updateTime := latestTime(builder.LastMapBuildTime(profile, resources), builder.LastTrafficUpdateTime(profile, resources))
+	if updateTime == nil {
+		return nil
+	}
+
+	labels := metadata.GetProfileLabels(builder.Instance, profile.Name)
+
+	source := map[string]interface{}{
+		"persistentVolumeClaimName": builder.Instance.ChildResourceName(profile.Name, PersistentVolumeClaimSuffix),
+	}
+	spec := map[string]interface{}{
+		"source": source,
+	}
+	if snapshots.VolumeSnapshotClassName != nil {
+		spec["volumeSnapshotClassName"] = *snapshots.VolumeSnapshotClassName
+	}
+
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(VolumeSnapshotGVK)
+	snapshot.SetName(builder.Instance.ChildResourceName(
+		profile.Name,
+		fmt.Sprintf("%s-%d", VolumeSnapshotSuffix, updateTime.Unix()),
+	))
+	snapshot.SetNamespace(builder.Instance.Namespace)
+	snapshot.SetLabels(labels)
+	snapshot.SetAnnotations(map[string]string{
+		SnapshotDataTimeAnnotation: updateTime.Format(time.RFC3339),
+	})
+	snapshot.Object["spec"] = spec
+
+	return snapshot
+}
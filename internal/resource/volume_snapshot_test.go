@@ -0,0 +1,72 @@
+package resource_test
+
+import (
+	"fmt"
+	"time"
+
+	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
+	"github.com/itayankri/OSRM-Operator/internal/metadata"
+	"github.com/itayankri/OSRM-Operator/internal/resource"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("VolumeSnapshot", func() {
+	var completionTime metav1.Time
+	BeforeEach(func() {
+		completionTime = metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		className := "csi-snapclass"
+		instance.Spec.Persistence.Snapshots = &osrmv1alpha1.SnapshotsSpec{VolumeSnapshotClassName: &className}
+	})
+
+	AfterEach(func() {
+		instance.Spec.Persistence.Snapshots = nil
+	})
+
+	It("Should not snapshot when snapshots are disabled", func() {
+		instance.Spec.Persistence.Snapshots = nil
+		resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+		Expect(osrmResourceBuilder.VolumeSnapshot(instance.Spec.Profiles[0], newChildResources(resources))).To(BeNil())
+	})
+
+	It("Should not snapshot before map data is built", func() {
+		resources := generateChildResources(true, false, instance.Name, instance.Spec.Profiles[0].Name)
+		Expect(osrmResourceBuilder.VolumeSnapshot(instance.Spec.Profiles[0], newChildResources(resources))).To(BeNil())
+	})
+
+	It("Should snapshot the map data volume after a map build", func() {
+		resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+		resources[0].(*batchv1.Job).Status.CompletionTime = &completionTime
+
+		snapshot := osrmResourceBuilder.VolumeSnapshot(instance.Spec.Profiles[0], newChildResources(resources))
+		Expect(snapshot).NotTo(BeNil())
+		Expect(snapshot.GetName()).To(Equal(fmt.Sprintf("%s-%s-%s-%d", instance.Name, instance.Spec.Profiles[0].Name, resource.VolumeSnapshotSuffix, completionTime.Unix())))
+		Expect(snapshot.GetLabels()).To(HaveKeyWithValue(metadata.ProfileLabelKey, instance.Spec.Profiles[0].Name))
+		Expect(snapshot.GetOwnerReferences()).To(BeEmpty())
+
+		className, _, _ := unstructured.NestedString(snapshot.Object, "spec", "volumeSnapshotClassName")
+		Expect(className).To(Equal("csi-snapclass"))
+		claimName, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+		Expect(claimName).To(Equal(fmt.Sprintf("%s-%s", instance.Name, instance.Spec.Profiles[0].Name)))
+	})
+
+	It("Should take a new snapshot after a speed update", func() {
+		resources := generateChildResources(true, true, instance.Name, instance.Spec.Profiles[0].Name)
+		resources[0].(*batchv1.Job).Status.CompletionTime = &completionTime
+		speedUpdateTime := metav1.NewTime(completionTime.Add(time.Hour))
+		resources = append(resources, &batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("%s-%s-%s", instance.Name, instance.Spec.Profiles[0].Name, resource.CronJobSuffix),
+			},
+			Status: batchv1.CronJobStatus{
+				LastSuccessfulTime: &speedUpdateTime,
+			},
+		})
+
+		snapshot := osrmResourceBuilder.VolumeSnapshot(instance.Spec.Profiles[0], newChildResources(resources))
+		Expect(snapshot.GetName()).To(HaveSuffix(fmt.Sprintf("-%d", speedUpdateTime.Unix())))
+	})
+})
@@ -1,11 +1,16 @@
 package status
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -14,6 +19,11 @@ const (
 	ConditionAvailable             = "Available"
 	ConditionReconciliationSuccess = "ReconciliationSuccess"
 	ConditionAllReplicasReady      = "AllReplicasReady"
+	ConditionStorageSynced         = "StorageSynced"
+	ConditionMapBuildFailed        = "MapBuildFailed"
+	ConditionOSRMVersionCompatible = "OSRMVersionCompatible"
+	ConditionRoutingHealthy        = "RoutingHealthy"
+	ConditionDrifted               = "Drifted"
 )
 
 func AvailableCondition(resources []runtime.Object, old *metav1.Condition) metav1.Condition {
@@ -102,6 +112,93 @@ func IsPersistentVolumeClaimBound(pvcName string, resources []runtime.Object) bo
 	return pvcBound
 }
 
+func GetPersistentVolumeClaim(pvcName string, resources []runtime.Object) *corev1.PersistentVolumeClaim {
+	for _, resource := range resources {
+		if pvc, ok := resource.(*corev1.PersistentVolumeClaim); ok {
+			if pvc != nil && pvc.ObjectMeta.Name == pvcName {
+				return pvc
+			}
+		}
+	}
+	return nil
+}
+
+// IsVolumeExpansionAllowed returns true when the claim's StorageClass is among
+// the resources and allows volume expansion.
+func IsVolumeExpansionAllowed(pvc *corev1.PersistentVolumeClaim, resources []runtime.Object) bool {
+	if pvc.Spec.StorageClassName == nil {
+		return false
+	}
+	for _, resource := range resources {
+		if storageClass, ok := resource.(*storagev1.StorageClass); ok {
+			if storageClass != nil && storageClass.ObjectMeta.Name == *pvc.Spec.StorageClassName {
+				return storageClass.AllowVolumeExpansion != nil && *storageClass.AllowVolumeExpansion
+			}
+		}
+	}
+	return false
+}
+
+// StorageSyncedCondition compares the requested size of each claim with the
+// desired size, keyed by claim name, and reports claims that cannot be resized.
+func StorageSyncedCondition(desired map[string]resource.Quantity, resources []runtime.Object) metav1.Condition {
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var shrinking, unexpandable, expanding []string
+	for _, name := range names {
+		pvc := GetPersistentVolumeClaim(name, resources)
+		if pvc == nil {
+			continue
+		}
+
+		size := desired[name]
+		requested := pvc.Spec.Resources.Requests.Storage()
+		switch requested.Cmp(size) {
+		case 1:
+			shrinking = append(shrinking, name)
+		case -1:
+			if IsVolumeExpansionAllowed(pvc, resources) {
+				expanding = append(expanding, name)
+			} else {
+				unexpandable = append(unexpandable, name)
+			}
+		default:
+			if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok && capacity.Cmp(size) < 0 {
+				expanding = append(expanding, name)
+			}
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               ConditionStorageSynced,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.Time{Time: time.Now()},
+		Reason:             "Synced",
+		Message:            "All PersistentVolumeClaims have the requested size",
+	}
+
+	switch {
+	case len(shrinking) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ShrinkNotSupported"
+		condition.Message = fmt.Sprintf("PersistentVolumeClaims cannot be shrunk: %s", strings.Join(shrinking, ", "))
+	case len(unexpandable) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ExpansionNotSupported"
+		condition.Message = fmt.Sprintf("StorageClass does not allow volume expansion: %s", strings.Join(unexpandable, ", "))
+	case len(expanding) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Expanding"
+		condition.Message = fmt.Sprintf("PersistentVolumeClaims are being expanded: %s", strings.Join(expanding, ", "))
+	}
+
+	return condition
+}
+
 func IsJobCompleted(jobName string, resources []runtime.Object) bool {
 	jobCompleted := false
 	for _, resource := range resources {
@@ -119,6 +216,74 @@ func IsJobCompleted(jobName string, resources []runtime.Object) bool {
 	return jobCompleted
 }
 
+func IsJobFailed(jobName string, resources []runtime.Object) bool {
+	job := GetJob(jobName, resources)
+	if job == nil {
+		return false
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// GetJobFailureReason returns the reason and message of the Failed condition of a Job.
+func GetJobFailureReason(job *batchv1.Job) (string, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return condition.Reason, condition.Message
+		}
+	}
+	return "", ""
+}
+
+// LatestTerminationMessage returns the termination message of the most recently
+// terminated container among the pods, e.g. the pods of a Job.
+func LatestTerminationMessage(pods []corev1.Pod) string {
+	var latest *corev1.ContainerStateTerminated
+	for _, pod := range pods {
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			for _, terminated := range []*corev1.ContainerStateTerminated{
+				containerStatus.State.Terminated,
+				containerStatus.LastTerminationState.Terminated,
+			} {
+				if terminated == nil || terminated.Message == "" {
+					continue
+				}
+				if latest == nil || terminated.FinishedAt.After(latest.FinishedAt.Time) {
+					latest = terminated
+				}
+			}
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.Message
+}
+
+func GetJob(jobName string, resources []runtime.Object) *batchv1.Job {
+	for _, resource := range resources {
+		if job, ok := resource.(*batchv1.Job); ok {
+			if job != nil && job.ObjectMeta.Name == jobName {
+				return job
+			}
+		}
+	}
+	return nil
+}
+
+func GetDeployment(deploymentName string, resources []runtime.Object) *appsv1.Deployment {
+	for _, resource := range resources {
+		if deployment, ok := resource.(*appsv1.Deployment); ok && deployment.Name == deploymentName {
+			return deployment
+		}
+	}
+	return nil
+}
+
 func DoAllReplicasReady(resources []runtime.Object) bool {
 	allReplicasReady := false
 	for _, resource := range resources {
@@ -0,0 +1,206 @@
+package tracing
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const (
+	instrumentationName = "github.com/itayankri/OSRM-Operator"
+	serviceName         = "osrm-operator"
+)
+
+// Attributes of the spans of the operator.
+const (
+	NamespaceKey = attribute.Key("k8s.namespace.name")
+	ClusterKey   = attribute.Key("osrm.cluster")
+	KindKey      = attribute.Key("k8s.object.kind")
+	NameKey      = attribute.Key("k8s.object.name")
+	OperationKey = attribute.Key("osrm.operation")
+)
+
+// Options configure the export of the spans of the operator.
+type Options struct {
+	// Endpoint is the host:port of an OTLP/HTTP receiver. Tracing is disabled without it.
+	Endpoint string
+	// Insecure exports the spans over HTTP instead of HTTPS.
+	Insecure bool
+	// SamplingRatio is the fraction of the reconciliations that are traced,
+	// unless they continue a sampled trace.
+	SamplingRatio float64
+}
+
+// BindFlags binds the tracing options to command line flags.
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Endpoint, "tracing-endpoint", "",
+		"The host:port of the OTLP/HTTP receiver the spans are exported to. Tracing is disabled when empty.")
+	fs.BoolVar(&o.Insecure, "tracing-insecure", false, "Export the spans over HTTP instead of HTTPS.")
+	fs.Float64Var(&o.SamplingRatio, "tracing-sampling-ratio", 1, "The fraction of the reconciliations that are traced.")
+}
+
+// Enabled returns true when the spans are exported.
+func (o *Options) Enabled() bool {
+	return o.Endpoint != ""
+}
+
+// Setup exports the spans of the operator to the OTLP endpoint and propagates
+// the W3C trace context. It returns a function that flushes the remaining
+// spans on shutdown.
+func Setup(ctx context.Context, options Options) (func(context.Context) error, error) {
+	exporterOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(options.Endpoint)}
+	if options.Insecure {
+		exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating the OTLP exporter: %v", err)
+	}
+
+	provider := NewTracerProvider(sdktrace.WithBatcher(exporter), options.SamplingRatio)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider returns a TracerProvider that samples the given ratio of the
+// traces started by the operator and sends its spans to the given processor.
+func NewTracerProvider(processor sdktrace.TracerProviderOption, samplingRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		processor,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(samplingRatio))),
+		sdktrace.WithResource(sdkresource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+}
+
+// Tracer returns the tracer of the operator. Its spans are dropped unless Setup was called.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// End records the error a span ended with, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// NewClient creates the client of the manager, which records a span for each
+// call to the Kubernetes API.
+func NewClient(config *rest.Config, options client.Options) (client.Client, error) {
+	apiClient, err := client.NewWithWatch(config, options)
+	if err != nil {
+		return nil, err
+	}
+	return WrapClient(apiClient), nil
+}
+
+// WrapClient returns a client that records a span for each call to the Kubernetes API.
+func WrapClient(apiClient client.WithWatch) client.WithWatch {
+	return interceptor.NewClient(apiClient, interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			ctx, span := startAPISpan(ctx, c, "Get", obj, key.Namespace, key.Name)
+			err := c.Get(ctx, key, obj, opts...)
+			End(span, err)
+			return err
+		},
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			listOptions := (&client.ListOptions{}).ApplyOptions(opts)
+			ctx, span := startAPISpan(ctx, c, "List", list, listOptions.Namespace, "")
+			err := c.List(ctx, list, opts...)
+			End(span, err)
+			return err
+		},
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			ctx, span := startAPISpan(ctx, c, "Create", obj, obj.GetNamespace(), obj.GetName())
+			err := c.Create(ctx, obj, opts...)
+			End(span, err)
+			return err
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			ctx, span := startAPISpan(ctx, c, "Update", obj, obj.GetNamespace(), obj.GetName())
+			err := c.Update(ctx, obj, opts...)
+			End(span, err)
+			return err
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			ctx, span := startAPISpan(ctx, c, "Patch", obj, obj.GetNamespace(), obj.GetName())
+			err := c.Patch(ctx, obj, patch, opts...)
+			End(span, err)
+			return err
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			ctx, span := startAPISpan(ctx, c, "Delete", obj, obj.GetNamespace(), obj.GetName())
+			err := c.Delete(ctx, obj, opts...)
+			End(span, err)
+			return err
+		},
+		DeleteAllOf: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteAllOfOption) error {
+			deleteOptions := (&client.DeleteAllOfOptions{}).ApplyOptions(opts)
+			ctx, span := startAPISpan(ctx, c, "DeleteAllOf", obj, deleteOptions.Namespace, "")
+			err := c.DeleteAllOf(ctx, obj, opts...)
+			End(span, err)
+			return err
+		},
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			ctx, span := startAPISpan(ctx, c, "Update "+subResource, obj, obj.GetNamespace(), obj.GetName())
+			err := c.SubResource(subResource).Update(ctx, obj, opts...)
+			End(span, err)
+			return err
+		},
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			ctx, span := startAPISpan(ctx, c, "Patch "+subResource, obj, obj.GetNamespace(), obj.GetName())
+			err := c.SubResource(subResource).Patch(ctx, obj, patch, opts...)
+			End(span, err)
+			return err
+		},
+	})
+}
+
+// startAPISpan starts the span of a call to the Kubernetes API, named after the
+// operation and the kind of the object, e.g. "Get Deployment".
+func startAPISpan(
+	ctx context.Context,
+	c client.Client,
+	operation string,
+	obj runtime.Object,
+	namespace, name string,
+) (context.Context, trace.Span) {
+	kind := fmt.Sprintf("%T", obj)
+	if gvk, err := c.GroupVersionKindFor(obj); err == nil {
+		kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+	attributes := []attribute.KeyValue{KindKey.String(kind)}
+	if namespace != "" {
+		attributes = append(attributes, NamespaceKey.String(namespace))
+	}
+	if name != "" {
+		attributes = append(attributes, NameKey.String(name))
+	}
+	return Tracer().Start(
+		ctx,
+		fmt.Sprintf("%s %s", operation, kind),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+	)
+}
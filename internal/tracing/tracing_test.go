@@ -0,0 +1,91 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/itayankri/OSRM-Operator/internal/tracing"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Tracing", func() {
+	var exporter *tracetest.InMemoryExporter
+	var provider *sdktrace.TracerProvider
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		provider = tracing.NewTracerProvider(sdktrace.WithSyncer(exporter), 1)
+		otel.SetTracerProvider(provider)
+	})
+
+	AfterEach(func() {
+		Expect(provider.Shutdown(context.Background())).To(Succeed())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	It("Should record a span for each call to the Kubernetes API", func() {
+		ctx, parent := tracing.Tracer().Start(context.Background(), "Reconcile")
+		apiClient := tracing.WrapClient(fake.NewClientBuilder().Build())
+
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "osrm"}}
+		Expect(apiClient.Create(ctx, configMap)).To(Succeed())
+		Expect(apiClient.Get(ctx, client.ObjectKeyFromObject(configMap), configMap)).To(Succeed())
+		Expect(apiClient.List(ctx, &appsv1.DeploymentList{}, client.InNamespace("osrm"))).To(Succeed())
+		parent.End()
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(4))
+		Expect(spans[0].Name).To(Equal("Create ConfigMap"))
+		Expect(spans[0].SpanKind).To(Equal(trace.SpanKindClient))
+		Expect(spans[0].Attributes).To(ConsistOf(
+			tracing.KindKey.String("ConfigMap"),
+			tracing.NamespaceKey.String("osrm"),
+			tracing.NameKey.String("test"),
+		))
+		Expect(spans[1].Name).To(Equal("Get ConfigMap"))
+		Expect(spans[2].Name).To(Equal("List Deployment"))
+		Expect(spans[2].Attributes).To(ConsistOf(
+			tracing.KindKey.String("Deployment"),
+			tracing.NamespaceKey.String("osrm"),
+		))
+		for _, span := range spans[:3] {
+			Expect(span.Parent.SpanID()).To(Equal(spans[3].SpanContext.SpanID()))
+		}
+	})
+
+	It("Should record the errors of failed calls", func() {
+		apiClient := tracing.WrapClient(fake.NewClientBuilder().Build())
+		err := apiClient.Get(context.Background(), client.ObjectKey{Name: "missing"}, &corev1.ConfigMap{})
+		Expect(err).To(HaveOccurred())
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Status.Code).To(Equal(codes.Error))
+		Expect(spans[0].Events).To(HaveLen(1))
+		Expect(spans[0].Events[0].Name).To(Equal("exception"))
+	})
+
+	It("Should end a span with its error", func() {
+		_, span := tracing.Tracer().Start(context.Background(), "CreateOrUpdate")
+		span.SetAttributes(tracing.OperationKey.String("created"))
+		tracing.End(span, errors.New("conflict"))
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Status).To(Equal(sdktrace.Status{Code: codes.Error, Description: "conflict"}))
+		Expect(spans[0].Attributes).To(ContainElement(attribute.String("osrm.operation", "created")))
+	})
+})
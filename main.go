@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 
@@ -35,6 +36,7 @@ import (
 
 	osrmv1alpha1 "github.com/itayankri/OSRM-Operator/api/v1alpha1"
 	"github.com/itayankri/OSRM-Operator/controllers"
+	"github.com/itayankri/OSRM-Operator/internal/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -59,15 +61,26 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
-	opts := zap.Options{
-		Development: true,
-	}
+	// Logs are written as JSON at the info level by default. --zap-devel switches to
+	// human-readable logs at the debug level, and --zap-log-level=debug (or 2, for
+	// the spec of each reconciled OSRMCluster) enables the verbose logs.
+	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
+	tracingOpts := tracing.Options{}
+	tracingOpts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if err := run(metricsAddr, probeAddr, enableLeaderElection, tracingOpts); err != nil {
+		os.Exit(1)
+	}
+}
+
+// run starts the manager and blocks until it stops. It returns instead of exiting
+// so that the deferred tracing shutdown flushes the remaining spans on every error.
+func run(metricsAddr, probeAddr string, enableLeaderElection bool, tracingOpts tracing.Options) error {
+	options := ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
 			BindAddress: metricsAddr,
@@ -75,30 +88,60 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "746cc0df.itayankri",
-	})
+	}
+	if tracingOpts.Enabled() {
+		shutdownTracing, err := tracing.Setup(context.Background(), tracingOpts)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			return err
+		}
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				setupLog.Error(err, "problem flushing the remaining spans")
+			}
+		}()
+		options.NewClient = tracing.NewClient
+	}
+
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		setupLog.Error(err, "unable to load the kubeconfig")
+		return err
+	}
+
+	mgr, err := ctrl.NewManager(config, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
+		return err
+	}
+
+	monitorKinds, err := controllers.DetectMonitorKinds(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to detect the Prometheus Operator CRDs, monitors are not created")
+		monitorKinds = map[osrmv1alpha1.MonitorKind]bool{}
 	}
 
-	if err = (controllers.NewOSRMClusterReconciler(mgr.GetClient(), mgr.GetScheme())).SetupWithManager(mgr); err != nil {
+	reconciler := controllers.NewOSRMClusterReconciler(mgr.GetClient(), mgr.GetScheme(), mgr.GetEventRecorderFor("osrmcluster-controller"))
+	reconciler.MonitorKinds = monitorKinds
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "OSRMCluster")
-		os.Exit(1)
+		return err
 	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
+		return err
 	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
-		os.Exit(1)
+		return err
 	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
-		os.Exit(1)
+		return err
 	}
+	return nil
 }